@@ -0,0 +1,251 @@
+package model
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+)
+
+//
+// Result cache.
+// Get() and List() results keyed by kind, rendered SQL and
+// parameters. Each entry is tagged with the generation of the
+// kind and the cache epoch when the query was issued. Entries are
+// invalid when the generation (incremented when changes to the kind
+// are committed) or the epoch (incremented on purge) has changed.
+// Least recently used (LRU) entries are evicted when full.
+type cache struct {
+	// Max number of entries.
+	size int
+	// Protect internal state.
+	mutex sync.Mutex
+	// Entries by key.
+	entries map[string]*list.Element
+	// Entries (LRU) order.
+	lru *list.List
+	// Incremented on purge.
+	epoch uint64
+	// Number of hits.
+	hits int64
+	// Number of misses.
+	misses int64
+}
+
+//
+// Cache entry.
+type cacheEntry struct {
+	// Key.
+	key string
+	// Generation of the kind.
+	generation uint64
+	// Cache epoch.
+	epoch uint64
+	// Cached (copy) of the result.
+	value reflect.Value
+}
+
+//
+// New cache.
+func newCache(size int) *cache {
+	return &cache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+//
+// Get the current epoch.
+// Must be captured before the query is issued.
+func (c *cache) Epoch() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.epoch
+}
+
+//
+// Get a (copy) of the cached result.
+// Invalid entries are removed.
+func (c *cache) Get(key string, generation uint64) (reflect.Value, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		c.misses++
+		return reflect.Value{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.generation != generation || entry.epoch != c.epoch {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		c.misses++
+		return reflect.Value{}, false
+	}
+	c.lru.MoveToFront(element)
+	c.hits++
+	copied, _ := c.copy(entry.value)
+
+	return copied, true
+}
+
+//
+// Put a (copy) of the result.
+// Ignored when purged since the epoch was captured or
+// the result cannot be copied.
+func (c *cache) Put(key string, generation, epoch uint64, value reflect.Value) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if epoch != c.epoch {
+		return
+	}
+	copied, ok := c.copy(value)
+	if !ok {
+		return
+	}
+	entry := &cacheEntry{
+		key:        key,
+		generation: generation,
+		epoch:      epoch,
+		value:      copied,
+	}
+	if element, found := c.entries[key]; found {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		last := c.lru.Back()
+		c.lru.Remove(last)
+		delete(c.entries, last.Value.(*cacheEntry).key)
+	}
+}
+
+//
+// Purge all entries.
+func (c *cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.epoch++
+}
+
+//
+// Get the number of hits and misses.
+func (c *cache) Stats() (hits, misses int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hits, c.misses
+}
+
+//
+// Copy the value (deep).
+// Slices, arrays, maps, pointers and struct fields are
+// copied recursively so nothing is shared with the caller.
+// Returns false when the value cannot be copied because it
+// contains a (set) unexported reference field, interface,
+// channel or function.
+func (c *cache) copy(value reflect.Value) (copied reflect.Value, ok bool) {
+	copied = reflect.New(value.Type()).Elem()
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.IsNil() {
+			break
+		}
+		copied.Set(reflect.MakeSlice(value.Type(), value.Len(), value.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			v, ok := c.copy(value.Index(i))
+			if !ok {
+				return copied, false
+			}
+			copied.Index(i).Set(v)
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			break
+		}
+		copied.Set(reflect.MakeMapWithSize(value.Type(), value.Len()))
+		iter := value.MapRange()
+		for iter.Next() {
+			k, ok := c.copy(iter.Key())
+			if !ok {
+				return copied, false
+			}
+			v, ok := c.copy(iter.Value())
+			if !ok {
+				return copied, false
+			}
+			copied.SetMapIndex(k, v)
+		}
+	case reflect.Ptr:
+		if value.IsNil() {
+			break
+		}
+		v, ok := c.copy(value.Elem())
+		if !ok {
+			return copied, false
+		}
+		copied.Set(reflect.New(v.Type()))
+		copied.Elem().Set(v)
+	case reflect.Struct:
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Field(i)
+			if !c.shared(field) {
+				continue
+			}
+			if !copied.Field(i).CanSet() {
+				return copied, false
+			}
+			v, ok := c.copy(field)
+			if !ok {
+				return copied, false
+			}
+			copied.Field(i).Set(v)
+		}
+	case reflect.Interface,
+		reflect.Chan,
+		reflect.Func,
+		reflect.UnsafePointer:
+		if !value.IsNil() {
+			return copied, false
+		}
+	default:
+		copied.Set(value)
+	}
+
+	return copied, true
+}
+
+//
+// Determine whether the value contains a (set) reference
+// that would be shared by a (shallow) copy.
+func (c *cache) shared(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice,
+		reflect.Map,
+		reflect.Ptr,
+		reflect.Interface,
+		reflect.Chan,
+		reflect.Func,
+		reflect.UnsafePointer:
+		return !value.IsNil()
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if c.shared(value.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if c.shared(value.Field(i)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
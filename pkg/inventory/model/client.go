@@ -1,12 +1,25 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
 	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/konveyor/controller/pkg/logging"
+	"github.com/konveyor/controller/pkg/ref"
+	"github.com/mattn/go-sqlite3"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -14,81 +27,501 @@ const (
 )
 
 //
-// Tx.Commit()
-// Tx.End()
-// Called and the transaction is not in progress by
-// the associated Client.
-var TxInvalidError = errors.New("transaction not valid")
+// Default logger.
+var log = logging.WithName("model")
+
+//
+// Regex used to validate pragma names and values.
+var PragmaRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+//
+// Registered drivers (name) keyed by pragmas.
+// Drivers cannot be unregistered so one is registered
+// for each distinct set of pragmas.
+var drivers = struct {
+	sync.Mutex
+	names map[string]string
+}{
+	names: map[string]string{},
+}
+
+//
+// Invalid pragma name or value.
+var PragmaInvalidError = errors.New("pragma not valid")
+
+//
+// Open() called and no models registered.
+var NoModelsError = errors.New("no models registered")
+
+//
+// The journal staged events cap exceeded.
+var JournalFullError = errors.New("journal full")
+
+//
+// The journal has active watches.
+var JournalWatchedError = errors.New("journal has active watches")
+
+//
+// The watch handler must implement SnapshotEventHandler.
+var SnapshotHandlerError = errors.New("handler must implement SnapshotEventHandler")
+
+//
+// Invalid promoted label kind or name.
+var PromotedLabelError = errors.New("promoted label not valid")
+
+//
+// Transaction in progress.
+var TxInProgressError = errors.New("transaction in progress")
+
+//
+// Model kind not registered.
+var KindNotFoundError = errors.New("kind not registered")
+
+//
+// Invalid label kind namespace.
+var NamespaceInvalidError = errors.New("namespace not valid")
+
+//
+// The DB is locked (opened) by another client or process.
+var InUseError = errors.New("database in use")
+
+//
+// The (exclusive) lock is not supported on the platform.
+var LockNotSupportedError = errors.New("lock not supported")
+
+//
+// Replicas are not supported by in-memory databases.
+var ReplicaNotSupportedError = errors.New("replicas not supported")
+
+//
+// Regex used to match full table scans in query plans.
+var ScanRegex = regexp.MustCompile(`^SCAN (TABLE )?(\w+)`)
+
+//
+// The List() result was truncated at MaxListRows.
+var ResultTruncated = errors.New("list result truncated")
+
+//
+// DB lifecycle actions.
+const (
+	Opened = "opened"
+	Closed = "closed"
+)
+
+//
+// DB lifecycle event.
+type LifecycleEvent struct {
+	// The action (opened|closed).
+	Action string
+	// The DB file path.
+	Path string
+	// The error when the action failed.
+	Error error
+}
 
 //
 // Database client.
 type DB interface {
+	// Register models.
+	Register(...interface{}) error
+	// Get a new model for the kind.
+	ModelForKind(string) (Model, error)
 	// Open and build the schema.
 	Open(bool) error
 	// Close.
 	Close(bool) error
+	// Get the schema DDL.
+	Schema() ([]string, error)
+	// Verify the schema matches the models.
+	Verify() error
+	// Backup (copy) the DB to the specified path.
+	Backup(string) error
+	// Fork (backup and open) the DB.
+	Fork(string) (*Client, error)
+	// Get DB statistics.
+	Stats() (Stats, error)
+	// Import models from another DB.
+	ImportFrom(DB, bool, ...interface{}) error
+	// Delete all models and rebuild the schema.
+	Reset() error
+	// Delete orphaned labels.
+	CompactLabels() (int64, error)
 	// Get the specified model.
 	Get(Model) error
+	// Get the specified model by unique index.
+	GetByUnique(Model, string) error
+	// Get the model referenced by a (fk) field.
+	Related(Model, string, Model) error
+	// List the models referencing a (parent) model.
+	RelatedList(Model, Model, string, interface{}) error
 	// Get for update of the specified model.
 	GetForUpdate(Model) (*Tx, error)
+	// List for update of the matching models.
+	ListForUpdate(interface{}, ListOptions) (*Tx, error)
+	// Get for update of the models by primary key.
+	GetManyForUpdate(Model, []interface{}) (*Tx, []Model, error)
+	// Get, mutate and update the specified model (locked).
+	UpdateLocked(Model, func(Model) error) error
 	// List models based on the type of slice.
 	List(interface{}, ListOptions) error
+	// List models (keyset pagination).
+	ListAfter(interface{}, ListOptions) ([]interface{}, error)
+	// List models updated since a time.
+	ListSince(interface{}, time.Time, ListOptions) error
+	// List models matching the predicate.
+	Find(interface{}, Predicate) error
+	// Get models by primary key.
+	GetAll(Model, []interface{}) ([]Model, error)
+	// List primary keys based on the specified model and predicate.
+	ListKeys(Model, Predicate) ([]interface{}, error)
+	// List models in chunks (by PK).
+	ListChunked(Model, ListOptions, int, func([]Model) error) error
+	// Full-text search.
+	Search(Model, string) ([]Model, error)
 	// Count based on the specified model.
 	Count(Model, Predicate) (int64, error)
+	// Count based on the specified model and list options.
+	CountOptions(Model, ListOptions) (int64, error)
+	// Approximate count of the specified model.
+	ApproxCount(Model) (int64, error)
+	// Count labels by name for the specified model.
+	LabelCounts(Model) (map[string]int64, error)
+	// Count label values for the specified model and label name.
+	LabelValueCounts(Model, string) (map[string]int64, error)
+	// List distinct label names for the specified models.
+	LabelKeys(...Model) ([]string, error)
 	// Begin a transaction.
 	Begin() (*Tx, error)
+	// Begin a transaction with options.
+	BeginWith(TxOptions) (*Tx, error)
+	// Run a function with a (consistent) read view.
+	View(func(*View) error) error
+	// Run a function with the journal suspended.
+	WithoutJournal(func() error) error
 	// Insert a model.
 	Insert(Model) error
+	// Insert a model when absent.
+	InsertIfAbsent(Model) (bool, error)
+	// Insert a model with options.
+	InsertWith(Model, InsertOptions) error
+	// Insert models with options.
+	InsertAll([]Model, InsertOptions) error
+	// List the named fields of models into a list of structs.
+	ListProjection(interface{}, Model, []string, ListOptions) error
 	// Update a model.
 	Update(Model) error
+	// Touch a model.
+	Touch(Model) error
+	// Update a model when changed.
+	UpdateChanged(Model) (bool, error)
+	// Update the named fields of a model.
+	UpdateFields(Model, ...string) error
+	// Update the named fields of all models matching a predicate.
+	UpdateAll(Model, map[string]interface{}, Predicate) (int64, error)
+	// Increment the named (int) field of a model.
+	Increment(Model, string, int64) (int64, error)
 	// Delete a model.
 	Delete(Model) error
+	// Delete a model when the predicate matches.
+	DeleteIf(Model, Predicate) (bool, error)
+	// Delete ALL models matching the predicate.
+	DeleteAll(Model, Predicate) (int64, error)
+	// Delete models by primary key.
+	DeleteKeys(Model, []interface{}) (int64, error)
+	// Delete expired models.
+	Sweep(Model) (int64, error)
+	// Reconcile the models matching the predicate with the desired models.
+	Reconcile(Model, []Model, Predicate) (ReconcileResult, error)
+	// Query models using raw SQL.
+	Query(Model, string, ...interface{}) ([]Model, error)
+	// Query using raw SQL into an arbitrary struct list.
+	QueryInto(interface{}, string, ...interface{}) error
+	// Execute raw SQL.
+	Exec(string, ...interface{}) (int64, error)
 	// Watch a model collection.
 	Watch(Model, EventHandler) (*Watch, error)
+	// Watch a model collection.
+	// The initial replay is aborted when the context is done.
+	WatchContext(context.Context, Model, EventHandler) (*Watch, error)
+	// Watch a model collection with options.
+	WatchWith(Model, EventHandler, WatchOptions) (*Watch, error)
 	// The journal
 	Journal() *Journal
+	// Get the generation of a model (kind).
+	Generation(Model) uint64
 }
 
 //
 // Database client.
 type Client struct {
+	// Pragmas applied to each connection on Open().
+	// Name = value. Default: foreign_keys = ON.
+	Pragmas map[string]string
+	// Labels promoted to columns.
+	// Map of: kind (table name) => label names.
+	// Selectors on promoted labels match an indexed column
+	// in the model table rather than the Label table.
+	PromotedLabels map[string][]string
+	// Lifecycle (hook) called after Open() and Close().
+	// Optional.
+	Lifecycle func(LifecycleEvent)
+	// Add missing columns on Open(). Columns for fields added
+	// to the models are added to the existing tables. See:
+	// Table.AddColumns().
+	AddColumns bool
+	// Detect schema drift on Open(). See: Verify().
+	// The columns of an existing DB are compared with the
+	// models and a SchemaDrift error is returned when
+	// they do not match. Indexes and constraints are
+	// compared only by Verify().
+	DetectDrift bool
+	// Naming strategy used to map model (type) and field
+	// names to table and column names. Must not change for
+	// the life of the DB. Default: identity.
+	Naming Naming
+	// Label kind namespace (prefix).
+	// Disambiguates labels (kind) when clients share a DB
+	// file. All clients sharing a DB file should be namespaced.
+	// Must not change for the life of the DB.
+	Namespace string
+	// Close() returns TxInProgressError when a transaction
+	// is in progress. Else, the transaction is rolled back.
+	StrictClose bool
+	// Number of read-only (replica) connections opened
+	// on Open(). Reads (Get, List, Count) are balanced
+	// round-robin across the replicas. Writes use the
+	// primary connection. Best used with WAL journaling
+	// (journal_mode = WAL) so readers and the writer do
+	// not block each other.
+	Replicas int
+	// Timeout applied to each read (Get, List, Count, Query).
+	// A stuck read is aborted when exceeded and TimeoutError
+	// is returned. Not applied to writes which may issue
+	// multiple statements and be partially applied when
+	// interrupted. A no-op within a transaction (including
+	// Tx reads) because sqlite rolls back the transaction when
+	// a statement is interrupted. Does not bound the wait to
+	// acquire the client (DB and transaction) locks.
+	// Zero disables.
+	DefaultTimeout time.Duration
+	// Result cache size (entries).
+	// Get() and List() results are cached when > 0. Cached
+	// results of a kind are invalidated when changes to the kind
+	// are committed. Staged reads (Tx), reads while the journal
+	// is suspended and using Subquery predicates are not cached.
+	// Models are copied (deep) in and out of the cache. Results
+	// with (set) unexported reference fields such as labels
+	// populated by LabelSetter are not cached.
+	CacheSize int
+	// Permission mode used to create the (missing) parent
+	// directories of the DB file on Open(). Zero disables
+	// and the directory must exist.
+	DirMode os.FileMode
+	// Lock the DB for exclusive use on Open().
+	// An advisory (flock) lock is held on the lock file
+	// (path + ".lock") until Close(). Open() fails fast with
+	// InUseError when the lock is held by another client or
+	// process. Not enabled for deployments that intentionally
+	// share the DB file (Eg: WAL with multiple processes).
+	// Supported on unix platforms. Else, Open() fails with
+	// LockNotSupportedError.
+	Exclusive bool
+	// Logger (optional).
+	// Default: logging.WithName("model").
+	Log logr.Logger
+	// Interval used to sweep (delete) expired models in the
+	// background. Models having an `expires` field are swept.
+	// The sweeper is started on Open() and stopped on Close().
+	// Zero disables. See: Sweep().
+	SweepInterval time.Duration
+	// Expired (not yet swept) models are excluded by Get(),
+	// List() and Count(). Get() returns NotFound. Results are
+	// not cached.
+	ExcludeExpired bool
+	// Safety limit on the number of models returned by List()
+	// when no Page is specified. When exceeded, the list is
+	// truncated and ResultTruncated is returned so the caller
+	// knows to paginate. Zero disables.
+	MaxListRows int
+	// Lint the query plan of predicate-filtered List() and
+	// Count() queries. A suggestion naming the fields that
+	// would benefit from an index is logged when a full table
+	// scan is detected. Intended for development only; each
+	// query is explained (EXPLAIN QUERY PLAN) before executed.
+	LintIndexes bool
+	// Generate the (str) PK of models without natural keys
+	// inserted with the PK not set. Models implementing
+	// IDGenerator generate their own. Example:
+	//   client.GenerateID = model.UUID
+	//   client.GenerateID = (&model.ULID{}).Generate
+	GenerateID func() string
 	// Protect internal state.
 	sync.RWMutex
 	// The sqlite3 database will not support
 	// concurrent write operations.
 	dbMutex sync.Mutex
+	// Serialize transactions.
+	// Held from Begin() until committed or ended.
+	txMutex sync.Mutex
+	// Result cache.
+	cache *cache
+	// Purge the cache on commit.
+	purge bool
 	// file path.
 	path string
 	// Model
 	models []interface{}
+	// Registered model types by kind.
+	kinds map[string]reflect.Type
 	// Database connection.
 	db *sql.DB
+	// Lock file (exclusive).
+	lockFile *os.File
+	// Foreign key constraints are enforced.
+	foreignKeys bool
+	// Stop the sweeper.
+	sweeperStop chan struct{}
+	// The sweeper has stopped.
+	sweeperDone chan struct{}
+	// Read-only (replica) connections.
+	replicas []*sql.DB
+	// Next replica (round-robin).
+	nextReplica uint64
 	// Current database transaction.
 	tx *sql.Tx
 	// Journal
 	journal Journal
 }
 
+//
+// Register models.
+// Models registered more than once (same table) are ignored.
+// Must be called before Open(). Returns AlreadyOpenError when
+// the DB is open.
+func (r *Client) Register(models ...interface{}) error {
+	if r.db != nil {
+		return liberr.Wrap(AlreadyOpenError)
+	}
+	r.register(models...)
+
+	return nil
+}
+
+//
+// Register models.
+func (r *Client) register(models ...interface{}) {
+	registered := map[string]bool{}
+	for _, m := range r.models {
+		registered[r.table(nil).Name(m)] = true
+	}
+	for _, m := range models {
+		name := r.table(nil).Name(m)
+		if registered[name] {
+			continue
+		}
+		registered[name] = true
+		r.models = append(r.models, m)
+	}
+
+	r.index()
+}
+
+//
+// Index the registered model types by kind.
+func (r *Client) index() {
+	r.Lock()
+	defer r.Unlock()
+	r.kinds = map[string]reflect.Type{}
+	table := r.table(nil)
+	for _, m := range r.models {
+		mt := reflect.TypeOf(m)
+		if mt.Kind() == reflect.Ptr {
+			mt = mt.Elem()
+		}
+		r.kinds[table.Kind(m)] = mt
+	}
+}
+
+//
+// Get a new model for the kind.
+// The `kind` is the (namespaced) kind as stored in Label.Kind.
+// Returns KindNotFoundError when no model of the kind
+// is registered.
+func (r *Client) ModelForKind(kind string) (Model, error) {
+	r.RLock()
+	mt, found := r.kinds[kind]
+	r.RUnlock()
+	if !found {
+		return nil, liberr.Wrap(KindNotFoundError)
+	}
+	m, cast := reflect.New(mt).Interface().(Model)
+	if !cast {
+		return nil, liberr.Wrap(MustBeObjectErr)
+	}
+
+	return m, nil
+}
+
 //
 // Create the database.
-// Build the schema to support the specified models.
+// Build the schema to support the registered models.
 // Optionally `purge` (delete) the DB first.
-func (r *Client) Open(purge bool) error {
+func (r *Client) Open(purge bool) (err error) {
+	defer func() {
+		r.notify(Opened, err)
+	}()
+	registered := false
+	for _, m := range r.models {
+		if _, cast := m.(*Label); !cast {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return liberr.Wrap(NoModelsError)
+	}
+	if r.Namespace != "" && !ColumnRegex.MatchString(r.Namespace) {
+		return liberr.Wrap(NamespaceInvalidError)
+	}
+	if r.DirMode != 0 {
+		err = os.MkdirAll(filepath.Dir(r.path), r.DirMode)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+	}
+	if r.Exclusive {
+		err = r.acquireLock()
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		defer func() {
+			if err != nil {
+				r.releaseLock()
+			}
+		}()
+	}
 	if purge {
 		os.Remove(r.path)
 	}
-	db, err := sql.Open("sqlite3", r.path)
+	pragmas, err := r.pragmas()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	db, err := sql.Open(r.driver(pragmas), r.path)
 	if err != nil {
 		panic(err)
 	}
-	statements := []string{Pragma}
-	r.models = append(r.models, &Label{})
-	for _, m := range r.models {
-		ddl, err := Table{}.DDL(m)
+	r.register(&Label{})
+	if r.AddColumns {
+		err = r.addColumns(db)
 		if err != nil {
-			panic(err)
+			db.Close()
+			return liberr.Wrap(err)
 		}
-		statements = append(statements, ddl...)
+	}
+	statements, err := r.Schema()
+	if err != nil {
+		panic(err)
 	}
 	for _, ddl := range statements {
 		_, err = db.Exec(ddl)
@@ -97,271 +530,3282 @@ func (r *Client) Open(purge bool) error {
 			return liberr.Wrap(err)
 		}
 	}
+	err = r.promote(db)
+	if err != nil {
+		db.Close()
+		return liberr.Wrap(err)
+	}
+	err = r.checkForeignKeys(db)
+	if err != nil {
+		db.Close()
+		return liberr.Wrap(err)
+	}
+	if r.DetectDrift {
+		err = r.drift(db, false)
+		if err != nil {
+			db.Close()
+			return liberr.Wrap(err)
+		}
+	}
+
+	err = r.openReplicas(pragmas)
+	if err != nil {
+		db.Close()
+		return liberr.Wrap(err)
+	}
 
 	r.db = db
+	r.cache = nil
+	if r.CacheSize > 0 {
+		r.cache = newCache(r.CacheSize)
+	}
+	r.startSweeper()
 
 	return nil
 }
 
 //
-// Close the database.
-// Optionally purge (delete) the DB.
-func (r *Client) Close(purge bool) error {
-	if r.db == nil {
-		return nil
+// Add missing columns to the existing tables.
+// Tables not yet created are skipped.
+func (r *Client) addColumns(db DBTX) error {
+	table := r.table(db)
+	for _, m := range r.models {
+		columns, err := table.Columns(m)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		added, err := table.AddColumns(m)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		if len(added) > 0 {
+			r.logger().Info(
+				"Columns added.",
+				"table",
+				table.Name(m),
+				"columns",
+				added)
+		}
 	}
-	err := r.db.Close()
-	if err != nil {
+
+	return nil
+}
+
+//
+// Get the logger.
+func (r *Client) logger() logr.Logger {
+	if r.Log != nil {
+		return r.Log
+	}
+
+	return log
+}
+
+//
+// Determine whether foreign key constraints are enforced.
+// The `foreign_keys` pragma is read back because it is silently
+// ignored when disabled (overridden) or not supported by sqlite.
+// When not enforced, a warning is logged and ON DELETE CASCADE
+// is emulated by the client. See: cascade().
+func (r *Client) checkForeignKeys(db *sql.DB) error {
+	n := int64(0)
+	err := db.QueryRow("PRAGMA foreign_keys").Scan(&n)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return liberr.Wrap(err)
 	}
-	r.db = nil
-	if purge {
-		os.Remove(r.path)
+	r.foreignKeys = n == 1
+	if !r.foreignKeys {
+		r.logger().Info(
+			"Foreign keys not enforced, cascading deletes emulated.",
+			"path",
+			r.path)
 	}
 
 	return nil
 }
 
 //
-// Get the model.
-func (r *Client) Get(model Model) error {
-	return Table{r.db}.Get(model)
+// Foreign key constraints are enforced by the DB.
+// Determined on Open(). When not enforced, deleting a
+// model deletes referencing (fk) models and their labels
+// (ON DELETE CASCADE emulated) by the client.
+func (r *Client) ForeignKeys() bool {
+	return r.foreignKeys
 }
 
 //
-// Get the model for update.
-// Locks the DB by beginning a transaction.
-// The caller MUST commit/end the returned Tx.
-func (r *Client) GetForUpdate(model Model) (*Tx, error) {
-	tx, err := r.Begin()
+// Acquire the (exclusive) advisory lock.
+// Returns InUseError when held by another client or process.
+func (r *Client) acquireLock() error {
+	if r.lockFile != nil {
+		return nil
+	}
+	f, err := os.OpenFile(r.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
 	if err != nil {
-		return nil, liberr.Wrap(err)
+		return liberr.Wrap(err)
 	}
-	err = Table{r.db}.Get(model)
+	err = flock(f)
 	if err != nil {
-		tx.End()
-		tx = nil
+		f.Close()
+		return err
 	}
+	r.lockFile = f
 
-	return tx, err
+	return nil
 }
 
 //
-// List models.
-// The `list` must be: *[]Model.
-func (r *Client) List(list interface{}, options ListOptions) error {
-	return Table{r.db}.List(list, options)
+// Release the (exclusive) advisory lock.
+// The lock file is not deleted.
+func (r *Client) releaseLock() {
+	if r.lockFile == nil {
+		return
+	}
+	funlock(r.lockFile)
+	r.lockFile.Close()
+	r.lockFile = nil
 }
 
 //
-// Count models.
-func (r *Client) Count(model Model, predicate Predicate) (int64, error) {
-	return Table{r.db}.Count(model, predicate)
+// Build a table using the naming strategy.
+func (r *Client) table(db DBTX) Table {
+	return Table{
+		DB:         db,
+		Naming:     r.Naming,
+		Namespace:  r.Namespace,
+		GenerateID: r.GenerateID,
+	}
 }
 
 //
-// Begin a transaction.
-// Example:
-//   tx, _ := client.Begin()
-//   defer tx.End()
-//   client.Insert(model)
-//   client.Insert(model)
-//   tx.Commit()
-func (r *Client) Begin() (*Tx, error) {
+// Bind the table to a context with the DefaultTimeout.
+// Returns the function used to cancel the context which
+// must be called when the operation has completed.
+// Must be used for reads only. Not bound (no-op) when
+// the table uses a transaction.
+func (r *Client) timed(table *Table) context.CancelFunc {
+	db, cast := table.DB.(*sql.DB)
+	if r.DefaultTimeout <= 0 || !cast {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.DefaultTimeout)
+	table.DB = &ctxDB{ctx: ctx, db: db}
+
+	return cancel
+}
+
+//
+// Open the read-only (replica) connections.
+func (r *Client) openReplicas(pragmas []string) error {
+	if r.Replicas < 1 {
+		return nil
+	}
+	if r.inMemory() {
+		return liberr.Wrap(ReplicaNotSupportedError)
+	}
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     r.path,
+		RawQuery: "mode=ro",
+	}
+	driver := r.driver(append(pragmas, "PRAGMA query_only = ON"))
+	for i := 0; i < r.Replicas; i++ {
+		db, err := sql.Open(driver, dsn.String())
+		if err != nil {
+			r.closeReplicas()
+			return liberr.Wrap(err)
+		}
+		err = db.Ping()
+		if err != nil {
+			db.Close()
+			r.closeReplicas()
+			return liberr.Wrap(err)
+		}
+		r.replicas = append(r.replicas, db)
+	}
+
+	return nil
+}
+
+//
+// The DB is in-memory.
+func (r *Client) inMemory() bool {
+	return r.path == ":memory:" ||
+		strings.HasPrefix(r.path, "file::memory:") ||
+		strings.Contains(r.path, "mode=memory")
+}
+
+//
+// Close the read-only (replica) connections.
+func (r *Client) closeReplicas() {
+	for _, db := range r.replicas {
+		db.Close()
+	}
+	r.replicas = nil
+}
+
+//
+// Get the connection used for reads.
+// The next replica (round-robin) when opened.
+// Else, the primary connection.
+// Committed state is read. Staged (uncommitted) changes
+// are read using Tx.Get(), Tx.List() and Tx.Count().
+func (r *Client) reader() DBTX {
+	if len(r.replicas) == 0 {
+		return r.db
+	}
+	next := atomic.AddUint64(&r.nextReplica, 1)
+
+	return r.replicas[next%uint64(len(r.replicas))]
+}
+
+//
+// Build the pragma statements.
+// The default (foreign_keys = ON) is applied unless overridden.
+func (r *Client) pragmas() ([]string, error) {
+	statements := []string{}
+	names := []string{}
+	for name, value := range r.Pragmas {
+		if !PragmaRegex.MatchString(name) || !PragmaRegex.MatchString(value) {
+			return nil, liberr.Wrap(PragmaInvalidError)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	overridden := false
+	for _, name := range names {
+		if strings.EqualFold(name, "foreign_keys") {
+			overridden = true
+		}
+		statements = append(
+			statements,
+			fmt.Sprintf("PRAGMA %s = %s", name, r.Pragmas[name]))
+	}
+	if !overridden {
+		statements = append([]string{Pragma}, statements...)
+	}
+
+	return statements, nil
+}
+
+//
+// Register a sqlite3 driver that applies the pragmas
+// to each new connection. Registered once for each
+// distinct set of pragmas.
+// Returns the driver name.
+func (r *Client) driver(pragmas []string) string {
+	drivers.Lock()
+	defer drivers.Unlock()
+	key := strings.Join(pragmas, ";")
+	if name, found := drivers.names[key]; found {
+		return name
+	}
+	name := fmt.Sprintf("sqlite3-%d", len(drivers.names)+1)
+	pragmas = append([]string{}, pragmas...)
+	sql.Register(
+		name,
+		&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, pragma := range pragmas {
+					_, err := conn.Exec(pragma, nil)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	drivers.names[key] = name
+
+	return name
+}
+
+//
+// Get the schema.
+// The table and index DDL for all models, including
+// the Label model.
+func (r *Client) Schema() ([]string, error) {
+	statements := []string{}
+	models := r.models
+	hasLabel := false
+	for _, m := range models {
+		if _, cast := m.(*Label); cast {
+			hasLabel = true
+			break
+		}
+	}
+	if !hasLabel {
+		models = append(models, &Label{})
+	}
+	for _, m := range models {
+		ddl, err := r.table(nil).DDL(m)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		statements = append(statements, ddl...)
+	}
+
+	return statements, nil
+}
+
+//
+// Close the database.
+// A transaction in progress is rolled back (and staged
+// events discarded) unless StrictClose. All watches are ended.
+// Optionally purge (delete) the DB. The DB is closed and the
+// (exclusive) lock released even when closing the connection
+// returns an error.
+func (r *Client) Close(purge bool) (err error) {
+	if r.db == nil {
+		return nil
+	}
+	defer func() {
+		r.notify(Closed, err)
+	}()
+	r.stopSweeper()
+	err = r.rollback()
+	if err != nil {
+		r.startSweeper()
+		return
+	}
+	r.journal.EndAll()
+	r.closeReplicas()
+	err = r.db.Close()
+	r.db = nil
+	r.releaseLock()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	if purge {
+		os.Remove(r.path)
+	}
+
+	return nil
+}
+
+//
+// Rollback a transaction in progress.
+// Returns TxInProgressError when StrictClose.
+func (r *Client) rollback() error {
 	r.Lock()
 	defer r.Unlock()
-	r.dbMutex.Lock()
-	tx, err := r.db.Begin()
+	if r.tx == nil {
+		return nil
+	}
+	if r.StrictClose {
+		return liberr.Wrap(TxInProgressError)
+	}
+	defer func() {
+		r.dbMutex.Unlock()
+		r.txMutex.Unlock()
+		r.tx = nil
+		r.purge = false
+	}()
+	r.journal.Unstage()
+	err := r.tx.Rollback()
 	if err != nil {
-		return nil, err
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Notify the lifecycle hook.
+func (r *Client) notify(action string, err error) {
+	if r.Lifecycle == nil {
+		return
+	}
+	r.Lifecycle(
+		LifecycleEvent{
+			Action: action,
+			Path:   r.path,
+			Error:  err,
+		})
+}
+
+//
+// Backup the database.
+// A consistent (committed) copy of the DB is written to the
+// specified path using `VACUUM INTO`. An existing file at
+// the path is replaced. Waits for an in-progress transaction
+// to be committed or ended.
+func (r *Client) Backup(path string) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.dbMutex.Lock()
+	defer r.dbMutex.Unlock()
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Classify(err)
+	}
+	_, err = r.db.Exec("VACUUM INTO :path", sql.Named("path", path))
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Fork the database.
+// The DB is backed up to the specified path (See: Backup) and
+// an opened client of the copy is returned. The configuration
+// and registered models are copied. The Lifecycle hook is not
+// copied. The journal (and watches) are not shared. The fork
+// journal is enabled when the journal is enabled.
+// Example:
+//   fork, err := client.Fork("/tmp/fixture.db")
+//   if err != nil {
+//       return err
+//   }
+//   defer fork.Close(true)
+func (r *Client) Fork(path string) (*Client, error) {
+	err := r.Backup(path)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	fork := &Client{
+		path:           path,
+		Pragmas:        r.Pragmas,
+		PromotedLabels: r.PromotedLabels,
+		AddColumns:     r.AddColumns,
+		DetectDrift:    r.DetectDrift,
+		Naming:         r.Naming,
+		Namespace:      r.Namespace,
+		StrictClose:    r.StrictClose,
+		Replicas:       r.Replicas,
+		DefaultTimeout: r.DefaultTimeout,
+		CacheSize:      r.CacheSize,
+		DirMode:        r.DirMode,
+		Exclusive:      r.Exclusive,
+		Log:            r.Log,
+		SweepInterval:  r.SweepInterval,
+		ExcludeExpired: r.ExcludeExpired,
+		MaxListRows:    r.MaxListRows,
+		LintIndexes:    r.LintIndexes,
+		GenerateID:     r.GenerateID,
+	}
+	fork.journal.MaxStaged = r.journal.MaxStaged
+	fork.journal.QueueSize = r.journal.QueueSize
+	fork.register(r.models...)
+	err = fork.Open(false)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	if r.journal.Enabled() {
+		fork.journal.Enable()
+	}
+
+	return fork, nil
+}
+
+//
+// DB statistics.
+type Stats struct {
+	// Number of rows by model (table name).
+	Models map[string]int64
+	// Total number of labels.
+	Labels int64
+	// DB file size (bytes).
+	Size int64
+	// WAL file size (bytes).
+	WalSize int64
+	// Result cache hits.
+	CacheHits int64
+	// Result cache misses.
+	CacheMisses int64
+}
+
+//
+// Get DB statistics.
+// The counts are gathered within a single (read) transaction.
+func (r *Client) Stats() (Stats, error) {
+	stats := Stats{Models: map[string]int64{}}
+	if r.db == nil {
+		return stats, liberr.Wrap(NotOpenError)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return stats, Classify(err)
+	}
+	defer tx.Rollback()
+	for _, m := range r.models {
+		name := r.table(nil).Name(m)
+		n := int64(0)
+		err = tx.QueryRow("SELECT COUNT(*) FROM " + name).Scan(&n)
+		if err != nil {
+			return stats, Classify(err)
+		}
+		if _, cast := m.(*Label); cast {
+			stats.Labels = n
+			continue
+		}
+		stats.Models[name] = n
+	}
+	err = tx.Commit()
+	if err != nil {
+		return stats, Classify(err)
+	}
+	for path, size := range map[string]*int64{
+		r.path:          &stats.Size,
+		r.path + "-wal": &stats.WalSize,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return stats, liberr.Wrap(err)
+		}
+		*size = info.Size()
+	}
+	if r.cache != nil {
+		stats.CacheHits, stats.CacheMisses = r.cache.Stats()
+	}
+
+	return stats, nil
+}
+
+//
+// Import models (and labels) from another DB.
+// All models of each specified type are read (with labels) from
+// the `src` DB and inserted within a transaction. Events are
+// journaled. When `upsert` is true, existing models are updated.
+// Else, a ConflictError is returned and nothing is imported.
+func (r *Client) ImportFrom(src DB, upsert bool, models ...interface{}) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	imported := []Model{}
+	for _, m := range models {
+		mt := reflect.TypeOf(m)
+		if mt.Kind() == reflect.Ptr {
+			mt = mt.Elem()
+		}
+		listPtr := reflect.New(reflect.SliceOf(mt))
+		err := src.List(
+			listPtr.Interface(),
+			ListOptions{
+				Detail: DetailLabels,
+			})
+		if err != nil {
+			return Classify(err)
+		}
+		list := listPtr.Elem()
+		for i := 0; i < list.Len(); i++ {
+			model, cast := list.Index(i).Addr().Interface().(Model)
+			if !cast {
+				return liberr.Wrap(MustBeObjectErr)
+			}
+			imported = append(imported, model)
+		}
+	}
+	tx, err := r.Begin()
+	if err != nil {
+		return Classify(err)
+	}
+	defer tx.End()
+	table := r.table(tx.ref)
+	for _, model := range imported {
+		err = table.Get(r.journal.copy(model))
+		switch {
+		case err == nil:
+			if !upsert {
+				return liberr.Wrap(ConflictError)
+			}
+			err = r.Update(model)
+		case errors.Is(err, NotFound):
+			err = r.Insert(model)
+		}
+		if err != nil {
+			return Classify(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+//
+// Reset the database.
+// All tables are dropped and the schema is rebuilt within
+// a transaction. The DB file is not replaced. A Deleted
+// event is journaled for each model.
+func (r *Client) Reset() error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	tx, err := r.Begin()
+	if err != nil {
+		return Classify(err)
+	}
+	defer tx.End()
+	table := r.table(tx.ref)
+	_, err = table.DB.Exec("PRAGMA defer_foreign_keys = ON")
+	if err != nil {
+		return Classify(err)
+	}
+	dropped := map[string]bool{}
+	for i := len(r.models) - 1; i >= 0; i-- {
+		m := r.models[i]
+		name := table.Name(m)
+		if dropped[name] {
+			continue
+		}
+		if _, cast := m.(*Label); !cast {
+			list, err := table.listModels(m, ListOptions{})
+			if err != nil {
+				return Classify(err)
+			}
+			for _, model := range list {
+				r.journal.Deleted(model)
+			}
+		}
+		_, err = table.DB.Exec("DROP TABLE IF EXISTS " + name)
+		if err != nil {
+			return Classify(err)
+		}
+		fts, err := table.FtsTable(m)
+		if err != nil {
+			return Classify(err)
+		}
+		if fts != "" {
+			_, err = table.DB.Exec("DROP TABLE IF EXISTS " + fts)
+			if err != nil {
+				return Classify(err)
+			}
+		}
+		dropped[name] = true
+	}
+	ddl, err := r.Schema()
+	if err != nil {
+		return Classify(err)
+	}
+	for _, stmt := range ddl {
+		_, err = table.DB.Exec(stmt)
+		if err != nil {
+			return Classify(err)
+		}
+	}
+	err = r.promote(table.DB)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return tx.Commit()
+}
+
+//
+// Compact labels.
+// Orphaned labels are deleted. Labels are orphaned when
+// the (Kind, Parent) of a registered model kind does not
+// reference an existing model. Labels of other kinds are
+// retained. Returns the number of labels deleted.
+func (r *Client) CompactLabels() (removed int64, err error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	removed, err = table.CompactLabels(r.models...)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return removed, nil
+}
+
+//
+// Get the model.
+// The latest committed state is read. Staged (uncommitted)
+// changes are read using Tx.Get().
+// See: View() to read a consistent snapshot.
+func (r *Client) Get(model Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	if r.cacheable(table, nil) {
+		return r.cachedGet(table, model)
+	}
+	defer r.timed(&table)()
+	err := table.Get(model)
+	if err != nil {
+		return Classify(err)
+	}
+	if r.ExcludeExpired && r.isExpired(table, model) {
+		return Classify(NotFound)
+	}
+
+	return nil
+}
+
+//
+// Get the model by unique index.
+// The model is matched using the (currently set) values of
+// the fields in the unique(`name`) group rather than the PK.
+// Example:
+//   m := &Person{Email: "elmer@fudd.com"}
+//   err := client.GetByUnique(m, "email")
+func (r *Client) GetByUnique(model Model, name string) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	err := table.GetByUnique(model, name)
+	if err != nil {
+		return Classify(err)
+	}
+	if r.ExcludeExpired && r.isExpired(table, model) {
+		return Classify(NotFound)
+	}
+
+	return nil
+}
+
+//
+// Get the model referenced by a (fk) field.
+// The `related` model is fetched by PK using the value of
+// the named field of the model.
+// Example:
+//   vm := &VM{ID: "1"}
+//   host := &Host{}
+//   err := client.Related(vm, "Host", host)
+func (r *Client) Related(model Model, field string, related Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	table := r.table(nil)
+	fields, err := table.Fields(model)
+	if err != nil {
+		return Classify(err)
+	}
+	var fk *Field
+	for _, f := range fields {
+		if f.Match(field) {
+			fk = f
+			break
+		}
+	}
+	if fk == nil {
+		return liberr.Wrap(FieldRefErr)
+	}
+	relatedFields, err := table.Fields(related)
+	if err != nil {
+		return Classify(err)
+	}
+	pk := table.PkField(relatedFields)
+	if pk == nil {
+		return liberr.Wrap(MustHavePkErr)
+	}
+	v, err := pk.AsValue(fk.Value.Interface())
+	if err != nil {
+		return Classify(err)
+	}
+	pk.Value.Set(reflect.ValueOf(v).Convert(pk.Value.Type()))
+
+	return r.Get(related)
+}
+
+//
+// List the models referencing a (parent) model.
+// Lists the models (of the child kind) with the named (fk)
+// field matching the PK of the parent.
+// The `list` must be: *[]Model.
+// Example:
+//   vms := []VM{}
+//   err := client.RelatedList(host, &VM{}, "Host", &vms)
+func (r *Client) RelatedList(parent Model, child Model, field string, list interface{}) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	fields, err := r.table(nil).Fields(child)
+	if err != nil {
+		return Classify(err)
+	}
+	found := false
+	for _, f := range fields {
+		if f.Match(field) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return liberr.Wrap(FieldRefErr)
+	}
+
+	return r.List(
+		list,
+		ListOptions{
+			Predicate: Eq(field, parent.Pk()),
+		})
+}
+
+//
+// Get the model (cached).
+func (r *Client) cachedGet(table Table, model Model) error {
+	stmt, params, err := table.RenderGet(model)
+	if err != nil {
+		return Classify(err)
+	}
+	key := r.cacheKey(model, stmt, params)
+	generation := r.journal.Generation(model)
+	epoch := r.cache.Epoch()
+	mv := reflect.ValueOf(model).Elem()
+	if cached, hit := r.cache.Get(key, generation); hit {
+		mv.Set(cached)
+		return nil
+	}
+	defer r.timed(&table)()
+	err = table.Get(model)
+	if err != nil {
+		return Classify(err)
+	}
+	r.cache.Put(key, generation, epoch, mv)
+
+	return nil
+}
+
+//
+// Get models by primary key.
+// A single statement is issued (per batch) rather than
+// a Get() per key. Keys not found are absent from the
+// result. Labels are populated in batch for models
+// implementing LabelSetter.
+func (r *Client) GetAll(model Model, keys []interface{}) ([]Model, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	models, err := table.GetAll(model, keys)
+	if err != nil {
+		return nil, Classify(err)
+	}
+	err = table.SetLabels(models)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return models, nil
+}
+
+//
+// Get the model for update.
+// Locks the DB by beginning a transaction.
+// The caller MUST commit/end the returned Tx.
+func (r *Client) GetForUpdate(model Model) (*Tx, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	tx, err := r.Begin()
+	if err != nil {
+		return nil, Classify(err)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	err = table.Get(model)
+	if err != nil {
+		tx.End()
+		return nil, Classify(err)
+	}
+
+	return tx, nil
+}
+
+//
+// Get the models (by primary key) for update.
+// Begins a transaction and fetches the models within it. The
+// keys are de-duplicated and fetched in a deterministic order,
+// sorted by the (typed) PK value, so that goroutines locking
+// overlapping sets always lock in the same order. The models
+// are returned in the same (sorted) order.
+// NotFound is returned (and the transaction ended) when any
+// of the models is not found. The caller MUST commit/end the
+// returned Tx. See ListForUpdate() for caveats.
+// Example:
+//   tx, models, err := client.GetManyForUpdate(&VM{}, []interface{}{"b", "a"})
+//   if err != nil {
+//       return err
+//   }
+//   defer tx.End()
+//   for _, m := range models {
+//       m.(*VM).Stale = true
+//       err = client.Update(m)
+//       if err != nil {
+//           return err
+//       }
+//   }
+//   err = tx.Commit()
+func (r *Client) GetManyForUpdate(model Model, keys []interface{}) (*Tx, []Model, error) {
+	if r.db == nil {
+		return nil, nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(nil)
+	fields, err := table.Fields(model)
+	if err != nil {
+		return nil, nil, Classify(err)
+	}
+	pk := table.PkField(fields)
+	if pk == nil {
+		return nil, nil, liberr.Wrap(MustHavePkErr)
+	}
+	sorted := []interface{}{}
+	seen := map[interface{}]bool{}
+	for _, key := range keys {
+		v, err := pk.AsValue(key)
+		if err != nil {
+			return nil, nil, Classify(err)
+		}
+		if !seen[v] {
+			seen[v] = true
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Slice(
+		sorted,
+		func(i, j int) bool {
+			switch v := sorted[i].(type) {
+			case int64:
+				return v < sorted[j].(int64)
+			case string:
+				return v < sorted[j].(string)
+			}
+			return false
+		})
+	tx, err := r.Begin()
+	if err != nil {
+		return nil, nil, Classify(err)
+	}
+	table.DB = tx.ref
+	found, err := table.GetAll(model, sorted)
+	if err != nil {
+		tx.End()
+		return nil, nil, Classify(err)
+	}
+	if len(found) != len(sorted) {
+		tx.End()
+		return nil, nil, liberr.Wrap(NotFound)
+	}
+	byKey := map[interface{}]Model{}
+	for _, m := range found {
+		mFields, err := table.Fields(m)
+		if err != nil {
+			tx.End()
+			return nil, nil, Classify(err)
+		}
+		byKey[table.PkField(mFields).Pull()] = m
+	}
+	models := []Model{}
+	for _, key := range sorted {
+		models = append(models, byKey[key])
+	}
+	err = table.SetLabels(models)
+	if err != nil {
+		tx.End()
+		return nil, nil, Classify(err)
+	}
+
+	return tx, models, nil
+}
+
+//
+// List models for update.
+// Begins a transaction and lists the models within it. The
+// models may be updated using the client and committed
+// atomically. The caller MUST commit/end the returned Tx.
+// The `list` must be: *[]Model.
+// Caveats (sqlite):
+//   - The whole DB is locked, not the listed rows. Writes by
+//     other clients (connections) are blocked for the life of
+//     the transaction once the first write has been made.
+//   - The transaction is deferred. Other connections may write
+//     between the list and the first write.
+//   - Changes made by the client (all goroutines) are made in
+//     the transaction until it is committed or ended.
+//   - Begin() on the client blocks until the transaction is
+//     committed or ended and will deadlock when called by the
+//     same goroutine.
+// Example:
+//   tx, err := client.ListForUpdate(&vms, ListOptions{})
+//   if err != nil {
+//       return err
+//   }
+//   defer tx.End()
+//   for i := range vms {
+//       vms[i].Stale = true
+//       err = client.Update(&vms[i])
+//       if err != nil {
+//           return err
+//       }
+//   }
+//   err = tx.Commit()
+func (r *Client) ListForUpdate(list interface{}, options ListOptions) (*Tx, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	tx, err := r.Begin()
+	if err != nil {
+		return nil, Classify(err)
+	}
+	options.promoted = r.PromotedLabels
+	err = r.table(tx.ref).List(list, options)
+	if err != nil {
+		tx.End()
+		return nil, Classify(err)
+	}
+
+	return tx, nil
+}
+
+//
+// Update the model (locked).
+// The model is fetched for update, mutated by `fn` and
+// updated within a transaction. The transaction is committed
+// when `fn` and the update succeed. Else, rolled back and
+// the error is returned.
+// Example:
+//   err := client.UpdateLocked(
+//       &Person{ID: 1},
+//       func(m Model) error {
+//           m.(*Person).Age++
+//           return nil
+//       })
+func (r *Client) UpdateLocked(model Model, fn func(Model) error) error {
+	tx, err := r.GetForUpdate(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	defer tx.End()
+	err = fn(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	err = r.Update(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// List models.
+// The latest committed state is read. Staged (uncommitted)
+// changes are read using Tx.List().
+// See: View() to read a consistent snapshot.
+// When MaxListRows is exceeded (no Page specified), the list is
+// truncated and ResultTruncated is returned.
+// The `list` must be: *[]Model.
+func (r *Client) List(list interface{}, options ListOptions) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	options.promoted = r.PromotedLabels
+	if r.ExcludeExpired {
+		lt := reflect.TypeOf(list)
+		if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+			return liberr.Wrap(MustBeSlicePtrErr)
+		}
+		options.Predicate = r.unexpired(
+			reflect.New(lt.Elem().Elem()).Interface(),
+			options.Predicate)
+	}
+	capped := r.MaxListRows > 0 && options.Page == nil
+	if capped {
+		options.Page = &Page{Limit: r.MaxListRows + 1}
+	}
+	table := r.table(r.reader())
+	r.lint(table, list, options)
+	var err error
+	if r.cacheable(table, options.Predicate) {
+		err = r.cachedList(table, list, options)
+	} else {
+		err = r.list(table, list, options)
+	}
+	if err != nil || !capped {
+		return err
+	}
+	lv := reflect.ValueOf(list).Elem()
+	if lv.Len() > r.MaxListRows {
+		lv.Set(lv.Slice(0, r.MaxListRows))
+		return liberr.Wrap(ResultTruncated)
+	}
+
+	return nil
+}
+
+//
+// List models (uncached).
+func (r *Client) list(table Table, list interface{}, options ListOptions) error {
+	defer r.timed(&table)()
+	err := table.List(list, options)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// List models updated (written) since a time.
+// The list is qualified by: updated > `since`. Intended for
+// incremental sync. The model must have an updated (timestamp)
+// field. Deleted models are not listed.
+// The `list` must be: *[]Model.
+// Example:
+//   err := client.ListSince(&persons, lastSync, ListOptions{})
+func (r *Client) ListSince(list interface{}, since time.Time, options ListOptions) error {
+	lt := reflect.TypeOf(list)
+	if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	table := r.table(nil)
+	fields, err := table.Fields(reflect.New(lt.Elem().Elem()).Interface())
+	if err != nil {
+		return Classify(err)
+	}
+	f := table.UpdatedField(fields)
+	if f == nil {
+		return liberr.Wrap(NotUpdatedErr)
+	}
+	updated := Gt(f.Name, since.UnixNano())
+	if options.Predicate == nil {
+		options.Predicate = updated
+	} else {
+		options.Predicate = And(options.Predicate, updated)
+	}
+
+	return r.List(list, options)
+}
+
+//
+// List models (cached).
+func (r *Client) cachedList(table Table, list interface{}, options ListOptions) error {
+	lt := reflect.TypeOf(list)
+	if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	model, cast := reflect.New(lt.Elem().Elem()).Interface().(Model)
+	if !cast {
+		return liberr.Wrap(MustBeObjectErr)
+	}
+	stmt, params, err := table.Render(model, options)
+	if err != nil {
+		return Classify(err)
+	}
+	key := r.cacheKey(model, stmt, append(params, options.Detail))
+	generation := r.journal.Generation(model)
+	epoch := r.cache.Epoch()
+	lv := reflect.ValueOf(list).Elem()
+	if cached, hit := r.cache.Get(key, generation); hit {
+		lv.Set(cached)
+		return nil
+	}
+	defer r.timed(&table)()
+	err = table.List(list, options)
+	if err != nil {
+		return Classify(err)
+	}
+	r.cache.Put(key, generation, epoch, lv)
+
+	return nil
+}
+
+//
+// Get whether reads may be cached.
+// Not cached when staged (Tx), while the journal is
+// suspended or when the predicate references another model.
+func (r *Client) cacheable(table Table, predicate Predicate) bool {
+	if r.cache == nil || r.journal.Suspended() || r.ExcludeExpired {
+		return false
+	}
+	if _, isDB := table.DB.(*sql.DB); !isDB {
+		return false
+	}
+	cacheable := true
+	Walk(
+		predicate,
+		func(p Predicate) Predicate {
+			if _, cast := p.(*SubqueryPredicate); cast {
+				cacheable = false
+			}
+			return p
+		})
+
+	return cacheable
+}
+
+//
+// Build the cache key.
+func (r *Client) cacheKey(model Model, stmt string, params []interface{}) string {
+	return ref.ToKind(model) + "\n" + stmt + "\n" + fmt.Sprintf("%#v", params)
+}
+
+//
+// List models (keyset pagination).
+// The models are sorted by the sort fields and then by PK.
+// Returns the token used to list the next page which is nil
+// after the last page. Unlike offset pagination, rows before
+// the page are not scanned and models inserted or deleted
+// before the page do not cause models to be skipped or listed
+// again. See: Table.ListAfter().
+// The `list` must be: *[]Model.
+// Example:
+//   options := ListOptions{Page: &Page{Limit: 100}}
+//   for {
+//       next, err := client.ListAfter(&vms, options)
+//       if err != nil {
+//           return err
+//       }
+//       ...
+//       if next == nil {
+//           break
+//       }
+//       options.After = next
+//   }
+func (r *Client) ListAfter(list interface{}, options ListOptions) ([]interface{}, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	options.promoted = r.PromotedLabels
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	next, err := table.ListAfter(list, options)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return next, nil
+}
+
+//
+// Find models.
+// List models matching the predicate.
+// The `list` must be: *[]Model.
+func (r *Client) Find(list interface{}, predicate Predicate) error {
+	return r.List(list, ListOptions{Predicate: predicate})
+}
+
+//
+// List model primary keys.
+// Only the PK column is selected which is much cheaper
+// than List() for large tables. The predicate may be nil.
+// Returns (string|int64) keys.
+func (r *Client) ListKeys(model Model, predicate Predicate) ([]interface{}, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	options := ListOptions{
+		Predicate: predicate,
+		promoted:  r.PromotedLabels,
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	keys, err := table.ListKeys(model, options)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return keys, nil
+}
+
+//
+// List models in chunks.
+// The table is paged by PK (keyset) and `fn` is called with
+// each chunk of (at most) `chunkSize` models. Each chunk is
+// read using a separate query so that neither the entire
+// list is loaded nor a cursor is held while `fn` runs. Each
+// model is listed once even when the table is modified by
+// `fn`. Models inserted with a PK greater than the last model
+// listed are included. Sort and pagination are ignored. The
+// error returned by `fn` stops the listing and is returned.
+// Example:
+//   err := client.ListChunked(
+//       &VM{},
+//       ListOptions{},
+//       100,
+//       func(chunk []Model) error {
+//           ...
+//       })
+func (r *Client) ListChunked(model Model, options ListOptions, chunkSize int, fn func([]Model) error) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	if chunkSize < 1 {
+		return liberr.Wrap(ChunkSizeErr)
+	}
+	options.promoted = r.PromotedLabels
+	var after interface{}
+	for {
+		chunk, last, err := r.listChunk(model, options, after, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		err = fn(chunk)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		if len(chunk) < chunkSize {
+			break
+		}
+		after = last
+	}
+
+	return nil
+}
+
+//
+// List a chunk of models.
+func (r *Client) listChunk(model Model, options ListOptions, after interface{}, limit int) ([]Model, interface{}, error) {
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	chunk, last, err := table.ListChunk(model, options, after, limit)
+	if err != nil {
+		return nil, nil, Classify(err)
+	}
+
+	return chunk, last, nil
+}
+
+//
+// Full-text search.
+// The `query` (FTS MATCH syntax) is matched against the
+// fields tagged `fts`. Returns models ranked by the number
+// of matched terms (phrases).
+func (r *Client) Search(model Model, query string) ([]Model, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	list, err := table.Search(model, query)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return list, nil
+}
+
+//
+// Count models.
+func (r *Client) Count(model Model, predicate Predicate) (int64, error) {
+	return r.CountOptions(model, ListOptions{Predicate: predicate})
+}
+
+//
+// Count models qualified by list options.
+// Counts what List() would return without pagination.
+func (r *Client) CountOptions(model Model, options ListOptions) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	options.promoted = r.PromotedLabels
+	if r.ExcludeExpired {
+		options.Predicate = r.unexpired(model, options.Predicate)
+	}
+	table := r.table(r.reader())
+	r.lint(table, model, options)
+	defer r.timed(&table)()
+	n, err := table.CountOptions(model, options)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return n, nil
+}
+
+//
+// Lint the query plan (development).
+// A suggestion is logged when a predicate-filtered query
+// of the model is satisfied by a full table scan. The
+// `model` may be a model or list (pointer).
+func (r *Client) lint(table Table, model interface{}, options ListOptions) {
+	if !r.LintIndexes || options.Predicate == nil {
+		return
+	}
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr && mt.Elem().Kind() == reflect.Slice {
+		model = reflect.New(mt.Elem().Elem()).Interface()
+	}
+	options.Page = nil
+	options.Sort = nil
+	options.After = nil
+	stmt, params, err := table.Render(model, options)
+	if err != nil {
+		return
+	}
+	plan, err := table.Explain(stmt, params...)
+	if err != nil {
+		r.logger().Error(err, "Explain query plan failed.")
+		return
+	}
+	name := table.Name(model)
+	for _, detail := range plan {
+		m := ScanRegex.FindStringSubmatch(detail)
+		if m == nil || m[2] != name || strings.Contains(detail, "INDEX") {
+			continue
+		}
+		fields, err := table.Fields(model)
+		if err != nil {
+			return
+		}
+		suggested := []string{}
+		expr := options.Predicate.Expr()
+		for _, f := range table.StoredFields(fields) {
+			if f.Pk() || f.Computed != "" {
+				continue
+			}
+			matched, _ := regexp.MatchString(
+				`\b`+regexp.QuoteMeta(f.Name)+`\b`,
+				expr)
+			if matched {
+				suggested = append(suggested, f.Name)
+			}
+		}
+		r.logger().Info(
+			"Full table scan, consider an index.",
+			"kind",
+			name,
+			"fields",
+			suggested,
+			"plan",
+			detail)
+	}
+}
+
+//
+// Approximate count of ALL models.
+// NOT exact. Intended for large tables when an exact
+// count is too slow. See: Table.ApproxCount().
+func (r *Client) ApproxCount(model Model) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	n, err := table.ApproxCount(model)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return n, nil
+}
+
+//
+// Count labels by name.
+// Returns the number of models (of the kind) having each label.
+func (r *Client) LabelCounts(model Model) (map[string]int64, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	counts, err := table.LabelCounts(model)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return counts, nil
+}
+
+//
+// Count label values.
+// Returns the number of models (of the kind) having each
+// value of the named label.
+func (r *Client) LabelValueCounts(model Model, name string) (map[string]int64, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	counts, err := table.LabelValueCounts(model, name)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return counts, nil
+}
+
+//
+// List distinct label names (keys).
+// Optionally filtered by model (kind). When no models
+// are specified, the names used by all kinds are listed.
+func (r *Client) LabelKeys(models ...Model) ([]string, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	kinds := []interface{}{}
+	for _, m := range models {
+		kinds = append(kinds, m)
+	}
+	if len(kinds) == 0 && r.Namespace != "" {
+		for _, m := range r.models {
+			if _, cast := m.(*Label); !cast {
+				kinds = append(kinds, m)
+			}
+		}
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	keys, err := table.LabelKeys(kinds...)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return keys, nil
+}
+
+//
+// Begin a transaction.
+// Blocks until a transaction in progress (begun by another
+// goroutine) is committed or ended.
+// Example:
+//   tx, _ := client.Begin()
+//   defer tx.End()
+//   client.Insert(model)
+//   client.Insert(model)
+//   tx.Commit()
+func (r *Client) Begin() (*Tx, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	r.txMutex.Lock()
+	return r.begin(TxOptions{})
+}
+
+//
+// Begin a transaction with options.
+// Example (deferred foreign keys):
+//   tx, _ := client.BeginWith(TxOptions{DeferForeignKeys: true})
+//   defer tx.End()
+//   client.Insert(child)
+//   client.Insert(parent)
+//   tx.Commit()
+func (r *Client) BeginWith(options TxOptions) (*Tx, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	r.txMutex.Lock()
+	return r.begin(options)
+}
+
+//
+// Begin a transaction.
+// The txMutex must be held.
+func (r *Client) begin(options TxOptions) (*Tx, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.dbMutex.Lock()
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.dbMutex.Unlock()
+		r.txMutex.Unlock()
+		return nil, Classify(err)
+	}
+	if options.DeferForeignKeys {
+		_, err = tx.Exec("PRAGMA defer_foreign_keys = ON")
+		if err != nil {
+			_ = tx.Rollback()
+			r.dbMutex.Unlock()
+			r.txMutex.Unlock()
+			return nil, Classify(err)
+		}
+	}
+	r.tx = tx
+	return &Tx{client: r, ref: tx, options: options}, nil
+}
+
+//
+// Insert the model.
+// The conflict strategy is not applied. A conflict with an
+// existing model (PK) is resolved by updating the model and
+// a Created event is journaled. See: InsertWith().
+func (r *Client) Insert(model Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	err := table.Insert(model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = r.insertLabels(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	r.journal.Created(model)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Insert the model when absent.
+// Nothing is done when the model exists.
+// Returns whether the model was inserted.
+func (r *Client) InsertIfAbsent(model Model) (inserted bool, err error) {
+	if r.db == nil {
+		return false, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	inserted, err = table.InsertIfAbsent(model)
+	if err != nil || !inserted {
+		err = Classify(err)
+		return
+	}
+	err = r.insertLabels(table, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	r.journal.Created(model)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return true, nil
+}
+
+//
+// Insert the model with options.
+// The conflict strategy determines how an insert that conflicts
+// with an existing model is resolved:
+//   OnConflictError: ConflictError is returned (default).
+//   OnConflictIgnore: nothing is done and no event is journaled.
+//   OnConflictReplace: the model is overwritten and an Updated
+//     event is journaled.
+// Example:
+//   err := client.InsertWith(
+//       person,
+//       model.InsertOptions{OnConflict: model.OnConflictReplace})
+func (r *Client) InsertWith(model Model, options InsertOptions) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	err := r.insert(table, model, options)
+	if err != nil {
+		return Classify(err)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Insert models with options.
+// See: InsertWith(). The events are delivered as a single
+// BulkEvent to handlers that opt-in. Outside of a transaction,
+// models inserted before an error are not rolled back and
+// their events are committed.
+func (r *Client) InsertAll(models []Model, options InsertOptions) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	r.journal.beginBulk()
+	defer r.journal.endBulk()
+	for _, model := range models {
+		err := r.insert(table, model, options)
+		if err != nil {
+			if r.tx == nil {
+				r.journal.Commit()
+			}
+			return Classify(err)
+		}
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Insert the model (and labels) using the conflict strategy.
+// The stored model is fetched when replaced so that either a
+// Created or Updated event is journaled.
+func (r *Client) insert(table Table, model Model, options InsertOptions) error {
+	var current Model
+	if options.OnConflict == OnConflictReplace {
+		stored, err := r.stored(table, model)
+		switch {
+		case err == nil:
+			current = stored
+		case !errors.Is(err, NotFound):
+			return liberr.Wrap(err)
+		}
+	}
+	written, err := table.InsertWith(model, options.OnConflict)
+	if err != nil || !written {
+		return liberr.Wrap(err)
+	}
+	if current != nil {
+		labels, err := r.replaceLabels(table, model)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		r.journal.Updated(current, model, labels)
+		return nil
+	}
+	err = r.insertLabels(table, model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	r.journal.Created(model)
+
+	return nil
+}
+
+//
+// Update the model.
+func (r *Client) Update(model Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.stored(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = r.update(table, current, model)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Update the model when changed.
+// The model (including labels) is compared with the stored
+// model. The update is skipped and no event is journaled
+// when nothing has changed. Returns whether the model was
+// changed (updated).
+func (r *Client) UpdateChanged(model Model) (changed bool, err error) {
+	if r.db == nil {
+		return false, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	fields, err := table.Fields(model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	table.SetPk(fields)
+	current, err := r.stored(table, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	diff, err := Diff(current, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	changed = len(diff) > 0
+	if !changed {
+		changed, err = r.labelsChanged(table, model)
+		if err != nil || !changed {
+			err = Classify(err)
+			return
+		}
+	}
+	err = r.update(table, current, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+
+	return true, nil
+}
+
+//
+// Get the stored model (pre-update state).
+// The model is copied and fetched. The persisted labels are
+// populated for models implementing LabelSetter so the labels
+// reported in Updated events reflect the stored state rather
+// than the labels of the model passed to update.
+func (r *Client) stored(table Table, model Model) (Model, error) {
+	stored := r.journal.copy(model)
+	err := table.Get(stored)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	err = table.SetLabels([]Model{stored})
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return stored, nil
+}
+
+//
+// Update the model and labels.
+// The `current` is the stored model.
+func (r *Client) update(table Table, current, model Model) error {
+	err := table.Update(model)
+	if err != nil {
+		if errors.Is(err, NotFound) {
+			err = StaleError
+		}
+		return liberr.Wrap(err)
+	}
+	labels, err := r.replaceLabels(table, model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	r.journal.Updated(current, model, labels)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Update the named fields of the model.
+// Other fields and labels are not changed.
+func (r *Client) UpdateFields(model Model, fields ...string) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.stored(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = table.UpdateFields(model, fields...)
+	if err != nil {
+		if errors.Is(err, NotFound) {
+			err = StaleError
+		}
+		return Classify(err)
+	}
+	updated := r.journal.copy(current)
+	err = table.Get(updated)
+	if err != nil {
+		return Classify(err)
+	}
+	r.journal.Updated(current, updated, nil)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Touch the model.
+// Only the updated (timestamp) field (when declared) is set
+// to the current time and an Updated event is journaled even
+// though no other fields are changed. Used to signal that the
+// model has been (re)processed. Returns NotFound when the model
+// does not exist.
+func (r *Client) Touch(model Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.stored(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = table.Touch(model)
+	if err != nil {
+		if errors.Is(err, NotFound) {
+			err = StaleError
+		}
+		return Classify(err)
+	}
+	updated := r.journal.copy(current)
+	err = table.Get(updated)
+	if err != nil {
+		return Classify(err)
+	}
+	r.journal.Updated(current, updated, nil)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Update the named fields of ALL models matching the predicate.
+// The `set` is a map of: field name => value. The predicate
+// may be nil. An Updated event is journaled for each model
+// and delivered as a single BulkEvent to handlers that opt-in.
+// Returns the number of models updated.
+// Example:
+//   n, err := client.UpdateAll(
+//       &Person{},
+//       map[string]interface{}{"Stale": true},
+//       model.Eq("Kind", "VM"))
+func (r *Client) UpdateAll(model Model, set map[string]interface{}, predicate Predicate) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := table.listModels(
+		model,
+		ListOptions{
+			Predicate: predicate,
+			promoted:  r.PromotedLabels,
+		})
+	if err != nil {
+		return 0, Classify(err)
+	}
+	n, err := table.UpdateAll(model, set, predicate)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	r.journal.beginBulk()
+	defer r.journal.endBulk()
+	for _, m := range current {
+		updated := r.journal.copy(m)
+		err = table.Get(updated)
+		if err != nil {
+			return 0, Classify(err)
+		}
+		r.journal.Updated(m, updated, nil)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return n, nil
+}
+
+//
+// Increment the named (int) field of the model.
+// The field is atomically incremented by `delta` in the DB.
+// Returns the new value which is also set in the model.
+func (r *Client) Increment(model Model, field string, delta int64) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.stored(table, model)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	n, err := table.Increment(model, field, delta)
+	if err != nil {
+		if errors.Is(err, NotFound) {
+			err = StaleError
+		}
+		return 0, Classify(err)
+	}
+	updated := r.journal.copy(current)
+	err = table.Get(updated)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	r.journal.Updated(current, updated, nil)
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return n, nil
+}
+
+//
+// Delete the model.
+func (r *Client) Delete(model Model) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.deleting(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = table.Delete(model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = r.deleteLabels(table, model)
+	if err != nil {
+		return Classify(err)
+	}
+	err = r.cascade(table, current)
+	if err != nil {
+		return Classify(err)
+	}
+	if current != nil {
+		r.journal.Deleted(current)
+	} else {
+		r.journal.Deleted(model)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return nil
+}
+
+//
+// Delete models by primary key.
+// Mirrors GetAll(): keys are deleted in batches using a single
+// statement per batch and keys not found are ignored (not
+// counted). Labels are deleted in batches. A Deleted event is
+// journaled for each model and delivered as a single BulkEvent
+// to handlers that opt-in. Returns the number of models deleted.
+func (r *Client) DeleteKeys(model Model, keys []interface{}) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := table.GetAll(model, keys)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	n, err := table.DeleteKeys(model, keys)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	err = r.deleteLabelsOf(table, current)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	r.journal.beginBulk()
+	defer r.journal.endBulk()
+	for _, m := range current {
+		err = r.cascade(table, m)
+		if err != nil {
+			return 0, Classify(err)
+		}
+		r.journal.Deleted(m)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return n, nil
+}
+
+//
+// Delete the model when the predicate matches.
+// The model is deleted by PK only when it also matches
+// the predicate. Returns whether the model was deleted.
+func (r *Client) DeleteIf(model Model, predicate Predicate) (deleted bool, err error) {
+	if r.db == nil {
+		return false, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := r.deleting(table, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	deleted, err = table.DeleteIf(model, predicate)
+	if err != nil || !deleted {
+		err = Classify(err)
+		return
+	}
+	err = r.deleteLabels(table, model)
+	if err != nil {
+		return false, Classify(err)
+	}
+	err = r.cascade(table, current)
+	if err != nil {
+		return false, Classify(err)
+	}
+	if current != nil {
+		r.journal.Deleted(current)
+	} else {
+		r.journal.Deleted(model)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return true, nil
+}
+
+//
+// Delete ALL models matching the predicate.
+// The predicate may be nil. A Deleted event is journaled for
+// each model and delivered as a single BulkEvent to handlers
+// that opt-in. Returns the number of models deleted.
+func (r *Client) DeleteAll(model Model, predicate Predicate) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	return r.deleteAll(model, predicate)
+}
+
+//
+// Delete ALL models matching the predicate.
+// The client must be locked.
+func (r *Client) deleteAll(model Model, predicate Predicate) (int64, error) {
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+	current, err := table.listModels(
+		model,
+		ListOptions{
+			Predicate: predicate,
+			promoted:  r.PromotedLabels,
+		})
+	if err != nil {
+		return 0, Classify(err)
+	}
+	r.journal.beginBulk()
+	defer r.journal.endBulk()
+	for _, m := range current {
+		err = table.Delete(m)
+		if err != nil {
+			return 0, Classify(err)
+		}
+		err = r.deleteLabels(table, m)
+		if err != nil {
+			return 0, Classify(err)
+		}
+		err = r.cascade(table, m)
+		if err != nil {
+			return 0, Classify(err)
+		}
+		r.journal.Deleted(m)
+	}
+	if r.tx == nil {
+		r.journal.Commit()
+	}
+
+	return int64(len(current)), nil
+}
+
+//
+// Delete (sweep) expired models.
+// Models with an `expires` field (unix seconds) that is not
+// zero and not after the current time are deleted. A Deleted
+// event is journaled for each model (See: DeleteAll()).
+// Returns the number of models deleted.
+func (r *Client) Sweep(model Model) (int64, error) {
+	predicate, err := r.expired(model)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return r.DeleteAll(model, predicate)
+}
+
+//
+// Build the predicate matching expired models.
+// Returns NotExpiringErr when the model has no `expires` field.
+func (r *Client) expired(model interface{}) (Predicate, error) {
+	fields, err := r.table(nil).Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	f := r.table(nil).ExpiresField(fields)
+	if f == nil {
+		return nil, liberr.Wrap(NotExpiringErr)
+	}
+	now := time.Now().Unix()
+	predicate := And(
+		Neq(f.Name, 0),
+		Lt(f.Name, now+1))
+
+	return predicate, nil
+}
+
+//
+// Qualify the predicate to exclude expired models.
+// The predicate (may be nil) is returned unchanged when
+// the model has no `expires` field.
+func (r *Client) unexpired(model interface{}, predicate Predicate) Predicate {
+	fields, err := r.table(nil).Fields(model)
+	if err != nil {
+		return predicate
+	}
+	f := r.table(nil).ExpiresField(fields)
+	if f == nil {
+		return predicate
+	}
+	now := time.Now().Unix()
+	unexpired := Or(
+		Eq(f.Name, 0),
+		Gt(f.Name, now))
+	if predicate == nil {
+		return unexpired
+	}
+
+	return And(predicate, unexpired)
+}
+
+//
+// Get whether the (fetched) model has expired.
+func (r *Client) isExpired(table Table, model Model) bool {
+	fields, err := table.Fields(model)
+	if err != nil {
+		return false
+	}
+	f := table.ExpiresField(fields)
+	if f == nil {
+		return false
+	}
+	expires, _ := f.Pull().(int64)
+
+	return expires != 0 && expires <= time.Now().Unix()
+}
+
+//
+// Start the (background) sweeper.
+// Expired models (of each kind with an `expires` field) are
+// swept every SweepInterval. Each sweep is performed on the
+// DB (outside of a transaction) while the client is locked and
+// skipped when a transaction is in progress so that swept
+// models are not joined to (or rolled back by) the transaction
+// of another goroutine. Errors are logged.
+func (r *Client) startSweeper() {
+	if r.SweepInterval <= 0 {
+		return
+	}
+	models := []Model{}
+	for _, m := range r.models {
+		if model, cast := m.(Model); cast {
+			if _, err := r.expired(model); err == nil {
+				models = append(models, model)
+			}
+		}
+	}
+	if len(models) == 0 {
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.sweeperStop = stop
+	r.sweeperDone = done
+	sweep := func(model Model) error {
+		r.Lock()
+		defer r.Unlock()
+		if r.tx != nil {
+			return nil
+		}
+		predicate, err := r.expired(model)
+		if err != nil {
+			return err
+		}
+		_, err = r.deleteAll(model, predicate)
+		return err
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, model := range models {
+					err := sweep(model)
+					if err != nil {
+						r.logger().Error(err, "Sweep failed.", "kind", ref.ToKind(model))
+					}
+				}
+			}
+		}
+	}()
+}
+
+//
+// Stop the (background) sweeper.
+// Waits for a sweep in progress.
+func (r *Client) stopSweeper() {
+	if r.sweeperStop == nil {
+		return
+	}
+	close(r.sweeperStop)
+	<-r.sweeperDone
+	r.sweeperStop = nil
+	r.sweeperDone = nil
+}
+
+//
+// Reconcile result.
+// The number of models changed by Reconcile().
+type ReconcileResult struct {
+	// Models inserted.
+	Created int64
+	// Models updated.
+	Updated int64
+	// Models deleted.
+	Deleted int64
+	// Models (desired) not changed.
+	Unchanged int64
+}
+
+//
+// Reconcile the models matching the predicate with the
+// desired models. Within a transaction, the stored models
+// in scope (predicate) are compared with the desired models
+// by PK. Models in scope not desired are deleted, desired
+// models not stored are inserted and changed models (including
+// labels) are updated. Desired models stored but not in scope
+// are updated (as changed). Unchanged models are not updated
+// (See: Diff). The predicate may be nil (all models). Events
+// are journaled.
+// Example:
+//   result, err := client.Reconcile(
+//       &VM{},
+//       desired,
+//       model.Eq("Cluster", cluster))
+func (r *Client) Reconcile(model Model, desired []Model, scope Predicate) (result ReconcileResult, err error) {
+	if r.db == nil {
+		err = liberr.Wrap(NotOpenError)
+		return
+	}
+	tx, err := r.Begin()
+	if err != nil {
+		err = Classify(err)
+		return
+	}
+	defer tx.End()
+	defer func() {
+		if err != nil {
+			result = ReconcileResult{}
+		}
+	}()
+	table := r.table(tx.ref)
+	current, err := table.listModels(
+		model,
+		ListOptions{
+			Predicate: scope,
+			promoted:  r.PromotedLabels,
+		})
+	if err != nil {
+		err = Classify(err)
+		return
+	}
+	wanted := map[string]bool{}
+	keys := []interface{}{}
+	for _, m := range desired {
+		fields, fErr := table.Fields(m)
+		if fErr != nil {
+			err = Classify(fErr)
+			return
+		}
+		table.SetPk(fields)
+		pk := table.PkField(fields)
+		if pk == nil {
+			err = liberr.Wrap(MustHavePkErr)
+			return
+		}
+		wanted[m.Pk()] = true
+		keys = append(keys, pk.Pull())
+	}
+	found, err := table.GetAll(model, keys)
+	if err != nil {
+		err = Classify(err)
+		return
+	}
+	stored := map[string]bool{}
+	for _, m := range found {
+		stored[m.Pk()] = true
+	}
+	for _, m := range current {
+		if wanted[m.Pk()] {
+			continue
+		}
+		err = r.Delete(m)
+		if err != nil {
+			return
+		}
+		result.Deleted++
+	}
+	for _, m := range desired {
+		if !stored[m.Pk()] {
+			err = r.Insert(m)
+			if err != nil {
+				return
+			}
+			result.Created++
+			continue
+		}
+		changed, uErr := r.UpdateChanged(m)
+		if uErr != nil {
+			err = uErr
+			return
+		}
+		if changed {
+			result.Updated++
+		} else {
+			result.Unchanged++
+		}
+	}
+	err = tx.Commit()
+
+	return
+}
+
+//
+// Query models using raw SQL.
+// The result columns are matched to the fields of the
+// specified model by name.
+// Callers own SQL-injection safety and MUST bind values
+// using placeholders and `args`.
+// Example:
+//   list, err := client.Query(
+//       &Person{},
+//       "SELECT * FROM Person WHERE Age > ? ORDER BY Age",
+//       17)
+func (r *Client) Query(model Model, stmt string, args ...interface{}) ([]Model, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.db)
+	defer r.timed(&table)()
+	list, err := table.Query(model, stmt, args...)
+	if err != nil {
+		return nil, Classify(err)
+	}
+
+	return list, nil
+}
+
+//
+// Query using raw SQL into an arbitrary struct list.
+// Intended for reports (joins, aggregates) that do not map
+// to a registered model. The result columns are matched to
+// the exported fields of the struct by name.
+// Callers own SQL-injection safety and MUST bind values
+// using placeholders and `args`.
+// Example:
+//   type AgeCount struct {
+//       Age   int
+//       Count int64
+//   }
+//   list := []AgeCount{}
+//   err := client.QueryInto(
+//       &list,
+//       "SELECT Age, COUNT(*) AS Count FROM Person GROUP BY Age")
+func (r *Client) QueryInto(list interface{}, stmt string, args ...interface{}) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	table := r.table(r.db)
+	defer r.timed(&table)()
+	err := table.QueryInto(list, stmt, args...)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// List the named fields of models into a list of (DTO) structs.
+// The `dest` must be a pointer to a slice of structs. Only the
+// named fields are selected and copied to the struct fields
+// with the same name. See: Table.ListProjection().
+// Example:
+//   type PersonName struct {
+//       First string
+//       Last  string
+//   }
+//   list := []PersonName{}
+//   err := client.ListProjection(
+//       &list,
+//       &Person{},
+//       []string{"First", "Last"},
+//       ListOptions{Predicate: Gt("Age", 17)})
+func (r *Client) ListProjection(dest interface{}, model Model, fields []string, options ListOptions) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	options.promoted = r.PromotedLabels
+	if r.ExcludeExpired {
+		options.Predicate = r.unexpired(model, options.Predicate)
+	}
+	table := r.table(r.reader())
+	defer r.timed(&table)()
+	r.lint(table, model, options)
+	err := table.ListProjection(dest, model, fields, options)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Execute raw SQL.
+// Returns the number of rows affected.
+// Callers own SQL-injection safety and MUST bind values
+// using placeholders and `args`.
+// Changes are not journaled.
+func (r *Client) Exec(stmt string, args ...interface{}) (int64, error) {
+	if r.db == nil {
+		return 0, liberr.Wrap(NotOpenError)
+	}
+	r.Lock()
+	defer r.Unlock()
+	table := r.table(nil)
+	if r.tx == nil {
+		r.dbMutex.Lock()
+		defer r.dbMutex.Unlock()
+		table.DB = r.db
+	} else {
+		table.DB = r.tx
+	}
+
+	n, err := table.Exec(stmt, args...)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	if r.cache != nil {
+		if r.tx == nil {
+			r.cache.Purge()
+		} else {
+			r.purge = true
+		}
+	}
+
+	return n, nil
+}
+
+//
+// Watch model events.
+func (r *Client) Watch(model Model, handler EventHandler) (*Watch, error) {
+	return r.watch(context.Background(), model, handler, WatchOptions{})
+}
+
+//
+// Watch model events with options.
+// Example (multiple kinds):
+//   watch, err := client.WatchWith(
+//       &VM{},
+//       handler,
+//       WatchOptions{
+//           Kinds: []Model{&Network{}},
+//       })
+// Example (filter):
+//   watch, err := client.WatchWith(
+//       &Person{},
+//       handler,
+//       WatchOptions{
+//           Filter: func(m Model) bool {
+//               return m.(*Person).Retired()
+//           },
+//       })
+// Example (snapshot each minute when changed):
+//   watch, err := client.WatchWith(
+//       &Person{},
+//       snapshotHandler,
+//       WatchOptions{
+//           Interval:     time.Minute,
+//           SnapshotOnly: true,
+//       })
+func (r *Client) WatchWith(model Model, handler EventHandler, options WatchOptions) (*Watch, error) {
+	return r.watch(context.Background(), model, handler, options)
+}
+
+//
+// Watch model events.
+// The initial replay of the current state is aborted when
+// the context is done. The watch is then ended (unregistered)
+// without any events delivered to the handler and the
+// context error is returned.
+func (r *Client) WatchContext(ctx context.Context, model Model, handler EventHandler) (*Watch, error) {
+	return r.watch(ctx, model, handler, WatchOptions{})
+}
+
+//
+// Watch model events.
+// The watch is registered, the current state replayed and
+// the watch started.
+func (r *Client) watch(ctx context.Context, model Model, handler EventHandler, options WatchOptions) (*Watch, error) {
+	if r.db == nil {
+		return nil, liberr.Wrap(NotOpenError)
+	}
+	if options.Interval > 0 || options.SnapshotOnly {
+		if _, optIn := handler.(SnapshotEventHandler); !optIn {
+			return nil, liberr.Wrap(SnapshotHandlerError)
+		}
+	}
+	r.Lock()
+	defer r.Unlock()
+	watch, err := r.journal.Watch(model, handler)
+	if err != nil {
+		return nil, Classify(err)
+	}
+	watch.Filter = options.Filter
+	watch.Kinds = options.Kinds
+	watch.snapshotOnly = options.SnapshotOnly
+	generation := r.watchGeneration(watch)
+	err = r.replay(ctx, watch, false)
+	if err != nil {
+		r.journal.End(watch)
+		return nil, Classify(err)
+	}
+	watch.resync = func() error {
+		r.Lock()
+		defer r.Unlock()
+		if r.db == nil {
+			return liberr.Wrap(NotOpenError)
+		}
+		return r.replay(context.Background(), watch, true)
+	}
+
+	watch.Start()
+	if options.Interval > 0 {
+		go r.poll(watch, options.Interval, generation)
+	}
+
+	return watch, nil
+}
+
+//
+// Deliver a snapshot of the current state each interval.
+// The snapshot is skipped when the generation of the watched
+// kinds has not changed since the last snapshot. Runs until
+// the watch is ended or the DB is closed.
+func (r *Client) poll(watch *Watch, interval time.Duration, last uint64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-watch.done:
+			return
+		case <-ticker.C:
+		}
+		r.Lock()
+		if r.db == nil {
+			r.Unlock()
+			return
+		}
+		generation := r.watchGeneration(watch)
+		if generation == last {
+			r.Unlock()
+			continue
+		}
+		err := r.replay(context.Background(), watch, true)
+		r.Unlock()
+		if err != nil {
+			watch.Handler.Error(err)
+			continue
+		}
+		last = generation
+	}
+}
+
+//
+// The (combined) generation of the watched kinds.
+func (r *Client) watchGeneration(watch *Watch) uint64 {
+	n := uint64(0)
+	for _, m := range watch.models() {
+		n += r.journal.Generation(m)
+	}
+
+	return n
+}
+
+//
+// Replay the current state.
+// A Created event is queued to the watch for each model
+// of each watched kind. A single snapshot is queued to
+// handlers that opt-in. Aborted when the context is done.
+func (r *Client) replay(ctx context.Context, watch *Watch, resync bool) error {
+	db := &ctxDB{ctx: ctx, db: r.db}
+	_, snapshot := watch.Handler.(SnapshotEventHandler)
+	replayed := []Model{}
+	for _, model := range watch.models() {
+		list, err := r.table(db).listModels(model, ListOptions{})
+		if err != nil {
+			return Classify(err)
+		}
+		if snapshot {
+			replayed = append(replayed, list...)
+			continue
+		}
+		for _, m := range list {
+			err = ctx.Err()
+			if err != nil {
+				return liberr.Wrap(err)
+			}
+			watch.notify(
+				&Event{
+					Model:  m,
+					Action: Created,
+					Resync: resync,
+				})
+		}
+	}
+	if snapshot {
+		err := ctx.Err()
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		watch.notifySnapshot(replayed)
 	}
-	r.tx = tx
-	return &Tx{client: r, ref: tx}, nil
+
+	return nil
 }
 
 //
-// Insert the model.
-func (r *Client) Insert(model Model) error {
-	r.Lock()
-	defer r.Unlock()
-	table := Table{}
-	if r.tx == nil {
-		r.dbMutex.Lock()
-		defer r.dbMutex.Unlock()
-		table.DB = r.db
-	} else {
-		table.DB = r.tx
-	}
-	err := table.Insert(model)
+// The associated journal.
+func (r *Client) Journal() *Journal {
+	return &r.journal
+}
+
+//
+// Run `fn` with the journal suspended.
+// Intended for bulk loading. Changes made while suspended are
+// not journaled. The generation of each model (kind) is
+// incremented once afterwards. Fails (JournalWatchedError)
+// when watches are active.
+func (r *Client) WithoutJournal(fn func() error) error {
+	err := r.journal.Suspend()
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	err = r.insertLabels(table, model)
+	defer func() {
+		models := []interface{}{}
+		for _, m := range r.models {
+			if _, cast := m.(*Label); !cast {
+				models = append(models, m)
+			}
+		}
+		r.journal.Resume(models...)
+	}()
+	err = fn()
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	r.journal.Created(model)
-	if r.tx == nil {
-		r.journal.Commit()
-	}
 
 	return nil
 }
 
 //
-// Update the model.
-func (r *Client) Update(model Model) error {
-	r.Lock()
-	defer r.Unlock()
-	table := Table{}
-	if r.tx == nil {
-		r.dbMutex.Lock()
-		defer r.dbMutex.Unlock()
-		table.DB = r.db
-	} else {
-		table.DB = r.tx
-	}
-	current := r.journal.copy(model)
-	err := table.Get(current)
+// Get the generation of the model (kind).
+// Incremented on each committed create, update and
+// delete of the kind. Callers may compare generations to
+// determine whether models of the kind have changed.
+func (r *Client) Generation(model Model) uint64 {
+	return r.journal.Generation(model)
+}
+
+//
+// Insert labels for the model into the DB.
+func (r *Client) insertLabels(table Table, model Model) error {
+	labels, err := r.labels(table, model)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	err = table.Update(model)
-	if err != nil {
-		return liberr.Wrap(err)
+	for _, label := range labels {
+		err := table.Insert(label)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
 	}
-	err = r.replaceLabels(table, model)
+	err = r.setPromoted(table, model, labels)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	r.journal.Updated(current, model)
-	if r.tx == nil {
-		r.journal.Commit()
-	}
 
 	return nil
 }
 
 //
-// Delete the model.
-func (r *Client) Delete(model Model) error {
-	r.Lock()
-	defer r.Unlock()
-	table := Table{}
-	if r.tx == nil {
-		r.dbMutex.Lock()
-		defer r.dbMutex.Unlock()
-		table.DB = r.db
-	} else {
-		table.DB = r.tx
+// Build the label models for the model.
+// Includes both (string) labels and typed labels.
+// A typed label supersedes a (string) label with the same name.
+func (r *Client) labels(table Table, model Model) ([]*Label, error) {
+	list := []*Label{}
+	kind := table.Kind(model)
+	typed := TypedLabels{}
+	if m, cast := model.(TypedLabeled); cast {
+		typed = m.TypedLabels()
+	}
+	for l, v := range model.Labels() {
+		if _, found := typed[l]; found {
+			continue
+		}
+		list = append(
+			list,
+			&Label{
+				Parent: model.Pk(),
+				Kind:   kind,
+				Name:   l,
+				Value:  v,
+				Type:   LabelString,
+			})
+	}
+	for l, v := range typed {
+		label := &Label{
+			Parent: model.Pk(),
+			Kind:   kind,
+			Name:   l,
+		}
+		err := label.SetValue(v)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		list = append(list, label)
+	}
+
+	return list, nil
+}
+
+//
+// Delete labels for the models in the DB.
+// The labels are deleted in batches.
+func (r *Client) deleteLabelsOf(table Table, models []Model) error {
+	if len(models) == 0 {
+		return nil
 	}
-	err := table.Delete(model)
+	parents := []interface{}{}
+	for _, m := range models {
+		parents = append(parents, m.Pk())
+	}
+	_, err := table.deleteIn(
+		&Label{},
+		"Parent",
+		parents,
+		Eq("Kind", table.Kind(models[0])))
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	err = r.deleteLabels(table, model)
+
+	return nil
+}
+
+//
+// Delete labels for a model in the DB.
+func (r *Client) deleteLabels(table Table, model Model) error {
+	list := []Label{}
+	err := table.List(
+		&list,
+		ListOptions{
+			Predicate: And(
+				Eq("Kind", table.Kind(model)),
+				Eq("Parent", model.Pk())),
+		})
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	r.journal.Deleted(model)
-	if r.tx == nil {
-		r.journal.Commit()
+	for _, label := range list {
+		err := table.Delete(&label)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
 	}
 
 	return nil
 }
 
 //
-// Watch model events.
-func (r *Client) Watch(model Model, handler EventHandler) (*Watch, error) {
-	r.Lock()
-	defer r.Unlock()
-	mt := reflect.TypeOf(model)
-	switch mt.Kind() {
-	case reflect.Ptr:
-		mt = mt.Elem()
-	}
-	watch, err := r.journal.Watch(model, handler)
-	if err != nil {
-		return nil, liberr.Wrap(err)
-	}
-	listPtr := reflect.New(reflect.SliceOf(mt))
-	err = Table{r.db}.List(listPtr.Interface(), ListOptions{})
+// Get the stored model (pre-delete state).
+// Used by cascade() and journaled (Deleted) so that watch
+// filters are applied to the deleted model rather than the
+// model passed to Delete() which may contain only the PK.
+// Returns nil when not found.
+func (r *Client) deleting(table Table, model Model) (Model, error) {
+	stored := r.journal.copy(model)
+	err := table.Get(stored)
 	if err != nil {
+		if errors.Is(err, NotFound) {
+			return nil, nil
+		}
 		return nil, liberr.Wrap(err)
 	}
-	list := listPtr.Elem()
-	for i := 0; i < list.Len(); i++ {
-		m := list.Index(i).Addr().Interface()
-		watch.notify(
-			&Event{
-				Model:  m.(Model),
-				Action: Created,
-			})
-	}
 
-	watch.Start()
+	return stored, nil
+}
 
-	return watch, nil
+//
+// Foreign key reference.
+type reference struct {
+	// Referencing model.
+	model interface{}
+	// Referencing (fk) field (column) name.
+	field string
+	// Referenced field (column) name.
+	referenced string
 }
 
 //
-// The associated journal.
-func (r *Client) Journal() *Journal {
-	return &r.journal
+// Get the references (fk) to the model by registered models.
+func (r *Client) references(table Table, model interface{}) []reference {
+	list := []reference{}
+	name := table.Name(model)
+	for _, m := range r.models {
+		if _, cast := m.(*Label); cast {
+			continue
+		}
+		fields, err := table.Fields(m)
+		if err != nil {
+			continue
+		}
+		for _, f := range fields {
+			fk := f.Fk()
+			if fk == nil || table.ident(fk.Table) != name {
+				continue
+			}
+			list = append(
+				list,
+				reference{
+					model:      m,
+					field:      f.Name,
+					referenced: table.ident(fk.Field),
+				})
+		}
+	}
+
+	return list
 }
 
 //
-// Insert labels for the model into the DB.
-func (r *Client) insertLabels(table Table, model Model) error {
-	for l, v := range model.Labels() {
-		label := &Label{
-			Parent: model.Pk(),
-			Kind:   table.Name(model),
-			Name:   l,
-			Value:  v,
+// Delete the models referencing (fk) the deleted model.
+// ON DELETE CASCADE is emulated when foreign keys are not
+// enforced (See: ForeignKeys()). Labels of the referencing
+// models are deleted. Events are not journaled for referencing
+// models (as with ON DELETE CASCADE). The `model` is the
+// deleted (stored) model and may be nil.
+func (r *Client) cascade(table Table, model Model) error {
+	if r.foreignKeys || model == nil {
+		return nil
+	}
+	fields, err := table.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	for _, fk := range r.references(table, model) {
+		var value interface{}
+		for _, f := range fields {
+			if f.Name == fk.referenced {
+				value = f.Pull()
+				break
+			}
 		}
-		err := table.Insert(label)
+		if value == nil {
+			continue
+		}
+		children, err := table.listModels(
+			fk.model,
+			ListOptions{
+				Predicate: Eq(fk.field, value),
+			})
 		if err != nil {
 			return liberr.Wrap(err)
 		}
+		for _, child := range children {
+			err = table.Delete(child)
+			if err != nil {
+				return liberr.Wrap(err)
+			}
+			err = r.deleteLabels(table, child)
+			if err != nil {
+				return liberr.Wrap(err)
+			}
+			err = r.cascade(table, child)
+			if err != nil {
+				return liberr.Wrap(err)
+			}
+		}
 	}
 
 	return nil
 }
 
 //
-// Delete labels for a model in the DB.
-func (r *Client) deleteLabels(table Table, model Model) error {
+// Replace labels.
+// The labels in the DB are reconciled with the model labels.
+// Only labels that have been added, removed or changed are written.
+func (r *Client) replaceLabels(table Table, model Model) (*LabelDelta, error) {
+	delta := &LabelDelta{
+		Added:   Labels{},
+		Removed: Labels{},
+		Changed: Labels{},
+	}
 	list := []Label{}
 	err := table.List(
 		&list,
 		ListOptions{
 			Predicate: And(
-				Eq("Kind", table.Name(model)),
+				Eq("Kind", table.Kind(model)),
 				Eq("Parent", model.Pk())),
 		})
 	if err != nil {
-		return liberr.Wrap(err)
+		return nil, liberr.Wrap(err)
 	}
+	current := map[string]Label{}
 	for _, label := range list {
-		err := table.Delete(&label)
+		current[label.Name] = label
+	}
+	wanted, err := r.labels(table, model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	for _, label := range wanted {
+		stored, found := current[label.Name]
+		if !found {
+			err = table.Insert(label)
+			if err != nil {
+				return nil, liberr.Wrap(err)
+			}
+			delta.Added[label.Name] = label.Value
+			continue
+		}
+		delete(current, label.Name)
+		if stored.Value == label.Value && stored.Type == label.Type {
+			continue
+		}
+		label.PK = stored.PK
+		err = table.Update(label)
 		if err != nil {
-			return liberr.Wrap(err)
+			return nil, liberr.Wrap(err)
+		}
+		delta.Changed[label.Name] = label.Value
+	}
+	for _, label := range current {
+		err = table.Delete(&label)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		delta.Removed[label.Name] = label.Value
+	}
+	if !delta.Empty() {
+		err = r.setPromoted(table, model, wanted)
+		if err != nil {
+			return nil, liberr.Wrap(err)
 		}
 	}
 
-	return nil
+	return delta, nil
 }
 
 //
-// Replace labels.
-func (r *Client) replaceLabels(table Table, model Model) error {
-	err := r.deleteLabels(table, model)
+// Get whether the model labels differ from the stored labels.
+func (r *Client) labelsChanged(table Table, model Model) (bool, error) {
+	list := []Label{}
+	err := table.List(
+		&list,
+		ListOptions{
+			Predicate: And(
+				Eq("Kind", table.Kind(model)),
+				Eq("Parent", model.Pk())),
+		})
 	if err != nil {
-		return liberr.Wrap(err)
+		return false, liberr.Wrap(err)
 	}
-	err = r.insertLabels(table, model)
+	wanted, err := r.labels(table, model)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	if len(wanted) != len(list) {
+		return true, nil
+	}
+	current := map[string]Label{}
+	for _, label := range list {
+		current[label.Name] = label
+	}
+	for _, label := range wanted {
+		stored, found := current[label.Name]
+		if !found || stored.Value != label.Value || stored.Type != label.Type {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+//
+// Build the promoted label columns.
+// Columns (and indexes) are added to the model tables as
+// needed and populated using the Label table.
+func (r *Client) promote(db DBTX) error {
+	table := r.table(db)
+	for kind, names := range r.PromotedLabels {
+		var model interface{}
+		for _, m := range r.models {
+			if table.Name(m) == kind {
+				model = m
+				break
+			}
+		}
+		if model == nil {
+			return liberr.Wrap(PromotedLabelError)
+		}
+		for _, name := range names {
+			if !ColumnRegex.MatchString(name) {
+				return liberr.Wrap(PromotedLabelError)
+			}
+			err := table.Promote(model, name)
+			if err != nil {
+				return liberr.Wrap(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+//
+// Set the promoted label columns for the model.
+func (r *Client) setPromoted(table Table, model Model, labels []*Label) error {
+	names := r.PromotedLabels[table.Name(model)]
+	if len(names) == 0 {
+		return nil
+	}
+	values := map[string]interface{}{}
+	for _, name := range names {
+		values[PromotedColumn(name)] = nil
+	}
+	for _, label := range labels {
+		column := PromotedColumn(label.Name)
+		if _, found := values[column]; found {
+			values[column] = label.Value
+		}
+	}
+	err := table.SetColumns(model, values)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
@@ -381,14 +3825,62 @@ func (r *Client) commit(tx *Tx) error {
 	}
 	defer func() {
 		r.dbMutex.Unlock()
+		r.txMutex.Unlock()
 		r.tx = nil
+		r.purge = false
 	}()
+	if r.journal.Overflow() {
+		err := r.tx.Rollback()
+		r.journal.Unstage()
+		if err != nil {
+			return Classify(err)
+		}
+		return liberr.Wrap(JournalFullError)
+	}
+	if tx.options.DeferForeignKeys {
+		err := r.foreignKeyCheck(r.tx)
+		if err != nil {
+			_ = r.tx.Rollback()
+			r.journal.Unstage()
+			return Classify(err)
+		}
+	}
 	err := r.tx.Commit()
 	if err != nil {
-		return liberr.Wrap(err)
+		return Classify(err)
 	}
 
 	r.journal.Commit()
+	if r.purge {
+		r.cache.Purge()
+	}
+
+	return nil
+}
+
+//
+// Check (deferred) foreign key constraints.
+// Returns ConflictError when violated. Checked before the
+// commit because sqlite leaves the transaction open when the
+// commit fails on deferred constraints.
+func (r *Client) foreignKeyCheck(tx *sql.Tx) error {
+	cursor, err := tx.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	if cursor.Next() {
+		table := ""
+		_ = cursor.Scan(&table)
+		return &Error{
+			Kind:   ConflictError,
+			Reason: liberr.New("foreign key constraint failed: " + table),
+		}
+	}
+	err = cursor.Err()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
 
 	return nil
 }
@@ -405,11 +3897,13 @@ func (r *Client) end(tx *Tx) error {
 	}
 	defer func() {
 		r.dbMutex.Unlock()
+		r.txMutex.Unlock()
 		r.tx = nil
+		r.purge = false
 	}()
 	err := r.tx.Rollback()
 	if err != nil {
-		return liberr.Wrap(err)
+		return Classify(err)
 	}
 
 	r.journal.Unstage()
@@ -424,6 +3918,26 @@ type Tx struct {
 	client *Client
 	// Reference to sql.Tx.
 	ref *sql.Tx
+	// Options.
+	options TxOptions
+}
+
+//
+// Transaction options.
+type TxOptions struct {
+	// Foreign key constraints are checked on Commit() rather
+	// than as each statement is executed so that models may be
+	// written in any order (Eg: child before parent). Commit()
+	// fails with ConflictError and the transaction is rolled
+	// back when constraints are violated.
+	DeferForeignKeys bool
+}
+
+//
+// Insert options.
+type InsertOptions struct {
+	// Conflict strategy.
+	OnConflict OnConflict
 }
 
 //
@@ -441,3 +3955,51 @@ func (r *Tx) Commit() error {
 func (r *Tx) End() error {
 	return r.client.end(r)
 }
+
+//
+// Get the model.
+// Staged (uncommitted) changes are read.
+func (r *Tx) Get(model Model) error {
+	table := r.client.table(r.ref)
+	defer r.client.timed(&table)()
+	err := table.Get(model)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// List models.
+// Staged (uncommitted) changes are read.
+// The `list` must be: *[]Model.
+func (r *Tx) List(list interface{}, options ListOptions) error {
+	options.promoted = r.client.PromotedLabels
+	table := r.client.table(r.ref)
+	defer r.client.timed(&table)()
+	err := table.List(list, options)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Count models.
+// Staged (uncommitted) changes are read.
+func (r *Tx) Count(model Model, predicate Predicate) (int64, error) {
+	options := ListOptions{
+		Predicate: predicate,
+		promoted:  r.client.PromotedLabels,
+	}
+	table := r.client.table(r.ref)
+	defer r.client.timed(&table)()
+	n, err := table.CountOptions(model, options)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return n, nil
+}
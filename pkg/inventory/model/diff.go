@@ -0,0 +1,88 @@
+package model
+
+import (
+	liberr "github.com/konveyor/controller/pkg/error"
+	"sort"
+)
+
+//
+// Prefix used to report label differences.
+const LabelDiffPrefix = "label:"
+
+//
+// Diff models.
+// Returns the names of the fields (columns) with values that
+// differ between `a` and `b` followed by the names of the labels
+// that differ, prefixed with `label:`. Computed fields are
+// ignored. An empty result indicates the models are the same
+// as stored and an Update() is not necessary. An error is
+// returned when the models cannot be compared.
+func Diff(a, b Model) (changed []string, err error) {
+	table := Table{}
+	fieldsA, err := table.Fields(a)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	fieldsB, err := table.Fields(b)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	changed = []string{}
+	values := map[string]interface{}{}
+	for _, f := range table.StoredFields(fieldsB) {
+		values[f.Name] = f.Pull()
+	}
+	for _, f := range table.StoredFields(fieldsA) {
+		v, found := values[f.Name]
+		delete(values, f.Name)
+		if !found || v != f.Pull() {
+			changed = append(changed, f.Name)
+		}
+	}
+	for name := range values {
+		changed = append(changed, name)
+	}
+	labelsA := labelValues(a)
+	labelsB := labelValues(b)
+	names := []string{}
+	for name, v := range labelsA {
+		if other, found := labelsB[name]; !found || other != v {
+			names = append(names, name)
+		}
+	}
+	for name := range labelsB {
+		if _, found := labelsA[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		changed = append(changed, LabelDiffPrefix+name)
+	}
+
+	return
+}
+
+//
+// Get the (typed) label values for the model.
+// Map of: name => type:value.
+func labelValues(model Model) map[string]string {
+	values := map[string]string{}
+	for name, v := range model.Labels() {
+		values[name] = LabelString + ":" + v
+	}
+	if m, cast := model.(TypedLabeled); cast {
+		for name, v := range m.TypedLabels() {
+			label := &Label{}
+			err := label.SetValue(v)
+			if err != nil {
+				continue
+			}
+			values[name] = label.Type + ":" + label.Value
+		}
+	}
+
+	return values
+}
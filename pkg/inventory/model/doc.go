@@ -13,7 +13,15 @@
 //       Unique index. `G` = unique-together fields.
 //   `sql:"const"`
 //       The field is immutable and not included on update.
+//   `sql:"expires"`
+//       The (int) field is the expiry time (unix seconds).
+//       Expired models are deleted by Sweep().
+//   `sql:"updated"`
+//       The (int64) field is the time (unix nanoseconds) the
+//       model was last written. Set on insert, update and Touch().
 // Each struct must implement the `Model` interface.
+// The table name is the name of the struct unless the
+// model implements the `TableNamed` interface.
 // Basic CRUD operations may be performed on each model using
 // the `DB` interface which together with the `Model` interface
 // provides value-added features and optimizations.
@@ -44,7 +52,9 @@
 //
 // In the event the primary key (PK) field is not populated,
 // the DB will derive (generate) its value as a sha1 of the
-// natural key fields.
+// natural key fields. Models without natural key fields may
+// have the (str) PK generated using the Client.GenerateID
+// function (Eg: UUID) or by implementing `IDGenerator`.
 //
 // Update the model:
 //   person.Age = 62
@@ -88,6 +98,14 @@
 //           },
 //       })
 //
+// Paginate the result using a keyset (after) token. Rows
+// before the page are not scanned:
+//   options := ListOptions{Page: &Page{Limit: 10}}
+//   next, err := DB.ListAfter(&persons, options)
+//   ...
+//   options.After = next
+//   next, err = DB.ListAfter(&persons, options)
+//
 // List specific models.
 // List persons with the last name of "Fudd" and legal to vote.
 //   err := DB.List(
@@ -99,13 +117,70 @@
 //           },
 //       })
 //
+// Find (list) models matching a predicate.
+//   err := DB.Find(&persons, Eq("Last", "Fudd"))
+//
+// Compare two fields using a field reference.
+//   err := DB.Find(&persons, Lt("Age", Ref("Retirement")))
+//
+// List models with a field value in a list. Fields of named
+// (enum) types are compared using the underlying value.
+//   err := DB.Find(&persons, In("Phase", []Phase{Pending, Running}))
+//
+// Durations (time.Duration) are stored as nanoseconds and may
+// be compared.
+//   err := DB.Find(&persons, Gt("Timeout", time.Minute))
+//
+// List models with a (JSON array) field containing a value.
+// Requires the sqlite JSON1 extension (build tag: sqlite_json).
+//   err := DB.Find(&persons, JsonArrayContains("Nicknames", "Doc"))
+//
+// List models by label.
+// Models implementing `TypedLabeled` may have labels with
+// (int, bool) values. Integer labels may be compared.
+//   err := DB.List(
+//       &persons,
+//       ListOptions{
+//           Predicate: And(
+//               Match(Labels{"city": "Boston"}),
+//               LabelGt("rank", 3),
+//           },
+//       })
+//
+// List models having any of the label values.
+// Persons in Boston or Denver or with the "vip" label "true".
+//   err := DB.Find(
+//       &persons,
+//       AnyLabel(map[string][]string{
+//           "city": {"Boston", "Denver"},
+//           "vip":  {"true"},
+//       }))
+//
+// Read isolation.
+// Each client read (Get, List, Count) is performed in its own
+// (implicit) transaction and sees the latest committed state, even
+// while a transaction is in progress. Staged changes are read using
+// the Tx (Get, List, Count) methods. A sequence of reads may observe
+// different states when changes are committed between them. Use
+// View() to bind a sequence of reads to a single (consistent) snapshot.
+//   err := DB.View(func(v *View) error {
+//       err := v.List(&persons, ListOptions{})
+//       if err != nil {
+//           return err
+//       }
+//       return v.Get(person)
+//   })
+//
 package model
 
 //
 // New database.
+// The models are registered. See: Client.Register().
 func New(path string, models ...interface{}) DB {
-	return &Client{
-		path:   path,
-		models: models,
+	client := &Client{
+		path: path,
 	}
+	client.register(models...)
+
+	return client
 }
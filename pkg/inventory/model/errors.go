@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/mattn/go-sqlite3"
+)
+
+//
+// Error kinds.
+// Errors returned by the Client are classified and may be
+// tested using errors.Is(). Example:
+//   err := client.Get(model)
+//   if errors.Is(err, model.NotFound) {
+//       ...
+//   }
+// NotFound (sql.ErrNoRows) is defined in model.go.
+var (
+	// The model already exists or a constraint
+	// is violated.
+	ConflictError = errors.New("model already exists")
+	// The model was changed or deleted by another
+	// writer after it was read.
+	StaleError = errors.New("model is stale")
+	// Tx.Commit()
+	// Tx.End()
+	// Called and the transaction is not in progress by
+	// the associated Client.
+	TxInvalidError = errors.New("transaction not valid")
+	// The DB is not open.
+	NotOpenError = errors.New("DB not open")
+	// The DB is already open.
+	AlreadyOpenError = errors.New("DB already open")
+	// The DB is locked (busy) and the lock was not
+	// acquired before the timeout.
+	LockTimeoutError = errors.New("DB lock timeout")
+	// The operation did not complete before the
+	// (default) timeout.
+	TimeoutError = errors.New("operation timeout")
+)
+
+//
+// Classified error.
+// Reports the kind (sentinel) error and wraps the
+// underlying (reason) error which retains its stack.
+type Error struct {
+	// The kind of error.
+	Kind error
+	// The underlying error.
+	Reason error
+}
+
+//
+// Error description.
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Reason.Error()
+}
+
+//
+// Is the error of the specified kind.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+//
+// Unwrap the error.
+func (e *Error) Unwrap() error {
+	return e.Reason
+}
+
+//
+// Classify the error.
+// Underlying sqlite and driver errors are mapped to the
+// kind (sentinel) errors. Errors already classified
+// or not mapped are returned (wrapped) unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, kind := range []error{
+		NotFound,
+		ConflictError,
+		StaleError,
+		TxInvalidError,
+		NotOpenError,
+		LockTimeoutError,
+		TimeoutError,
+	} {
+		if errors.Is(err, kind) {
+			return liberr.Wrap(err)
+		}
+	}
+	var kind error
+	sqliteErr := sqlite3.Error{}
+	switch {
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code {
+		case sqlite3.ErrConstraint:
+			kind = ConflictError
+		case sqlite3.ErrBusy,
+			sqlite3.ErrLocked:
+			kind = LockTimeoutError
+		}
+	case errors.Is(err, sql.ErrTxDone):
+		kind = TxInvalidError
+	case errors.Is(err, context.DeadlineExceeded):
+		kind = TimeoutError
+	case errors.Is(err, sql.ErrConnDone):
+		kind = NotOpenError
+	case err.Error() == "sql: database is closed":
+		kind = NotOpenError
+	}
+	if kind == nil {
+		return liberr.Wrap(err)
+	}
+
+	return liberr.Wrap(
+		&Error{
+			Kind:   kind,
+			Reason: err,
+		})
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"bytes"
+	"database/sql"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"reflect"
+	"text/template"
+)
+
+//
+// Full-text search (FTS).
+// String fields tagged `fts` are indexed in a shadow (FTS4)
+// virtual table named: <table>_fts. The shadow table is
+// maintained by triggers on insert, update and delete.
+// Rows that exist when the shadow table is created are indexed.
+// Example:
+//   type Person struct {
+//       ID  int    `sql:"pk"`
+//       Bio string `sql:"fts"`
+//   }
+
+//
+// FTS DDL templates.
+var FtsTableDDL = `
+CREATE VIRTUAL TABLE IF NOT EXISTS {{.Table}}_fts
+USING fts4(
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Name }}
+{{ end -}}
+);
+`
+
+var FtsInsertDDL = `
+CREATE TRIGGER IF NOT EXISTS {{.Table}}_fts_insert
+AFTER INSERT ON {{.Table}}
+BEGIN
+INSERT INTO {{.Table}}_fts (
+docid
+{{ range $i,$f := .Fields -}}
+,{{ $f.Name }}
+{{ end -}}
+)
+VALUES (
+new.rowid
+{{ range $i,$f := .Fields -}}
+,new.{{ $f.Name }}
+{{ end -}}
+);
+END;
+`
+
+var FtsUpdateDDL = `
+CREATE TRIGGER IF NOT EXISTS {{.Table}}_fts_update
+AFTER UPDATE OF
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Name }}
+{{ end -}}
+ON {{.Table}}
+BEGIN
+DELETE FROM {{.Table}}_fts WHERE docid = old.rowid;
+INSERT INTO {{.Table}}_fts (
+docid
+{{ range $i,$f := .Fields -}}
+,{{ $f.Name }}
+{{ end -}}
+)
+VALUES (
+new.rowid
+{{ range $i,$f := .Fields -}}
+,new.{{ $f.Name }}
+{{ end -}}
+);
+END;
+`
+
+var FtsDeleteDDL = `
+CREATE TRIGGER IF NOT EXISTS {{.Table}}_fts_delete
+AFTER DELETE ON {{.Table}}
+BEGIN
+DELETE FROM {{.Table}}_fts WHERE docid = old.rowid;
+END;
+`
+
+var FtsPopulateDDL = `
+INSERT INTO {{.Table}}_fts (
+docid
+{{ range $i,$f := .Fields -}}
+,{{ $f.Name }}
+{{ end -}}
+)
+SELECT
+rowid
+{{ range $i,$f := .Fields -}}
+,{{ $f.Name }}
+{{ end -}}
+FROM {{.Table}}
+WHERE rowid NOT IN (SELECT docid FROM {{.Table}}_fts)
+;
+`
+
+//
+// Search SQL.
+// Ranked by the number of matched terms (phrases).
+var SearchSQL = `
+SELECT
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Select }}
+{{ end -}}
+FROM {{.Table}}
+INNER JOIN (
+SELECT
+docid,
+length(offsets({{.Table}}_fts)) -
+length(replace(offsets({{.Table}}_fts), ' ', '')) AS hits
+FROM {{.Table}}_fts
+WHERE {{.Table}}_fts MATCH :query
+) AS fts ON fts.docid = {{.Table}}.rowid
+ORDER BY fts.hits DESC, {{.Table}}.rowid
+;
+`
+
+//
+// Get the full-text search (fts) fields.
+func (t Table) FtsFields(fields []*Field) []*Field {
+	list := []*Field{}
+	for _, f := range fields {
+		if f.Fts() {
+			list = append(list, f)
+		}
+	}
+
+	return list
+}
+
+//
+// Get the FTS (shadow) table name.
+// Empty when the model has no fts fields.
+func (t Table) FtsTable(model interface{}) (string, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+	if len(t.FtsFields(fields)) == 0 {
+		return "", nil
+	}
+
+	return t.Name(model) + "_fts", nil
+}
+
+//
+// Build the FTS DDL.
+// The shadow table, triggers and the statement used
+// to index existing rows.
+func (t Table) FtsDDL(model interface{}) ([]string, error) {
+	list := []string{}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	fields = t.FtsFields(fields)
+	if len(fields) == 0 {
+		return list, nil
+	}
+	for _, ddl := range []string{
+		FtsTableDDL,
+		FtsInsertDDL,
+		FtsUpdateDDL,
+		FtsDeleteDDL,
+		FtsPopulateDDL,
+	} {
+		tpl := template.New("")
+		tpl, err = tpl.Parse(ddl)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		bfr := &bytes.Buffer{}
+		err = tpl.Execute(
+			bfr,
+			TmplData{
+				Table:  t.Name(model),
+				Fields: fields,
+			})
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		list = append(list, bfr.String())
+	}
+
+	return list, nil
+}
+
+//
+// Full-text search.
+// The `query` uses the FTS MATCH syntax. Example:
+//   `term`, `"a phrase"`, `term*`, `a OR b`.
+// Returns matched models ranked by the number of
+// matched terms (phrases).
+func (t Table) Search(model interface{}, query string) ([]Model, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	if len(t.FtsFields(fields)) == 0 {
+		return nil, liberr.Wrap(FtsNotIndexedErr)
+	}
+	tpl := template.New("")
+	tpl, err = tpl.Parse(SearchSQL)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:  t.Name(model),
+			Fields: fields,
+		})
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(bfr.String(), sql.Named("query", query))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+	models := []Model{}
+	for cursor.Next() {
+		m, cast := reflect.New(mt).Interface().(Model)
+		if !cast {
+			return nil, liberr.Wrap(MustBeObjectErr)
+		}
+		newFields, _ := t.Fields(m)
+		err = t.scan(cursor, newFields)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		models = append(models, m)
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return models, nil
+}
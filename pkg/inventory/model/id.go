@@ -0,0 +1,101 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+//
+// ID generator.
+// Optionally implemented by models to generate the (str) PK
+// on insert when not set. Takes precedence over the generator
+// configured on the Client.
+type IDGenerator interface {
+	// Generate the ID.
+	GenerateID() string
+}
+
+//
+// Generate a (random) version 4 UUID.
+// Example:
+//   client.GenerateID = model.UUID
+func UUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	s := hex.EncodeToString(b)
+
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+//
+// Crockford base32 alphabet used to encode ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+//
+// ULID generator.
+// Generates lexicographically sortable (26 character) IDs
+// using the time (ms) and randomness. IDs generated within
+// the same millisecond are monotonically increasing.
+// Example:
+//   client.GenerateID = (&model.ULID{}).Generate
+type ULID struct {
+	// Protect internal state.
+	mutex sync.Mutex
+	// Time (ms) of the last ID.
+	last uint64
+	// Entropy (80 bits) of the last ID.
+	entropy [10]byte
+}
+
+//
+// Generate the next ID.
+func (g *ULID) Generate() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	if ms > g.last {
+		g.last = ms
+		_, _ = rand.Read(g.entropy[:])
+	} else {
+		for i := len(g.entropy) - 1; i >= 0; i-- {
+			g.entropy[i]++
+			if g.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+	id := [16]byte{}
+	binary.BigEndian.PutUint16(id[0:2], uint16(g.last>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(g.last))
+	copy(id[6:], g.entropy[:])
+
+	return g.encode(id)
+}
+
+//
+// Encode the (128 bit) ID.
+// Each character encodes 5 bits; the first encodes
+// the 3 most significant bits.
+func (g *ULID) encode(id [16]byte) string {
+	bit := func(n int) byte {
+		if n < 0 {
+			return 0
+		}
+		return (id[n/8] >> (7 - uint(n%8))) & 1
+	}
+	encoded := make([]byte, 26)
+	for i := range encoded {
+		v := byte(0)
+		for n := i*5 - 2; n < i*5+3; n++ {
+			v = v<<1 | bit(n)
+		}
+		encoded[i] = crockford[v]
+	}
+
+	return string(encoded)
+}
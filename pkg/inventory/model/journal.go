@@ -5,6 +5,7 @@ import (
 	"github.com/konveyor/controller/pkg/ref"
 	"reflect"
 	"sync"
+	"time"
 )
 
 //
@@ -24,6 +25,49 @@ type Event struct {
 	Action int8
 	// The updated model.
 	Updated Model
+	// Label changes (updated only).
+	Labels *LabelDelta
+	// Delivered by Watch.Resync() (created only).
+	Resync bool
+	// Commit sequence.
+	// Assigned (increasing) as events are committed.
+	// Zero for replayed events.
+	Seq uint64
+	// Bulk operation (group).
+	bulk uint64
+	// Grouped events (bulk delivery).
+	grouped []*Event
+	// Transaction boundary (marker).
+	boundary *TxBoundary
+	// Replayed models (snapshot delivery).
+	// Not nil marks a snapshot.
+	snapshot []Model
+}
+
+//
+// Bulk event.
+// Delivered (once) to a BulkEventHandler for the models
+// (of the watched kind) affected by a bulk operation.
+type BulkEvent struct {
+	// The event action (created|updated|deleted).
+	Action int8
+	// The event for each affected model.
+	Events []Event
+	// Commit sequence of the last event.
+	Seq uint64
+}
+
+//
+// Transaction boundary.
+// Delivered to a TxEventHandler after the events committed
+// by a transaction have been delivered.
+type TxBoundary struct {
+	// Commit sequence of the last event committed by
+	// the transaction.
+	Seq uint64
+	// The number of (model) events delivered for the
+	// transaction.
+	Count int
 }
 
 //
@@ -41,31 +85,240 @@ type EventHandler interface {
 	End()
 }
 
+//
+// Bulk event handler.
+// Handlers that implement this interface opt-in to receive
+// a single BulkEvent for each bulk operation instead of an
+// event for each affected model.
+type BulkEventHandler interface {
+	EventHandler
+	// Models have been changed by a bulk operation.
+	Bulk(BulkEvent)
+}
+
+//
+// Transaction event handler.
+// Handlers that implement this interface opt-in to receive
+// a TxBoundary (marker) following the events committed by each
+// transaction. Writes made outside of a transaction are each
+// committed by an implicit transaction.
+type TxEventHandler interface {
+	EventHandler
+	// The events committed by a transaction have been delivered.
+	TxBoundary(TxBoundary)
+}
+
+//
+// Journaled model.
+// Optionally implemented by models to provide the snapshot
+// (copy) journaled in events. By default, models are copied
+// (by value) using reflection which may be costly for models
+// with large fields.
+type Journaled interface {
+	// Get a snapshot (copy) of the model.
+	// Returns nil when the model does not need to be copied.
+	// The model is journaled (shared) as passed and must not
+	// be changed by the caller or the handlers.
+	Snapshot() Model
+}
+
+//
+// Snapshot event handler.
+// Handlers that implement this interface opt-in to receive
+// the replayed (current) state as a single batch instead of
+// a Created event for each model. Live events continue to be
+// delivered individually.
+type SnapshotEventHandler interface {
+	EventHandler
+	// The current state (models) of the watched kinds.
+	// Delivered when the watch is started and by Resync().
+	Snapshot([]Model)
+}
+
 //
 // Model event watch.
+// Events are delivered in commit order by a single goroutine.
+// Events for the same model (key) are guaranteed to be
+// delivered first-in-first-out (FIFO). Cross-key ordering
+// is not part of the contract except for the events of
+// watched kinds committed by the same transaction which
+// are delivered in the order staged (See: WatchOptions.Kinds).
 type Watch struct {
 	// Model to be watched.
 	Model Model
+	// Additional model kinds to be watched.
+	// See: WatchOptions.Kinds.
+	Kinds []Model
 	// Event handler.
 	Handler EventHandler
+	// Filter (optional).
+	// See: WatchOptions.Filter.
+	Filter func(Model) bool
 	// Event queue.
 	queue chan *Event
 	// Started
 	started bool
+	// Resync (replay) the current state.
+	resync func() error
+	// Live events are not delivered.
+	// See: WatchOptions.SnapshotOnly.
+	snapshotOnly bool
+	// Closed when the watch is ended.
+	done chan struct{}
 }
 
 //
 // Match by model `kind`.
+// Either the watched model or one of the watched kinds.
 func (w *Watch) Match(model Model) bool {
-	return ref.ToKind(w.Model) == ref.ToKind(model)
+	kind := ref.ToKind(model)
+	if ref.ToKind(w.Model) == kind {
+		return true
+	}
+	for _, m := range w.Kinds {
+		if ref.ToKind(m) == kind {
+			return true
+		}
+	}
+
+	return false
 }
 
 //
-// Queue event.
-func (w *Watch) notify(event *Event) {
+// The watched models (kinds).
+func (w *Watch) models() []Model {
+	return append([]Model{w.Model}, w.Kinds...)
+}
+
+//
+// Watch options.
+type WatchOptions struct {
+	// Filter (optional) applied to each event before it is
+	// queued, including the replayed (initial) state. Events
+	// are delivered only when the model is matched. Updated
+	// events are delivered when either the model or the updated
+	// model is matched. Deleted events are filtered using the
+	// stored (deleted) model. Called while the journal is
+	// locked and must not use the DB.
+	Filter func(Model) bool
+	// Additional model kinds watched (optional).
+	// The events of all watched kinds are delivered by a single
+	// watch (queue) in commit order. The events committed by a
+	// transaction are delivered in the order staged so that
+	// cross-kind invariants hold. The current state of each
+	// kind is replayed in the order listed following the
+	// watched model.
+	Kinds []Model
+	// Snapshot interval (optional).
+	// The current state of the watched kinds is delivered as
+	// a snapshot each interval. The snapshot is skipped when
+	// nothing (generation) has changed since the last one was
+	// delivered. The handler must implement SnapshotEventHandler.
+	Interval time.Duration
+	// Only snapshots are delivered. Live events are not.
+	// The handler must implement SnapshotEventHandler.
+	SnapshotOnly bool
+}
+
+//
+// Determine whether the (live) event is accepted by the watch.
+// Matched by kind and filter. Not accepted when only
+// snapshots are delivered.
+func (w *Watch) accept(event *Event) bool {
+	if w.snapshotOnly {
+		return false
+	}
+
+	return w.matched(event)
+}
+
+//
+// Determine whether the event is matched by kind and filter.
+func (w *Watch) matched(event *Event) bool {
 	if !w.Match(event.Model) {
+		return false
+	}
+	if w.Filter == nil || event.grouped != nil {
+		return true
+	}
+	if w.Filter(event.Model) {
+		return true
+	}
+
+	return event.Updated != nil && w.Filter(event.Updated)
+}
+
+//
+// Queue event.
+// Returns whether the event was accepted.
+func (w *Watch) notify(event *Event) bool {
+	if !w.accept(event) {
+		return false
+	}
+	w.push(event)
+	return true
+}
+
+//
+// Queue bulk (grouped) events.
+// A single event is queued to handlers that opt-in.
+// Returns the number of events accepted.
+func (w *Watch) notifyBulk(group []*Event) int {
+	if _, optIn := w.Handler.(BulkEventHandler); !optIn {
+		n := 0
+		for _, event := range group {
+			if w.notify(event) {
+				n++
+			}
+		}
+		return n
+	}
+	matched := []*Event{}
+	for _, event := range group {
+		if w.accept(event) {
+			matched = append(matched, event)
+		}
+	}
+	if len(matched) == 0 {
+		return 0
+	}
+	last := matched[len(matched)-1]
+	w.push(
+		&Event{
+			Model:   last.Model,
+			Action:  last.Action,
+			Seq:     last.Seq,
+			grouped: matched,
+		})
+
+	return len(matched)
+}
+
+//
+// Queue a transaction boundary to handlers that opt-in.
+func (w *Watch) notifyBoundary(boundary TxBoundary) {
+	if _, optIn := w.Handler.(TxEventHandler); !optIn || w.snapshotOnly {
 		return
 	}
+	w.push(&Event{boundary: &boundary})
+}
+
+//
+// Queue a snapshot of the replayed models.
+// Models not accepted by the watch are omitted.
+func (w *Watch) notifySnapshot(models []Model) {
+	snapshot := []Model{}
+	for _, m := range models {
+		if w.matched(&Event{Model: m, Action: Created}) {
+			snapshot = append(snapshot, m)
+		}
+	}
+	w.push(&Event{snapshot: snapshot})
+}
+
+//
+// Push the event on the queue.
+func (w *Watch) push(event *Event) {
 	defer func() {
 		recover()
 	}()
@@ -86,6 +339,25 @@ func (w *Watch) Start() {
 	}
 	run := func() {
 		for event := range w.queue {
+			if event.boundary != nil {
+				w.Handler.(TxEventHandler).TxBoundary(*event.boundary)
+				continue
+			}
+			if event.snapshot != nil {
+				w.Handler.(SnapshotEventHandler).Snapshot(event.snapshot)
+				continue
+			}
+			if event.grouped != nil {
+				bulk := BulkEvent{
+					Action: event.Action,
+					Seq:    event.Seq,
+				}
+				for _, e := range event.grouped {
+					bulk.Events = append(bulk.Events, *e)
+				}
+				w.Handler.(BulkEventHandler).Bulk(bulk)
+				continue
+			}
 			switch event.Action {
 			case Created:
 				w.Handler.Created(*event)
@@ -104,22 +376,69 @@ func (w *Watch) Start() {
 	go run()
 }
 
+//
+// Resync the watch.
+// A Created event (marked Resync) is re-delivered to
+// this watch for each model in the DB. The models are
+// delivered as a single snapshot to handlers that opt-in.
+// Live events continue to be delivered.
+func (w *Watch) Resync() error {
+	if w.resync == nil {
+		return liberr.New("resync not supported")
+	}
+	err := w.resync()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
 //
 // End the watch.
 func (w *Watch) End() {
+	if w.done != nil {
+		close(w.done)
+	}
 	close(w.queue)
 }
 
+//
+// Default watch queue size.
+const WatchQueueSize = 10000
+
 //
 // Event manager.
 type Journal struct {
-	mutex sync.RWMutex
+	// Max number of events staged (per transaction).
+	// When exceeded, the transaction is rolled back on commit
+	// and JournalFullError is returned. 0 = unlimited.
+	MaxStaged int
+	// Watch queue (buffer) size. Events are discarded and
+	// reported to the handler when full.
+	// Default: WatchQueueSize.
+	QueueSize int
+	mutex     sync.RWMutex
 	// List of registered watches.
 	watches []*Watch
 	// Queue of staged events.
 	staged []*Event
+	// Staged changes by kind.
+	changed map[string]uint64
+	// Generation by kind.
+	generation map[string]uint64
+	// Commit sequence.
+	seq uint64
+	// Staged events discarded (cap exceeded).
+	overflow bool
+	// Suspended.
+	suspended bool
 	// Enabled.
 	enabled bool
+	// Current bulk operation (group).
+	bulk uint64
+	// Bulk operation sequence.
+	bulkSeq uint64
 }
 
 //
@@ -163,12 +482,20 @@ func (r *Journal) Watch(model Model, handler EventHandler) (*Watch, error) {
 	if !r.enabled {
 		return nil, liberr.New("disabled")
 	}
+	if r.suspended {
+		return nil, liberr.New("suspended")
+	}
 	watch := &Watch{
 		Handler: handler,
 		Model:   model,
 	}
 	r.watches = append(r.watches, watch)
-	watch.queue = make(chan *Event, 10000)
+	size := r.QueueSize
+	if size < 1 {
+		size = WatchQueueSize
+	}
+	watch.queue = make(chan *Event, size)
+	watch.done = make(chan struct{})
 	return watch, nil
 }
 
@@ -192,38 +519,71 @@ func (r *Journal) End(watch *Watch) {
 	r.watches = kept
 }
 
+//
+// End all watches.
+func (r *Journal) EndAll() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, w := range r.watches {
+		w.End()
+	}
+
+	r.watches = []*Watch{}
+}
+
 //
 // A model has been created.
 // Queue an event.
 func (r *Journal) Created(model Model) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.suspended {
+		return
+	}
+	r.stage(model)
 	if !r.enabled {
 		return
 	}
+	if r.full() {
+		return
+	}
 	r.staged = append(
 		r.staged,
 		&Event{
-			Model:  r.copy(model),
+			Model:  r.snapshot(model),
 			Action: Created,
+			bulk:   r.bulk,
 		})
 }
 
 //
 // A model has been updated.
 // Queue an event.
-func (r *Journal) Updated(model Model, updated Model) {
+// The `labels` describes label changes and may be nil.
+func (r *Journal) Updated(model Model, updated Model, labels *LabelDelta) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.suspended {
+		return
+	}
+	r.stage(model)
 	if !r.enabled {
 		return
 	}
+	if labels == nil {
+		labels = &LabelDelta{}
+	}
+	if r.full() {
+		return
+	}
 	r.staged = append(
 		r.staged,
 		&Event{
-			Model:   r.copy(model),
-			Updated: r.copy(updated),
+			Model:   r.snapshot(model),
+			Updated: r.snapshot(updated),
 			Action:  Updated,
+			Labels:  labels,
+			bulk:    r.bulk,
 		})
 }
 
@@ -233,39 +593,148 @@ func (r *Journal) Updated(model Model, updated Model) {
 func (r *Journal) Deleted(model Model) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.suspended {
+		return
+	}
+	r.stage(model)
 	if !r.enabled {
 		return
 	}
+	if r.full() {
+		return
+	}
 	r.staged = append(
 		r.staged,
 		&Event{
-			Model:  r.copy(model),
+			Model:  r.snapshot(model),
 			Action: Deleted,
+			bulk:   r.bulk,
 		})
 }
 
 //
 // Commit staged events and notify handlers.
+// Events are sequenced and queued to each watch in the
+// order staged while the journal is locked. Events for the
+// same model (key) are delivered FIFO. The events committed
+// are queued contiguously and followed by a TxBoundary for
+// handlers that opt-in. Multiple updates of the same model
+// (key) are collapsed into a single (net) Updated event.
 func (r *Journal) Commit() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if r.generation == nil {
+		r.generation = map[string]uint64{}
+	}
+	for kind, n := range r.changed {
+		r.generation[kind] += n
+	}
+	r.changed = nil
+	r.overflow = false
 	if !r.enabled {
 		return
 	}
-	for _, event := range r.staged {
-		for _, w := range r.watches {
-			w.notify(event)
+	r.staged = r.collapse(r.staged)
+	delivered := make([]int, len(r.watches))
+	for i := 0; i < len(r.staged); {
+		event := r.staged[i]
+		if event.bulk == 0 {
+			r.seq++
+			event.Seq = r.seq
+			for j, w := range r.watches {
+				if w.notify(event) {
+					delivered[j]++
+				}
+			}
+			i++
+			continue
+		}
+		n := i
+		for ; n < len(r.staged) && r.staged[n].bulk == event.bulk; n++ {
+			r.seq++
+			r.staged[n].Seq = r.seq
+		}
+		for j, w := range r.watches {
+			delivered[j] += w.notifyBulk(r.staged[i:n])
+		}
+		i = n
+	}
+	for j, w := range r.watches {
+		if delivered[j] > 0 {
+			w.notifyBoundary(
+				TxBoundary{
+					Seq:   r.seq,
+					Count: delivered[j],
+				})
 		}
 	}
 
 	r.staged = []*Event{}
 }
 
+//
+// Collapse the updates of the same model (key).
+// Updated events for the same key staged (not bulk) without
+// an intervening event for the key are collapsed into a single
+// (net) event positioned at the last update. The net event has
+// the state before the first update, the state after the last
+// update and the merged label changes.
+func (r *Journal) collapse(staged []*Event) []*Event {
+	pending := map[string]int{}
+	dropped := make([]bool, len(staged))
+	for i, event := range staged {
+		key := ref.ToKind(event.Model) + "/" + event.Model.Pk()
+		if event.bulk != 0 || event.Action != Updated {
+			delete(pending, key)
+			continue
+		}
+		if j, found := pending[key]; found {
+			prior := staged[j]
+			staged[i] = &Event{
+				Model:   prior.Model,
+				Updated: event.Updated,
+				Action:  Updated,
+				Labels:  prior.Labels.merge(event.Labels),
+			}
+			dropped[j] = true
+		}
+		pending[key] = i
+	}
+	collapsed := make([]*Event, 0, len(staged))
+	for i, event := range staged {
+		if !dropped[i] {
+			collapsed = append(collapsed, event)
+		}
+	}
+
+	return collapsed
+}
+
+//
+// Begin a bulk operation.
+// Events staged until endBulk() are grouped.
+func (r *Journal) beginBulk() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bulkSeq++
+	r.bulk = r.bulkSeq
+}
+
+//
+// End a bulk operation.
+func (r *Journal) endBulk() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bulk = 0
+}
+
 //
 // Discard staged events.
 func (r *Journal) Unstage() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.changed = nil
+	r.overflow = false
 	if !r.enabled {
 		return
 	}
@@ -273,6 +742,99 @@ func (r *Journal) Unstage() {
 	r.staged = []*Event{}
 }
 
+//
+// Staged events have been discarded because the
+// MaxStaged cap was exceeded.
+func (r *Journal) Overflow() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.overflow
+}
+
+//
+// The staged events cap has been reached.
+// Sets the overflow flag when reached.
+func (r *Journal) full() bool {
+	if r.MaxStaged > 0 && len(r.staged) >= r.MaxStaged {
+		r.overflow = true
+	}
+
+	return r.overflow
+}
+
+//
+// Get the generation of the model (kind).
+// The generation is incremented for each committed
+// change (event) of the kind whether or not the journal
+// is enabled.
+func (r *Journal) Generation(model Model) uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.generation[ref.ToKind(model)]
+}
+
+//
+// Suspend the journal.
+// Changes are not staged (or delivered) while suspended.
+// Fails when watches are active.
+func (r *Journal) Suspend() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.watches) > 0 {
+		return liberr.Wrap(JournalWatchedError)
+	}
+	r.suspended = true
+	return nil
+}
+
+//
+// The journal is suspended.
+func (r *Journal) Suspended() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.suspended
+}
+
+//
+// Resume the journal.
+// The generation of each of the models (kind) is
+// incremented once.
+func (r *Journal) Resume(models ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.generation == nil {
+		r.generation = map[string]uint64{}
+	}
+	for _, m := range models {
+		r.generation[ref.ToKind(m)]++
+	}
+	r.suspended = false
+}
+
+//
+// Stage a change of the model (kind).
+func (r *Journal) stage(model Model) {
+	if r.changed == nil {
+		r.changed = map[string]uint64{}
+	}
+	r.changed[ref.ToKind(model)]++
+}
+
+//
+// Snapshot the model journaled in events.
+// Models implementing Journaled provide the snapshot.
+func (r *Journal) snapshot(model Model) Model {
+	if m, cast := model.(Journaled); cast {
+		snapshot := m.Snapshot()
+		if snapshot == nil {
+			snapshot = model
+		}
+		return snapshot
+	}
+
+	return r.copy(model)
+}
+
 //
 // Copy the model.
 // The model is a pointer must be protected against being
@@ -1,9 +1,199 @@
 package model
 
+import (
+	"bytes"
+	"database/sql"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//
+// Label count SQL.
+var LabelCountSQL = `
+SELECT name, COUNT(*)
+FROM Label
+WHERE kind = :kind
+GROUP BY name
+;
+`
+
+//
+// Label value count SQL.
+var LabelValueCountSQL = `
+SELECT value, COUNT(*)
+FROM Label
+WHERE kind = :kind AND name = :name
+GROUP BY value
+;
+`
+
+//
+// Labels (batch) by parent SQL.
+var LabelParentSQL = `
+SELECT parent, name, value
+FROM Label
+WHERE kind = :kind AND parent IN (
+{{- range $i,$p := .Parents -}}
+{{ if $i }},{{ end }}:p{{ $i }}
+{{- end -}}
+)
+;
+`
+
+//
+// Orphaned label SQL.
+var LabelOrphanSQL = `
+DELETE FROM Label
+WHERE
+{{- range $i,$k := .Kinds }}
+{{ if $i }}OR {{ end }}(kind = '{{ $k.Kind }}' AND parent NOT IN (SELECT {{ $k.Pk.Name }} FROM {{ $k.Table }}))
+{{- end }}
+;
+`
+
+//
+// Label keys SQL.
+var LabelKeySQL = `
+SELECT DISTINCT name
+FROM Label
+{{ if .Kinds -}}
+WHERE kind IN (
+{{- range $i,$k := .Kinds -}}
+{{ if $i }},{{ end }}:k{{ $i }}
+{{- end -}}
+)
+{{ end -}}
+ORDER BY name
+;
+`
+
+//
+// Promoted label (column) backfill SQL.
+var PromoteSQL = `
+UPDATE {{ .Table }}
+SET {{ .Column }} =
+(
+SELECT value
+FROM Label
+WHERE kind = :kind AND
+parent = {{ .Table }}.{{ .Pk.Name }} AND
+name = :name
+)
+;
+`
+
+//
+// Regex used to validate promoted label names.
+var ColumnRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+//
+// Label value types.
+const (
+	LabelString = "string"
+	LabelInt    = "int"
+	LabelBool   = "bool"
+)
+
 //
 // Labels collection.
 type Labels map[string]string
 
+//
+// Typed labels collection.
+// Values may be: (string, int, bool).
+type TypedLabels map[string]interface{}
+
+//
+// Typed labels.
+// Optionally implemented by models having labels
+// with values of non-string types.
+type TypedLabeled interface {
+	// Get typed labels.
+	// May return nil.
+	TypedLabels() TypedLabels
+}
+
+//
+// Label setter.
+// Optionally implemented by models to have labels
+// populated (rehydrated) when fetched using GetAll().
+type LabelSetter interface {
+	// Set the labels.
+	SetLabels(Labels)
+}
+
+//
+// Label changes.
+type LabelDelta struct {
+	// Labels added.
+	Added Labels
+	// Labels removed (prior value).
+	Removed Labels
+	// Labels changed (new value).
+	Changed Labels
+}
+
+//
+// Get whether there are no changes.
+func (d *LabelDelta) Empty() bool {
+	return len(d.Added) == 0 &&
+		len(d.Removed) == 0 &&
+		len(d.Changed) == 0
+}
+
+//
+// Merge the (subsequent) changes.
+// Returns the net changes of both deltas.
+func (d *LabelDelta) merge(next *LabelDelta) *LabelDelta {
+	merged := &LabelDelta{
+		Added:   Labels{},
+		Removed: Labels{},
+		Changed: Labels{},
+	}
+	for name, v := range d.Added {
+		merged.Added[name] = v
+	}
+	for name, v := range d.Removed {
+		merged.Removed[name] = v
+	}
+	for name, v := range d.Changed {
+		merged.Changed[name] = v
+	}
+	for name, v := range next.Added {
+		prior, removed := merged.Removed[name]
+		if removed {
+			delete(merged.Removed, name)
+			if prior != v {
+				merged.Changed[name] = v
+			}
+			continue
+		}
+		merged.Added[name] = v
+	}
+	for name, v := range next.Changed {
+		if _, added := merged.Added[name]; added {
+			merged.Added[name] = v
+			continue
+		}
+		merged.Changed[name] = v
+	}
+	for name, v := range next.Removed {
+		if _, added := merged.Added[name]; added {
+			delete(merged.Added, name)
+			continue
+		}
+		delete(merged.Changed, name)
+		merged.Removed[name] = v
+	}
+
+	return merged
+}
+
 //
 // Label model
 type Label struct {
@@ -12,6 +202,7 @@ type Label struct {
 	Kind   string `sql:"key"`
 	Name   string `sql:"key"`
 	Value  string `sql:""`
+	Type   string `sql:""`
 }
 
 func (l *Label) Pk() string {
@@ -27,7 +218,8 @@ func (l *Label) Equals(other Model) bool {
 		return label.Kind == l.Kind &&
 			label.Parent == l.Parent &&
 			label.Name == l.Name &&
-			label.Value == l.Value
+			label.Value == l.Value &&
+			label.Type == l.Type
 
 	}
 
@@ -37,3 +229,396 @@ func (l *Label) Equals(other Model) bool {
 func (l *Label) Labels() Labels {
 	return nil
 }
+
+//
+// Set the value and type.
+// The `value` must be: (string, int, bool).
+func (l *Label) SetValue(value interface{}) error {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		l.Type = LabelString
+		l.Value = v.String()
+	case reflect.Bool:
+		l.Type = LabelBool
+		l.Value = strconv.FormatBool(v.Bool())
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		l.Type = LabelInt
+		l.Value = strconv.FormatInt(v.Int(), 10)
+	default:
+		return liberr.Wrap(LabelTypeErr)
+	}
+
+	return nil
+}
+
+//
+// Get the typed value.
+func (l *Label) TypedValue() (value interface{}, err error) {
+	switch l.Type {
+	case LabelInt:
+		value, err = strconv.ParseInt(l.Value, 10, 64)
+	case LabelBool:
+		value, err = strconv.ParseBool(l.Value)
+	default:
+		value = l.Value
+	}
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+
+	return
+}
+
+//
+// Count labels by name for the model (kind).
+func (t Table) LabelCounts(model interface{}) (map[string]int64, error) {
+	counts := map[string]int64{}
+	cursor, err := t.DB.Query(
+		LabelCountSQL,
+		sql.Named("kind", t.Kind(model)))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		name := ""
+		count := int64(0)
+		err = cursor.Scan(&name, &count)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		counts[name] = count
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return counts, nil
+}
+
+//
+// Count label values for the model (kind).
+// Returns the number of models having each value of the
+// named label.
+func (t Table) LabelValueCounts(model interface{}, name string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	cursor, err := t.DB.Query(
+		LabelValueCountSQL,
+		sql.Named("kind", t.Kind(model)),
+		sql.Named("name", name))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		value := ""
+		count := int64(0)
+		err = cursor.Scan(&value, &count)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		counts[value] = count
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return counts, nil
+}
+
+//
+// Get the labels for models (of the kind) by parent (PK).
+// Returns a map of: parent => labels. Parents are fetched
+// in batches of GetAllBatch.
+func (t Table) LabelsOf(model interface{}, parents []string) (map[string]Labels, error) {
+	labels := map[string]Labels{}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelParentSQL)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	for begin := 0; begin < len(parents); begin += GetAllBatch {
+		end := begin + GetAllBatch
+		if end > len(parents) {
+			end = len(parents)
+		}
+		batch := parents[begin:end]
+		params := []interface{}{sql.Named("kind", t.Kind(model))}
+		for i, parent := range batch {
+			params = append(params, sql.Named("p"+strconv.Itoa(i), parent))
+		}
+		bfr := &bytes.Buffer{}
+		err = tpl.Execute(
+			bfr,
+			struct {
+				Parents []string
+			}{
+				Parents: batch,
+			})
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		cursor, err := t.DB.Query(bfr.String(), params...)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		for cursor.Next() {
+			parent, name, value := "", "", ""
+			err = cursor.Scan(&parent, &name, &value)
+			if err != nil {
+				cursor.Close()
+				return nil, liberr.Wrap(err)
+			}
+			if _, found := labels[parent]; !found {
+				labels[parent] = Labels{}
+			}
+			labels[parent][name] = value
+		}
+		err = cursor.Err()
+		cursor.Close()
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+	}
+
+	return labels, nil
+}
+
+//
+// Populate (rehydrate) the labels of models implementing
+// LabelSetter. The labels are fetched in batch.
+func (t Table) SetLabels(models []Model) error {
+	parents := []string{}
+	for _, m := range models {
+		if _, cast := m.(LabelSetter); cast {
+			parents = append(parents, m.Pk())
+		}
+	}
+	if len(parents) == 0 {
+		return nil
+	}
+	labels, err := t.LabelsOf(models[0], parents)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	for _, m := range models {
+		if setter, cast := m.(LabelSetter); cast {
+			setter.SetLabels(labels[m.Pk()])
+		}
+	}
+
+	return nil
+}
+
+//
+// List the distinct label names (keys).
+// Optionally filtered by model (kind).
+func (t Table) LabelKeys(models ...interface{}) ([]string, error) {
+	kinds := []string{}
+	params := []interface{}{}
+	for i, m := range models {
+		kinds = append(kinds, t.Kind(m))
+		params = append(
+			params,
+			sql.Named("k"+strconv.Itoa(i), t.Kind(m)))
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelKeySQL)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Kinds []string
+		}{
+			Kinds: kinds,
+		})
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(bfr.String(), params...)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	keys := []string{}
+	for cursor.Next() {
+		name := ""
+		err = cursor.Scan(&name)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		keys = append(keys, name)
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return keys, nil
+}
+
+//
+// Delete orphaned labels.
+// Labels with a (Kind, Parent) that does not reference an
+// existing row of the specified models are deleted. Labels
+// of other kinds are retained since they may be owned by
+// models not specified (or by another namespace).
+// Returns the number of labels deleted.
+func (t Table) CompactLabels(models ...interface{}) (int64, error) {
+	type Kind struct {
+		Kind  string
+		Table string
+		Pk    *Field
+	}
+	kinds := []Kind{}
+	for _, m := range models {
+		if _, cast := m.(*Label); cast {
+			continue
+		}
+		fields, err := t.Fields(m)
+		if err != nil {
+			return 0, liberr.Wrap(err)
+		}
+		kinds = append(
+			kinds,
+			Kind{
+				Kind:  t.Kind(m),
+				Table: t.Name(m),
+				Pk:    t.PkField(fields),
+			})
+	}
+	if len(kinds) == 0 {
+		return 0, nil
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelOrphanSQL)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Kinds []Kind
+		}{
+			Kinds: kinds,
+		})
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	r, err := t.DB.Exec(bfr.String())
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+
+	return n, nil
+}
+
+//
+// Get the column name for a promoted label.
+func PromotedColumn(name string) string {
+	return "label_" + name
+}
+
+//
+// Promote a label to a column.
+// The (indexed) column is added to the model table as needed
+// and populated using the Label table.
+func (t Table) Promote(model interface{}, name string) error {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	table := t.Name(model)
+	column := PromotedColumn(name)
+	columns, err := t.Columns(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	if _, found := columns[column]; !found {
+		_, err = t.DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " TEXT")
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+	}
+	_, err = t.DB.Exec(
+		"CREATE INDEX IF NOT EXISTS " + table + "_" + column + " ON " + table + " (" + column + ")")
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	tpl := template.New("")
+	tpl, err = tpl.Parse(PromoteSQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Table  string
+			Column string
+			Pk     *Field
+		}{
+			Table:  table,
+			Column: column,
+			Pk:     t.PkField(fields),
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	_, err = t.DB.Exec(
+		bfr.String(),
+		sql.Named("kind", t.Kind(model)),
+		sql.Named("name", name))
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// Set (promoted) column values for the model.
+// The `values` is a map of: column => value.
+func (t Table) SetColumns(model interface{}, values map[string]interface{}) error {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	pk := t.PkField(fields)
+	names := []string{}
+	for column := range values {
+		names = append(names, column)
+	}
+	sort.Strings(names)
+	set := []string{}
+	params := []interface{}{}
+	for _, column := range names {
+		set = append(set, column+" = :"+column)
+		params = append(params, sql.Named(column, values[column]))
+	}
+	params = append(params, sql.Named("pk", pk.Pull()))
+	_, err = t.DB.Exec(
+		"UPDATE "+t.Name(model)+" SET "+strings.Join(set, ",")+" WHERE "+pk.Name+" = :pk",
+		params...)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
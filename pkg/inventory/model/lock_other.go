@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package model
+
+import (
+	liberr "github.com/konveyor/controller/pkg/error"
+	"os"
+)
+
+//
+// Lock the file.
+// Not supported on this platform.
+func flock(f *os.File) error {
+	return liberr.Wrap(LockNotSupportedError)
+}
+
+//
+// Unlock the file.
+func funlock(f *os.File) {
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package model
+
+import (
+	"errors"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"os"
+	"syscall"
+)
+
+//
+// Lock the file (exclusive, non-blocking).
+// Returns InUseError when held by another client or process.
+func flock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return liberr.Wrap(InUseError)
+		}
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// Unlock the file.
+func funlock(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	liberr "github.com/konveyor/controller/pkg/error"
 	_ "github.com/mattn/go-sqlite3"
 	"reflect"
 )
@@ -19,6 +22,41 @@ type DBTX interface {
 	QueryRow(string, ...interface{}) *sql.Row
 }
 
+//
+// Context aware database client.
+// Implemented by both sql.DB and sql.Tx.
+type ctxDBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+//
+// Database client bound to a context.
+// Statements are aborted when the context is done.
+type ctxDB struct {
+	ctx context.Context
+	db  ctxDBTX
+}
+
+//
+// Execute a statement.
+func (r *ctxDB) Exec(stmt string, params ...interface{}) (sql.Result, error) {
+	return r.db.ExecContext(r.ctx, stmt, params...)
+}
+
+//
+// Execute a query.
+func (r *ctxDB) Query(stmt string, params ...interface{}) (*sql.Rows, error) {
+	return r.db.QueryContext(r.ctx, stmt, params...)
+}
+
+//
+// Execute a query (single row).
+func (r *ctxDB) QueryRow(stmt string, params ...interface{}) *sql.Row {
+	return r.db.QueryRowContext(r.ctx, stmt, params...)
+}
+
 //
 // Database interface.
 // Support model `Scan` taking either sql.Row or sql.Rows.
@@ -26,6 +64,78 @@ type Row interface {
 	Scan(...interface{}) error
 }
 
+//
+// Optional (nullable) field value.
+// Stored as NULL when not valid. Like the sql.Null* types,
+// supports distinguishing NULL from the zero value. Example:
+//   type Person struct {
+//       ID       int              `sql:"pk"`
+//       Nickname Optional[string] `sql:""`
+//   }
+type Optional[T ~string | ~bool | ~int | ~int8 | ~int16 | ~int32 | ~int64] struct {
+	// The value.
+	V T
+	// The value is valid (not NULL).
+	Valid bool
+}
+
+//
+// Scan implements sql.Scanner.
+// The value is converted using the sql.Null* type for
+// the kind of T.
+func (o *Optional[T]) Scan(value interface{}) (err error) {
+	v := reflect.ValueOf(&o.V).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		n := sql.NullString{}
+		err = n.Scan(value)
+		if err != nil {
+			return
+		}
+		v.SetString(n.String)
+		o.Valid = n.Valid
+	case reflect.Bool:
+		n := sql.NullBool{}
+		err = n.Scan(value)
+		if err != nil {
+			return
+		}
+		v.SetBool(n.Bool)
+		o.Valid = n.Valid
+	default:
+		n := sql.NullInt64{}
+		err = n.Scan(value)
+		if err != nil {
+			return
+		}
+		if v.OverflowInt(n.Int64) {
+			err = liberr.Wrap(OverflowErr)
+			return
+		}
+		v.SetInt(n.Int64)
+		o.Valid = n.Valid
+	}
+
+	return
+}
+
+//
+// Value implements driver.Valuer.
+func (o Optional[T]) Value() (driver.Value, error) {
+	if !o.Valid {
+		return nil, nil
+	}
+	v := reflect.ValueOf(o.V)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	default:
+		return v.Int(), nil
+	}
+}
+
 //
 // Page.
 // Support pagination.
@@ -79,6 +189,15 @@ type Model interface {
 	Labels() Labels
 }
 
+//
+// Table name.
+// Optionally implemented by models to override the
+// table name derived from the model type.
+type TableNamed interface {
+	// Get the table name.
+	TableName() string
+}
+
 type Base struct {
 	// Primary key (digest).
 	PK string `sql:"pk"`
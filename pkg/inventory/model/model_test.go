@@ -1,11 +1,19 @@
 package model
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"github.com/go-logr/logr"
 	"github.com/konveyor/controller/pkg/ref"
 	"github.com/onsi/gomega"
 	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -20,6 +28,7 @@ type TestObject struct {
 	Int32  int32  `sql:""`
 	Bool   bool   `sql:""`
 	labels Labels
+	typed  TypedLabels
 }
 
 func (m *TestObject) Pk() string {
@@ -41,273 +50,7009 @@ func (m *TestObject) Labels() Labels {
 	return m.labels
 }
 
+func (m *TestObject) TypedLabels() TypedLabels {
+	return m.typed
+}
+
+func (m *TestObject) SetLabels(labels Labels) {
+	m.labels = labels
+}
+
+type TestRelated struct {
+	PK     string `sql:"pk"`
+	ID     int    `sql:"key"`
+	Parent string `sql:""`
+	Name   string `sql:""`
+}
+
+func (m *TestRelated) Pk() string {
+	return m.PK
+}
+
+func (m *TestRelated) String() string {
+	return fmt.Sprintf(
+		"TestRelated: id: %d, name:%s",
+		m.ID,
+		m.Name)
+}
+
+func (m *TestRelated) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestRelated) Labels() Labels {
+	return nil
+}
+
+type TestNamed struct {
+	PK     string `sql:"pk"`
+	ID     int    `sql:"key"`
+	Name   string `sql:""`
+	labels Labels
+}
+
+func (m *TestNamed) TableName() string {
+	return "named_objects"
+}
+
+func (m *TestNamed) Pk() string {
+	return m.PK
+}
+
+func (m *TestNamed) String() string {
+	return fmt.Sprintf(
+		"TestNamed: id: %d, name:%s",
+		m.ID,
+		m.Name)
+}
+
+func (m *TestNamed) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestNamed) Labels() Labels {
+	return m.labels
+}
+
+type TestIndexed struct {
+	PK      string `sql:"pk"`
+	Name    string `sql:"index(active:Deleted = 0)"`
+	Zone    string `sql:"index(active),index(zone)"`
+	Deleted bool   `sql:""`
+}
+
+func (m *TestIndexed) Pk() string {
+	return m.PK
+}
+
+func (m *TestIndexed) String() string {
+	return m.Name
+}
+
+func (m *TestIndexed) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestIndexed) Labels() Labels {
+	return nil
+}
+
+type TestDefaulted struct {
+	PK     string `sql:"pk"`
+	Name   string `sql:"" default:"Elmer's"`
+	Age    int    `sql:"" default:"18"`
+	Active bool   `sql:"" default:"true"`
+	Other  string `sql:""`
+}
+
+func (m *TestDefaulted) Pk() string {
+	return m.PK
+}
+
+func (m *TestDefaulted) String() string {
+	return m.Name
+}
+
+func (m *TestDefaulted) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestDefaulted) Labels() Labels {
+	return nil
+}
+
+type TestDefaultedNullable struct {
+	PK     string         `sql:"pk"`
+	Active Optional[bool] `sql:"" default:"true"`
+}
+
+func (m *TestDefaultedNullable) Pk() string {
+	return m.PK
+}
+
+func (m *TestDefaultedNullable) String() string {
+	return m.PK
+}
+
+func (m *TestDefaultedNullable) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestDefaultedNullable) Labels() Labels {
+	return nil
+}
+
+type TestRequired struct {
+	PK    string `sql:"pk"`
+	Name  string `sql:"notnull"`
+	Age   int    `sql:"notnull" default:"18"`
+	Other string `sql:""`
+}
+
+func (m *TestRequired) Pk() string {
+	return m.PK
+}
+
+func (m *TestRequired) String() string {
+	return m.Name
+}
+
+func (m *TestRequired) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestRequired) Labels() Labels {
+	return nil
+}
+
+type TestNillable struct {
+	PK   string        `sql:"pk"`
+	Rank Optional[int] `sql:"notnull"`
+	Nick *string       `sql:"notnull"`
+	Note *string       `sql:""`
+}
+
+func (m *TestNillable) Pk() string {
+	return m.PK
+}
+
+func (m *TestNillable) String() string {
+	return m.PK
+}
+
+func (m *TestNillable) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestNillable) Labels() Labels {
+	return nil
+}
+
+type TestNumbered struct {
+	ID   int    `sql:"pk"`
+	Name string `sql:""`
+}
+
+func (m *TestNumbered) Pk() string {
+	return strconv.Itoa(m.ID)
+}
+
+func (m *TestNumbered) String() string {
+	return m.Name
+}
+
+func (m *TestNumbered) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestNumbered) Labels() Labels {
+	return nil
+}
+
+type TestSnake struct {
+	PK        string `sql:"pk"`
+	FirstName string `sql:"key"`
+	VMCount   int    `sql:"index(count:VMCount > 0)"`
+	labels    Labels
+}
+
+func (m *TestSnake) Pk() string {
+	return m.PK
+}
+
+func (m *TestSnake) String() string {
+	return m.FirstName
+}
+
+func (m *TestSnake) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestSnake) Labels() Labels {
+	return m.labels
+}
+
+type TestComputed struct {
+	PK    string `sql:"pk"`
+	First string `sql:""`
+	Last  string `sql:""`
+	Age   int    `sql:""`
+	Full  string `computed:"First || ' ' || Last"`
+	Adult bool   `computed:"Age >= 18"`
+}
+
+func (m *TestComputed) Pk() string {
+	return m.PK
+}
+
+func (m *TestComputed) String() string {
+	return m.Full
+}
+
+func (m *TestComputed) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestComputed) Labels() Labels {
+	return nil
+}
+
+//
+// Model (not pointer).
+type TestValue struct {
+	PK string `sql:"pk"`
+}
+
+func (m TestValue) Pk() string {
+	return m.PK
+}
+
+func (m TestValue) String() string {
+	return m.PK
+}
+
+func (m TestValue) Equals(other Model) bool {
+	return false
+}
+
+func (m TestValue) Labels() Labels {
+	return nil
+}
+
+type TestDocument struct {
+	PK    string `sql:"pk"`
+	Title string `sql:"fts"`
+	Body  string `sql:"fts"`
+	Rev   int    `sql:""`
+}
+
+func (m *TestDocument) Pk() string {
+	return m.PK
+}
+
+func (m *TestDocument) String() string {
+	return m.Title
+}
+
+func (m *TestDocument) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestDocument) Labels() Labels {
+	return nil
+}
+
+type TestOptional struct {
+	PK    string           `sql:"pk"`
+	Note  sql.NullString   `sql:""`
+	Count sql.NullInt64    `sql:""`
+	Rank  Optional[int]    `sql:""`
+	Flag  Optional[bool]   `sql:""`
+	Alias Optional[string] `sql:""`
+}
+
+func (m *TestOptional) Pk() string {
+	return m.PK
+}
+
+func (m *TestOptional) String() string {
+	return m.PK
+}
+
+func (m *TestOptional) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestOptional) Labels() Labels {
+	return nil
+}
+
+type TestBadIndex struct {
+	PK   string `sql:"pk"`
+	Name string `sql:"index(bad:Other IS NULL)"`
+}
+
+func (m *TestBadIndex) Pk() string {
+	return m.PK
+}
+
+func (m *TestBadIndex) String() string {
+	return m.Name
+}
+
+func (m *TestBadIndex) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestBadIndex) Labels() Labels {
+	return nil
+}
+
+type TestTagged struct {
+	PK   string `sql:"pk"`
+	Tags string `sql:""`
+}
+
+func (m *TestTagged) Pk() string {
+	return m.PK
+}
+
+func (m *TestTagged) String() string {
+	return m.Tags
+}
+
+func (m *TestTagged) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestTagged) Labels() Labels {
+	return nil
+}
+
+type TestParent struct {
+	PK     string `sql:"pk"`
+	Name   string `sql:""`
+	labels Labels
+}
+
+func (m *TestParent) Pk() string {
+	return m.PK
+}
+
+func (m *TestParent) String() string {
+	return m.Name
+}
+
+func (m *TestParent) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestParent) Labels() Labels {
+	return m.labels
+}
+
+type TestChild struct {
+	PK     string `sql:"pk"`
+	Parent string `sql:"fk:TestParent(PK)"`
+	labels Labels
+}
+
+func (m *TestChild) Pk() string {
+	return m.PK
+}
+
+func (m *TestChild) String() string {
+	return m.PK
+}
+
+func (m *TestChild) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestChild) Labels() Labels {
+	return m.labels
+}
+
+type TestExpiring struct {
+	PK      string `sql:"pk"`
+	Expires int64  `sql:"expires"`
+	labels  Labels
+}
+
+func (m *TestExpiring) Pk() string {
+	return m.PK
+}
+
+func (m *TestExpiring) String() string {
+	return m.PK
+}
+
+func (m *TestExpiring) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestExpiring) Labels() Labels {
+	return m.labels
+}
+
+type TestLarge struct {
+	PK   string `sql:"pk"`
+	ID   int    `sql:"key"`
+	Name string `sql:""`
+	data []int64
+	// Snapshot: 0=deep copy, 1=custom, 2=shared (none).
+	snapshot int8
+}
+
+func (m *TestLarge) Pk() string {
+	return m.PK
+}
+
+func (m *TestLarge) String() string {
+	return fmt.Sprintf(
+		"TestLarge: id: %d, name:%s",
+		m.ID,
+		m.Name)
+}
+
+func (m *TestLarge) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestLarge) Labels() Labels {
+	return nil
+}
+
+func (m *TestLarge) Snapshot() Model {
+	switch m.snapshot {
+	case 1:
+		return &TestLarge{
+			PK:       m.PK,
+			ID:       m.ID,
+			Name:     m.Name,
+			snapshot: m.snapshot,
+		}
+	case 2:
+		return nil
+	default:
+		copied := *m
+		copied.data = append([]int64{}, m.data...)
+		return &copied
+	}
+}
+
+type TestUnique struct {
+	PK     string `sql:"pk"`
+	Email  string `sql:"unique(email)"`
+	Region string `sql:"unique(host)"`
+	Host   string `sql:"unique(host)"`
+	Name   string `sql:""`
+}
+
+func (m *TestUnique) Pk() string {
+	return m.PK
+}
+
+func (m *TestUnique) String() string {
+	return fmt.Sprintf(
+		"TestUnique: pk: %s, name:%s",
+		m.PK,
+		m.Name)
+}
+
+func (m *TestUnique) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestUnique) Labels() Labels {
+	return nil
+}
+
+type TestPhase int
+
+const (
+	TestPending TestPhase = iota
+	TestRunning
+	TestSucceeded
+	TestFailed
+)
+
+type TestEnum struct {
+	PK    string    `sql:"pk"`
+	Phase TestPhase `sql:"index(phase)"`
+	Code  int8      `sql:""`
+}
+
+func (m *TestEnum) Pk() string {
+	return m.PK
+}
+
+func (m *TestEnum) String() string {
+	return fmt.Sprintf(
+		"TestEnum: pk: %s, phase:%d",
+		m.PK,
+		m.Phase)
+}
+
+func (m *TestEnum) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestEnum) Labels() Labels {
+	return nil
+}
+
+type TestTimer struct {
+	PK       string        `sql:"pk"`
+	Timeout  time.Duration `sql:"index(timeout)"`
+	Interval time.Duration `sql:""`
+}
+
+func (m *TestTimer) Pk() string {
+	return m.PK
+}
+
+func (m *TestTimer) String() string {
+	return fmt.Sprintf(
+		"TestTimer: pk: %s, timeout:%s",
+		m.PK,
+		m.Timeout)
+}
+
+func (m *TestTimer) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestTimer) Labels() Labels {
+	return nil
+}
+
+type TestStamped struct {
+	PK      string `sql:"pk"`
+	Name    string `sql:""`
+	Age     int    `sql:""`
+	Updated int64  `sql:"updated,index(updated)"`
+}
+
+func (m *TestStamped) Pk() string {
+	return m.PK
+}
+
+func (m *TestStamped) String() string {
+	return fmt.Sprintf(
+		"TestStamped: pk: %s, name:%s",
+		m.PK,
+		m.Name)
+}
+
+func (m *TestStamped) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestStamped) Labels() Labels {
+	return nil
+}
+
+type TestGenerated struct {
+	PK   string `sql:"pk"`
+	Name string `sql:""`
+}
+
+func (m *TestGenerated) Pk() string {
+	return m.PK
+}
+
+func (m *TestGenerated) GenerateID() string {
+	return "G-" + m.Name
+}
+
+func (m *TestGenerated) String() string {
+	return fmt.Sprintf(
+		"TestGenerated: pk: %s, name:%s",
+		m.PK,
+		m.Name)
+}
+
+func (m *TestGenerated) Equals(other Model) bool {
+	return false
+}
+
+func (m *TestGenerated) Labels() Labels {
+	return nil
+}
+
+type TestEventHandler struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (w *TestEventHandler) add(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.events = append(w.events, e)
+}
+
+func (w *TestEventHandler) Created(e Event) {
+	w.add(e)
+}
+
+func (w *TestEventHandler) Updated(e Event) {
+	w.add(e)
+}
+
+func (w *TestEventHandler) Deleted(e Event) {
+	w.add(e)
+}
+
+func (w *TestEventHandler) Error(err error) {
+}
+
+func (w *TestEventHandler) End() {
+}
+
+//
+// Get the PK of each event (model) with the action.
+func (w *TestEventHandler) keys(action int8) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	keys := []string{}
+	for _, e := range w.events {
+		if e.Action == action {
+			keys = append(keys, e.Model.Pk())
+		}
+	}
+	return keys
+}
+
+type TestLogger struct {
+	entries []string
+	values  [][]interface{}
+}
+
+func (l *TestLogger) Info(message string, kvpair ...interface{}) {
+	l.entries = append(l.entries, message)
+	l.values = append(l.values, kvpair)
+}
+
+func (l *TestLogger) Enabled() bool {
+	return true
+}
+
+func (l *TestLogger) Error(err error, message string, kvpair ...interface{}) {
+	l.entries = append(l.entries, message)
+}
+
+func (l *TestLogger) V(level int) logr.InfoLogger {
+	return l
+}
+
+func (l *TestLogger) WithValues(kvpair ...interface{}) logr.Logger {
+	return l
+}
+
+func (l *TestLogger) WithName(name string) logr.Logger {
+	return l
+}
+
 type TestHandler struct {
+	mutex   sync.Mutex
 	name    string
 	created []int
+	resync  []int
 	updated []int
 	deleted []int
+	labels  []*LabelDelta
 	err     []error
 	done    bool
 }
 
-func (w *TestHandler) Created(e Event) {
-	if object, cast := e.Model.(*TestObject); cast {
-		w.created = append(w.created, object.ID)
+func (w *TestHandler) Created(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if object, cast := e.Model.(*TestObject); cast {
+		if e.Resync {
+			w.resync = append(w.resync, object.ID)
+			return
+		}
+		w.created = append(w.created, object.ID)
+	}
+}
+
+func (w *TestHandler) Updated(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if object, cast := e.Model.(*TestObject); cast {
+		w.updated = append(w.updated, object.ID)
+		w.labels = append(w.labels, e.Labels)
+	}
+}
+func (w *TestHandler) Deleted(e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if object, cast := e.Model.(*TestObject); cast {
+		w.deleted = append(w.deleted, object.ID)
+	}
+}
+
+func (w *TestHandler) Error(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.err = append(w.err, err)
+}
+
+func (w *TestHandler) End() {
+}
+
+func TestCRUD(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	objA := &TestObject{
+		ID:    0,
+		Name:  "Elmer",
+		Age:   18,
+		Int8:  8,
+		Int16: 16,
+		Int32: 32,
+		Bool:  true,
+		labels: Labels{
+			"n1": "v1",
+			"n2": "v2",
+		},
+	}
+	assertEqual := func(a, b *TestObject) {
+		g.Expect(a.PK).To(gomega.Equal(b.PK))
+		g.Expect(a.ID).To(gomega.Equal(b.ID))
+		g.Expect(a.Name).To(gomega.Equal(b.Name))
+		g.Expect(a.Age).To(gomega.Equal(b.Age))
+		g.Expect(a.Int8).To(gomega.Equal(b.Int8))
+		g.Expect(a.Int16).To(gomega.Equal(b.Int16))
+		g.Expect(a.Int32).To(gomega.Equal(b.Int32))
+		g.Expect(a.Bool).To(gomega.Equal(b.Bool))
+		for k, v := range objA.labels {
+			l := &Label{
+				Kind:   ref.ToKind(a),
+				Parent: a.PK,
+				Name:   k,
+			}
+			g.Expect(DB.Get(l)).To(gomega.BeNil())
+			g.Expect(v).To(gomega.Equal(l.Value))
+		}
+	}
+	// Insert
+	err = DB.Insert(objA)
+	g.Expect(err).To(gomega.BeNil())
+	objB := &TestObject{ID: objA.ID}
+	// Get
+	err = DB.Get(objB)
+	g.Expect(err).To(gomega.BeNil())
+	assertEqual(objA, objB)
+	// Update
+	objA.Name = "Larry"
+	objA.Age = 21
+	objA.Bool = false
+	err = DB.Update(objA)
+	g.Expect(err).To(gomega.BeNil())
+	// Get
+	objB = &TestObject{ID: objA.ID}
+	err = DB.Get(objB)
+	g.Expect(err).To(gomega.BeNil())
+	assertEqual(objA, objB)
+	// Delete
+	objA = &TestObject{ID: objA.ID}
+	err = DB.Delete(objA)
+	g.Expect(err).To(gomega.BeNil())
+	// Get (not found)
+	objB = &TestObject{ID: objA.ID}
+	err = DB.Get(objB)
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+}
+
+func TestTransactions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Begin
+	tx, err := DB.Begin()
+	defer tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Get (not found)
+	object = &TestObject{ID: object.ID}
+	err = DB.Get(object)
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	tx.Commit()
+	// Get (found)
+	object = &TestObject{ID: object.ID}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+}
+
+func TestGetForUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Insert
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	tx, err := DB.GetForUpdate(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
+	tx.Commit()
+}
+
+func TestListForUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestObject{ID: i, Age: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ages := func() []int {
+		list := []TestObject{}
+		err := DB.List(&list, ListOptions{Sort: []int{2}})
+		g.Expect(err).To(gomega.BeNil())
+		ages := []int{}
+		for _, m := range list {
+			ages = append(ages, m.Age)
+		}
+		return ages
+	}
+	// Commit.
+	list := []TestObject{}
+	tx, err := DB.ListForUpdate(&list, ListOptions{Predicate: Lt("ID", 3)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
+	g.Expect(len(list)).To(gomega.Equal(3))
+	for i := range list {
+		list[i].Age += 10
+		err = DB.Update(&list[i])
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ages()).To(gomega.Equal([]int{10, 11, 12, 3, 4}))
+	// Rollback.
+	list = []TestObject{}
+	tx, err = DB.ListForUpdate(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(5))
+	for i := range list {
+		list[i].Age = 0
+		err = DB.Update(&list[i])
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ages()).To(gomega.Equal([]int{10, 11, 12, 3, 4}))
+	// Error.
+	_, err = DB.ListForUpdate(&list, ListOptions{Predicate: Eq("Unknown", 1)})
+	g.Expect(err).ToNot(gomega.BeNil())
+	g.Expect(DB.(*Client).tx).To(gomega.BeNil())
+}
+
+func TestGetManyForUpdate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestNumbered{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	keys := []interface{}{}
+	for i := 0; i < 5; i++ {
+		object := &TestObject{ID: i, Age: i}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+		keys = append(keys, object.PK)
+	}
+	// Sorted and de-duplicated.
+	requested := []interface{}{keys[3], keys[1], keys[3], keys[0]}
+	tx, models, err := DB.GetManyForUpdate(&TestObject{}, requested)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
+	g.Expect(len(models)).To(gomega.Equal(3))
+	for i := 1; i < len(models); i++ {
+		g.Expect(models[i-1].Pk() < models[i].Pk()).To(gomega.BeTrue())
+	}
+	for _, m := range models {
+		m.(*TestObject).Age += 10
+		err = DB.Update(m)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	ages := []int{}
+	for _, m := range list {
+		ages = append(ages, m.Age)
+	}
+	g.Expect(ages).To(gomega.Equal([]int{10, 11, 2, 13, 4}))
+	// Sorted by (typed) PK value.
+	for i := 8; i < 12; i++ {
+		err = DB.Insert(&TestNumbered{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	tx, models, err = DB.GetManyForUpdate(
+		&TestNumbered{},
+		[]interface{}{10, "9", 11, 8, 10})
+	g.Expect(err).To(gomega.BeNil())
+	ids := []int{}
+	for _, m := range models {
+		ids = append(ids, m.(*TestNumbered).ID)
+	}
+	g.Expect(ids).To(gomega.Equal([]int{8, 9, 10, 11}))
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	// Not found.
+	_, _, err = DB.GetManyForUpdate(
+		&TestObject{},
+		[]interface{}{keys[0], "unknown"})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	g.Expect(DB.(*Client).tx).To(gomega.BeNil())
+	// Concurrent (overlapping sets in different orders).
+	reversed := []interface{}{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		reversed = append(reversed, keys[i])
+	}
+	N := 10
+	wg := sync.WaitGroup{}
+	errs := make(chan error, N)
+	for n := 0; n < N; n++ {
+		wg.Add(1)
+		order := keys
+		if n%2 == 1 {
+			order = reversed
+		}
+		go func(order []interface{}) {
+			defer wg.Done()
+			tx, models, err := DB.GetManyForUpdate(&TestObject{}, order)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer tx.End()
+			for _, m := range models {
+				m.(*TestObject).Age++
+				err = DB.Update(m)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- tx.Commit()
+		}(order)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		g.Expect(err).To(gomega.BeNil())
+	}
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	ages = []int{}
+	for _, m := range list {
+		ages = append(ages, m.Age)
+	}
+	g.Expect(ages).To(gomega.Equal([]int{20, 21, 12, 23, 14}))
+}
+
+func TestUpdateLocked(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 0, Age: 1})
+	g.Expect(err).To(gomega.BeNil())
+	// Success.
+	err = DB.UpdateLocked(
+		&TestObject{ID: 0},
+		func(m Model) error {
+			m.(*TestObject).Age++
+			return nil
+		})
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{ID: 0}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Age).To(gomega.Equal(2))
+	// Rollback.
+	err = DB.UpdateLocked(
+		&TestObject{ID: 0},
+		func(m Model) error {
+			m.(*TestObject).Age = 100
+			return ConflictError
+		})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	object = &TestObject{ID: 0}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Age).To(gomega.Equal(2))
+	// Not found.
+	called := false
+	err = DB.UpdateLocked(
+		&TestObject{ID: 1},
+		func(m Model) error {
+			called = true
+			return nil
+		})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	g.Expect(called).To(gomega.BeFalse())
+	// Not locked (released).
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+}
+
+func TestList(t *testing.T) {
+	var err error
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID: i,
+			labels: Labels{
+				"id": fmt.Sprintf("v%d", i),
+			},
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// List all.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(10))
+	// List = (single).
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Eq("ID", 0),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].ID).To(gomega.Equal(0))
+	// List != AND
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: And( // Even only.
+				Neq("ID", 1),
+				Neq("ID", 3),
+				Neq("ID", 5),
+				Neq("ID", 7),
+				Neq("ID", 9)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(5))
+	g.Expect(list[0].ID).To(gomega.Equal(0))
+	g.Expect(list[1].ID).To(gomega.Equal(2))
+	g.Expect(list[2].ID).To(gomega.Equal(4))
+	g.Expect(list[3].ID).To(gomega.Equal(6))
+	g.Expect(list[4].ID).To(gomega.Equal(8))
+	// List OR =.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Or(
+				Eq("ID", 0),
+				Eq("ID", 6)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(0))
+	g.Expect(list[1].ID).To(gomega.Equal(6))
+	// List < (lt).
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Lt("ID", 2),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(0))
+	g.Expect(list[1].ID).To(gomega.Equal(1))
+	// List > (gt).
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Gt("ID", 7),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(8))
+	g.Expect(list[1].ID).To(gomega.Equal(9))
+	// By label.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort: []int{2},
+			Predicate: Or(
+				Match(Labels{"id": "v4"}),
+				Eq("ID", 8)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(4))
+	g.Expect(list[1].ID).To(gomega.Equal(8))
+	// Find.
+	found := []TestObject{}
+	err = DB.Find(
+		&found,
+		Or(
+			Match(Labels{"id": "v4"}),
+			Eq("ID", 8)))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found).To(gomega.ConsistOf(list))
+	// Test count all.
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(10)))
+	// Test count with predicate.
+	count, err = DB.Count(&TestObject{}, Gt("ID", 0))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(9)))
+	// Test count with options.
+	options := ListOptions{
+		Predicate: Or(
+			Match(Labels{"id": "v4"}),
+			Gt("ID", 6)),
+	}
+	list = []TestObject{}
+	err = DB.List(&list, options)
+	g.Expect(err).To(gomega.BeNil())
+	count, err = DB.CountOptions(&TestObject{}, options)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(len(list))))
+	g.Expect(count).To(gomega.Equal(int64(4)))
+	// Pagination ignored.
+	options.Sort = []int{2}
+	options.Page = &Page{Limit: 1}
+	count, err = DB.CountOptions(&TestObject{}, options)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(4)))
+}
+
+func TestTypedLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID: i,
+			labels: Labels{
+				"name": fmt.Sprintf("n%d", i),
+			},
+			typed: TypedLabels{
+				"rank":  i * 10,
+				"even":  i%2 == 0,
+				"group": "A",
+			},
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Stored with type.
+	object := &TestObject{ID: 3}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	label := &Label{
+		Kind:   ref.ToKind(object),
+		Parent: object.PK,
+		Name:   "rank",
+	}
+	err = DB.Get(label)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(label.Type).To(gomega.Equal(LabelInt))
+	v, err := label.TypedValue()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(v).To(gomega.Equal(int64(30)))
+	// Range.
+	list := []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort: []int{2},
+			Predicate: And(
+				LabelGt("rank", 20),
+				LabelLt("rank", 70)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(4))
+	g.Expect(list[0].ID).To(gomega.Equal(3))
+	g.Expect(list[3].ID).To(gomega.Equal(6))
+	// Numeric (not lexical) comparison.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: LabelGt("rank", 9),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(9))
+	// Only integer labels compared.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: LabelLt("name", 100),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(0))
+	// Mixed with string labels.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort: []int{2},
+			Predicate: And(
+				Match(Labels{"even": "true"}),
+				LabelGt("rank", 40)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(6))
+	g.Expect(list[1].ID).To(gomega.Equal(8))
+}
+
+func TestReplaceLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	client := DB.(*Client)
+	labels := Labels{}
+	for i := 0; i < 10; i++ {
+		labels[fmt.Sprintf("n%d", i)] = fmt.Sprintf("v%d", i)
+	}
+	object := &TestObject{
+		ID:     0,
+		labels: labels,
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Unchanged.
+	delta, err := client.replaceLabels(Table{DB: client.db}, object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(delta.Empty()).To(gomega.BeTrue())
+	// Change one of ten.
+	labels["n4"] = "changed"
+	delta, err = client.replaceLabels(Table{DB: client.db}, object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(delta.Added)).To(gomega.Equal(0))
+	g.Expect(len(delta.Removed)).To(gomega.Equal(0))
+	g.Expect(delta.Changed).To(gomega.Equal(Labels{"n4": "changed"}))
+	// Add and remove.
+	delete(labels, "n0")
+	labels["n10"] = "v10"
+	delta, err = client.replaceLabels(Table{DB: client.db}, object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(delta.Added).To(gomega.Equal(Labels{"n10": "v10"}))
+	g.Expect(delta.Removed).To(gomega.Equal(Labels{"n0": "v0"}))
+	g.Expect(len(delta.Changed)).To(gomega.Equal(0))
+	// Stored.
+	list := []Label{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Eq("Parent", object.PK),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	stored := Labels{}
+	for _, l := range list {
+		stored[l.Name] = l.Value
+	}
+	g.Expect(stored).To(gomega.Equal(labels))
+}
+
+func TestLabelCounts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 6; i++ {
+		labels := Labels{"all": "yes"}
+		if i%2 == 0 {
+			labels["even"] = "yes"
+		}
+		if i == 5 {
+			labels["five"] = "yes"
+		}
+		object := &TestObject{
+			ID:     i,
+			labels: labels,
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	counts, err := DB.LabelCounts(&TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(counts).To(gomega.Equal(
+		map[string]int64{
+			"all":  6,
+			"even": 3,
+			"five": 1,
+		}))
+	// Other kind.
+	counts, err = DB.LabelCounts(&Label{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(counts)).To(gomega.Equal(0))
+}
+
+func TestLabelValueCounts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestNamed{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	zones := []string{"east", "west", "east", "north", "east"}
+	for i, zone := range zones {
+		err = DB.Insert(
+			&TestObject{
+				ID: i,
+				labels: Labels{
+					"zone": zone,
+					"tier": "web",
+				},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.Insert(&TestObject{ID: 5})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(
+		&TestNamed{
+			PK: "0",
+			labels: Labels{
+				"zone": "east",
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	counts, err := DB.LabelValueCounts(&TestObject{}, "zone")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(counts).To(gomega.Equal(
+		map[string]int64{
+			"east":  3,
+			"west":  1,
+			"north": 1,
+		}))
+	counts, err = DB.LabelValueCounts(&TestNamed{}, "zone")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(counts).To(gomega.Equal(map[string]int64{"east": 1}))
+	counts, err = DB.LabelValueCounts(&TestObject{}, "unknown")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(counts)).To(gomega.Equal(0))
+}
+
+func TestSubquery(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		object := &TestObject{ID: i}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+		if i%3 != 0 {
+			continue
+		}
+		related := &TestRelated{
+			ID:     i,
+			Parent: object.PK,
+			Name:   "related",
+		}
+		if i == 9 {
+			related.Name = "other"
+		}
+		err = DB.Insert(related)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Matched.
+	list := []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort: []int{2},
+			Predicate: And(
+				Gt("ID", 0),
+				Subquery(
+					"PK",
+					&TestRelated{},
+					"Parent",
+					Eq("Name", "related"))),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(3))
+	g.Expect(list[1].ID).To(gomega.Equal(6))
+	// No inner predicate.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Subquery("PK", &TestRelated{}, "Parent", nil),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(4))
+	// Empty inner result.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Subquery(
+				"PK",
+				&TestRelated{},
+				"Parent",
+				Eq("Name", "none")),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(0))
+	// Invalid field.
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Subquery("PK", &TestRelated{}, "Unknown", nil),
+		})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+}
+
+func TestRawSQL(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID:   i,
+			Name: fmt.Sprintf("n%d", i),
+			Age:  i * 10,
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Query.
+	list, err := DB.Query(
+		&TestObject{},
+		"SELECT id, name, age, 'ignored' AS extra "+
+			"FROM TestObject WHERE Age > ? ORDER BY ID DESC",
+		60)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	object := list[0].(*TestObject)
+	g.Expect(object.ID).To(gomega.Equal(9))
+	g.Expect(object.Name).To(gomega.Equal("n9"))
+	g.Expect(object.Age).To(gomega.Equal(90))
+	g.Expect(object.PK).To(gomega.Equal(""))
+	// Exec.
+	n, err := DB.Exec(
+		"UPDATE TestObject SET Name = ? WHERE Age < ?",
+		"young",
+		30)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+	count, err := DB.Count(&TestObject{}, Eq("Name", "young"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(3)))
+}
+
+func TestRender(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	normalized := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	table := Table{}
+	// List.
+	stmt, args, err := table.Render(
+		&TestObject{},
+		ListOptions{
+			Sort: []int{2},
+			Page: &Page{Limit: 10, Offset: 20},
+			Predicate: Or(
+				And(
+					Eq("Name", "Elmer"),
+					Gt("Age", 17)),
+				Lt("ID", 3)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"SELECT PK ,ID ,Name ,Age ,Int8 ,Int16 ,Int32 ,Bool " +
+			"FROM TestObject " +
+			"WHERE Name = :Name0 AND Age > :Age1 OR ID < :ID2 " +
+			"ORDER BY 2 " +
+			"LIMIT 10 OFFSET 20 ;"))
+	g.Expect(args).To(gomega.Equal(
+		[]interface{}{
+			sql.Named("Name0", "Elmer"),
+			sql.Named("Age1", int64(17)),
+			sql.Named("ID2", int64(3)),
+		}))
+	// No predicate.
+	stmt, args, err = table.Render(&Label{}, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"SELECT PK ,Parent ,Kind ,Name ,Value ,Type FROM Label ;"))
+	g.Expect(len(args)).To(gomega.Equal(0))
+	// Invalid.
+	_, _, err = table.Render(&TestObject{}, ListOptions{Predicate: Eq("X", 1)})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+	// Insert.
+	object := &TestObject{PK: "1", ID: 1, Name: "Elmer"}
+	stmt, args, err = table.RenderInsert(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"INSERT INTO TestObject ( PK ,ID ,Name ,Age ,Int8 ,Int16 ,Int32 ,Bool ) " +
+			"VALUES ( :PK ,:ID ,:Name ,:Age ,:Int8 ,:Int16 ,:Int32 ,:Bool );"))
+	g.Expect(len(args)).To(gomega.Equal(8))
+	g.Expect(args[0]).To(gomega.Equal(sql.Named("PK", "1")))
+	g.Expect(args[2]).To(gomega.Equal(sql.Named("Name", "Elmer")))
+	// Update.
+	stmt, args, err = table.RenderUpdate(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"UPDATE TestObject SET Name = :Name ,Age = :Age ,Int8 = :Int8 " +
+			",Int16 = :Int16 ,Int32 = :Int32 ,Bool = :Bool WHERE PK = :PK ;"))
+	g.Expect(len(args)).To(gomega.Equal(7))
+	// Delete.
+	stmt, args, err = table.RenderDelete(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"DELETE FROM TestObject WHERE PK = :PK ;"))
+	g.Expect(args).To(gomega.Equal([]interface{}{sql.Named("PK", "1")}))
+}
+
+func TestRenderComposite(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	normalized := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	options := ListOptions{
+		Predicate: And(
+			Gt("Age", 17),
+			Match(Labels{"city": "Boston"}),
+			Or(
+				Eq("Name", "A"),
+				LabelNotIn("tier", "web", "db"))),
+		Sort: []int{4, 3},
+		Page: &Page{Offset: 1, Limit: 2},
+	}
+	stmt, args, err := Table{}.Render(&TestObject{}, options)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"SELECT PK ,ID ,Name ,Age ,Int8 ,Int16 ,Int32 ,Bool " +
+			"FROM TestObject " +
+			"WHERE Age > :Age0 AND " +
+			"PK IN ( SELECT parent FROM Label " +
+			"WHERE kind = 'TestObject' AND name = :k1 AND value = :v2 ) AND " +
+			"(Name = :Name3 OR " +
+			"NOT EXISTS ( SELECT 1 FROM Label " +
+			"WHERE kind = 'TestObject' AND parent = TestObject.PK AND " +
+			"name = :k4 AND value IN (:v5,:v6) ) ) " +
+			"ORDER BY 4 ,3 " +
+			"LIMIT 2 OFFSET 1 ;"))
+	g.Expect(args).To(gomega.Equal(
+		[]interface{}{
+			sql.Named("Age0", int64(17)),
+			sql.Named("k1", "city"),
+			sql.Named("v2", "Boston"),
+			sql.Named("Name3", "A"),
+			sql.Named("k4", "tier"),
+			sql.Named("v5", "web"),
+			sql.Named("v6", "db"),
+		}))
+	// Executed.
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for _, m := range []*TestObject{
+		{ID: 0, Name: "A", Age: 20, labels: Labels{"city": "Boston", "tier": "web"}},
+		{ID: 1, Name: "B", Age: 30, labels: Labels{"city": "Boston", "tier": "web"}},
+		{ID: 2, Name: "B", Age: 25, labels: Labels{"city": "Boston", "tier": "app"}},
+		{ID: 3, Name: "A", Age: 15, labels: Labels{"city": "Boston"}},
+		{ID: 4, Name: "C", Age: 40, labels: Labels{"city": "Denver"}},
+		{ID: 5, Name: "B", Age: 18, labels: Labels{"city": "Boston"}},
+		{ID: 6, Name: "A", Age: 22, labels: Labels{"city": "Boston", "tier": "db"}},
+	} {
+		err = DB.Insert(m)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(options ListOptions) []int {
+		list := []TestObject{}
+		err := DB.List(&list, options)
+		g.Expect(err).To(gomega.BeNil())
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	g.Expect(ids(options)).To(gomega.Equal([]int{0, 6}))
+	options.Page = nil
+	g.Expect(ids(options)).To(gomega.Equal([]int{5, 0, 6, 2}))
+	// Empty compound.
+	g.Expect(len(ids(ListOptions{Predicate: And()}))).To(gomega.Equal(7))
+	g.Expect(ids(ListOptions{Predicate: Or()})).To(gomega.BeEmpty())
+}
+
+func TestTableName(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestNamed{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	ddl, err := Table{}.DDL(&TestNamed{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("CREATE TABLE IF NOT EXISTS named_objects"))
+	// Insert.
+	object := &TestNamed{
+		ID:     1,
+		Name:   "Elmer",
+		labels: Labels{"n1": "v1"},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	list, err := DB.Query(&TestNamed{}, "SELECT * FROM named_objects")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	// Labels.
+	label := &Label{
+		Kind:   "named_objects",
+		Parent: object.PK,
+		Name:   "n1",
+	}
+	err = DB.Get(label)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(label.Value).To(gomega.Equal("v1"))
+	named := []TestNamed{}
+	err = DB.List(&named, ListOptions{Predicate: Match(Labels{"n1": "v1"})})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(named)).To(gomega.Equal(1))
+	// Update.
+	object.Name = "Larry"
+	err = DB.Update(object)
+	g.Expect(err).To(gomega.BeNil())
+	object = &TestNamed{ID: 1}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Name).To(gomega.Equal("Larry"))
+	// Delete.
+	err = DB.Delete(object)
+	g.Expect(err).To(gomega.BeNil())
+	count, err := DB.Count(&TestNamed{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+	count, err = DB.Count(&Label{}, Eq("Kind", "named_objects"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+}
+
+func TestPartialIndex(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ddl, err := Table{}.DDL(&TestIndexed{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(ddl)).To(gomega.Equal(3))
+	active := strings.Join(strings.Fields(ddl[1]), " ")
+	g.Expect(active).To(gomega.Equal(
+		"CREATE INDEX IF NOT EXISTS TestIndexed_active ON TestIndexed ( Name ,Zone ) WHERE Deleted = 0 ;"))
+	zone := strings.Join(strings.Fields(ddl[2]), " ")
+	g.Expect(zone).To(gomega.Equal(
+		"CREATE INDEX IF NOT EXISTS TestIndexed_zone ON TestIndexed ( Zone ) ;"))
+	// Invalid.
+	_, err = Table{}.DDL(&TestBadIndex{})
+	g.Expect(errors.Is(err, IndexWhereErr)).To(gomega.BeTrue())
+	// Used.
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestIndexed{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	plan := func(stmt string) string {
+		cursor, err := DB.(*Client).db.Query("EXPLAIN QUERY PLAN " + stmt)
+		g.Expect(err).To(gomega.BeNil())
+		defer cursor.Close()
+		detail := []string{}
+		for cursor.Next() {
+			var id, parent, notused int
+			var s string
+			err = cursor.Scan(&id, &parent, &notused, &s)
+			g.Expect(err).To(gomega.BeNil())
+			detail = append(detail, s)
+		}
+		return strings.Join(detail, ";")
+	}
+	g.Expect(plan("SELECT * FROM TestIndexed WHERE Name = 'a' AND Deleted = 0")).To(
+		gomega.ContainSubstring("TestIndexed_active"))
+	g.Expect(plan("SELECT * FROM TestIndexed WHERE Name = 'a'")).ToNot(
+		gomega.ContainSubstring("TestIndexed_active"))
+}
+
+func TestDefault(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ddl, err := Table{}.DDL(&TestDefaulted{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Name TEXT NOT NULL DEFAULT 'Elmer''s'"))
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Age INTEGER NOT NULL DEFAULT 18"))
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Active INTEGER NOT NULL DEFAULT 1"))
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestDefaulted{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Zero values (defaulted).
+	err = DB.Insert(&TestDefaulted{PK: "0"})
+	g.Expect(err).To(gomega.BeNil())
+	m := &TestDefaulted{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer's"))
+	g.Expect(m.Age).To(gomega.Equal(18))
+	g.Expect(m.Active).To(gomega.BeTrue())
+	// Values.
+	err = DB.Insert(&TestDefaulted{PK: "1", Name: "Fudd", Age: 40})
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestDefaulted{PK: "1"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Fudd"))
+	g.Expect(m.Age).To(gomega.Equal(40))
+	// Added column.
+	_, err = DB.Exec("DROP TABLE TestDefaulted")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("CREATE TABLE TestDefaulted (PK TEXT PRIMARY KEY, Other TEXT NOT NULL)")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("INSERT INTO TestDefaulted VALUES ('0', '')")
+	g.Expect(err).To(gomega.BeNil())
+	table := Table{DB: DB.(*Client).db}
+	added, err := table.AddColumns(&TestDefaulted{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(added).To(gomega.Equal([]string{"Name", "Age", "Active"}))
+	m = &TestDefaulted{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer's"))
+	g.Expect(m.Age).To(gomega.Equal(18))
+	g.Expect(m.Active).To(gomega.BeTrue())
+	// Not defaulted.
+	_, err = DB.Exec("DROP TABLE TestDefaulted")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("CREATE TABLE TestDefaulted (PK TEXT PRIMARY KEY)")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = table.AddColumns(&TestDefaulted{})
+	g.Expect(errors.Is(err, AddColumnErr)).To(gomega.BeTrue())
+	// Added on Open().
+	_, err = DB.Exec("DROP TABLE TestDefaulted")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("CREATE TABLE TestDefaulted (PK TEXT PRIMARY KEY, Other TEXT NOT NULL)")
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("INSERT INTO TestDefaulted VALUES ('0', '')")
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	DB.(*Client).AddColumns = true
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	m = &TestDefaulted{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Age).To(gomega.Equal(18))
+	// Nullable (valid zero value inserted).
+	DB.Close(true)
+	DB = New(
+		"/tmp/test.db",
+		&Label{},
+		&TestDefaultedNullable{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestDefaultedNullable{PK: "0"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestDefaultedNullable{PK: "1", Active: Optional[bool]{Valid: true}})
+	g.Expect(err).To(gomega.BeNil())
+	n := &TestDefaultedNullable{PK: "0"}
+	err = DB.Get(n)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n.Active).To(gomega.Equal(Optional[bool]{V: true, Valid: true}))
+	n = &TestDefaultedNullable{PK: "1"}
+	err = DB.Get(n)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n.Active).To(gomega.Equal(Optional[bool]{Valid: true}))
+}
+
+func TestSchema(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&TestObject{},
+		&TestNamed{})
+	schema, err := DB.Schema()
+	g.Expect(err).To(gomega.BeNil())
+	all := strings.Join(schema, "\n")
+	for _, table := range []string{"TestObject", "named_objects", "Label"} {
+		g.Expect(all).To(
+			gomega.ContainSubstring(
+				"CREATE TABLE IF NOT EXISTS " + table + " ("))
+		g.Expect(all).To(
+			gomega.ContainSubstring(
+				"CREATE INDEX IF NOT EXISTS " + table + "Index"))
+	}
+	g.Expect(strings.Count(all, "CREATE TABLE")).To(gomega.Equal(3))
+	// Label not repeated when registered.
+	DB = New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	schema, err = DB.Schema()
+	g.Expect(err).To(gomega.BeNil())
+	all = strings.Join(schema, "\n")
+	g.Expect(strings.Count(all, "CREATE TABLE")).To(gomega.Equal(2))
+}
+
+func TestSchemaDrift(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).DetectDrift = true
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("ALTER TABLE TestObject RENAME COLUMN Age TO Years")
+	g.Expect(err).To(gomega.BeNil())
+	// Indexes not compared.
+	_, err = DB.Exec("CREATE INDEX TestObject_name ON TestObject (Name)")
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	// Drift detected.
+	DB = New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).DetectDrift = true
+	err = DB.Open(false)
+	drift := &SchemaDrift{}
+	g.Expect(errors.As(err, &drift)).To(gomega.BeTrue())
+	g.Expect(drift.Mismatches).To(gomega.ConsistOf(
+		"TestObject: column Age not found",
+		"TestObject: column Years not expected"))
+	// Not detected.
+	DB = New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Close(true)
+}
+
+func TestVerify(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestParent{},
+		&TestChild{},
+		&TestUnique{},
+		&TestEnum{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	err = DB.Verify()
+	g.Expect(err).To(gomega.BeNil())
+	// Altered (out-of-band).
+	for _, stmt := range []string{
+		"ALTER TABLE TestObject ADD COLUMN Extra TEXT",
+		"CREATE INDEX TestObject_extra ON TestObject (Extra)",
+		"DROP INDEX TestEnum_phase",
+		"CREATE TABLE Replaced AS SELECT * FROM TestChild",
+		"DROP TABLE TestChild",
+		"ALTER TABLE Replaced RENAME TO TestChild",
+		"CREATE TABLE Replaced (PK TEXT PRIMARY KEY, Email TEXT, Region TEXT, Host TEXT, Name TEXT, UNIQUE (Name))",
+		"DROP TABLE TestUnique",
+		"ALTER TABLE Replaced RENAME TO TestUnique",
+	} {
+		_, err = DB.Exec(stmt)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.Verify()
+	drift := &SchemaDrift{}
+	g.Expect(errors.As(err, &drift)).To(gomega.BeTrue())
+	g.Expect(drift.Mismatches).To(gomega.ConsistOf(
+		"TestObject: column Extra not expected",
+		"TestObject: index TestObject_extra not expected",
+		"TestEnum: index TestEnum_phase not found",
+		"TestChild: foreign key (parent) references testparent (pk) not found",
+		"TestUnique: unique (email) not found",
+		"TestUnique: unique (region,host) not found",
+		"TestUnique: unique (name) not expected"))
+	// Closed.
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Verify()
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+}
+
+func TestBackup(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/wal.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	_, err = DB.Exec("PRAGMA journal_mode = WAL")
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID:     i,
+			Name:   fmt.Sprintf("n%d", i),
+			labels: Labels{"id": fmt.Sprintf("v%d", i)},
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.Backup("/tmp/backup.db")
+	g.Expect(err).To(gomega.BeNil())
+	// Replaced.
+	err = DB.Backup("/tmp/backup.db")
+	g.Expect(err).To(gomega.BeNil())
+	backup := New(
+		"/tmp/backup.db",
+		&Label{},
+		&TestObject{})
+	err = backup.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	defer backup.Close(true)
+	listA := []TestObject{}
+	err = DB.List(&listA, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	listB := []TestObject{}
+	err = backup.List(&listB, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(listB).To(gomega.Equal(listA))
+	labelsA := []Label{}
+	err = DB.List(&labelsA, ListOptions{Sort: []int{1}})
+	g.Expect(err).To(gomega.BeNil())
+	labelsB := []Label{}
+	err = backup.List(&labelsB, ListOptions{Sort: []int{1}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(labelsB)).To(gomega.Equal(10))
+	g.Expect(labelsB).To(gomega.Equal(labelsA))
+}
+
+func TestOpenDirMode(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	root := "/tmp/model-dir"
+	os.RemoveAll(root)
+	defer os.RemoveAll(root)
+	path := root + "/a/b/test.db"
+	// Not created.
+	DB := &Client{path: path}
+	DB.Register(&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).ToNot(gomega.BeNil())
+	_, err = os.Stat(root)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	// Created.
+	DB = &Client{
+		path:    path,
+		DirMode: 0700,
+	}
+	DB.Register(&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	info, err := os.Stat(root + "/a/b")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(info.IsDir()).To(gomega.BeTrue())
+	g.Expect(info.Mode().Perm()).To(gomega.Equal(os.FileMode(0700)))
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = os.Stat(path)
+	g.Expect(err).To(gomega.BeNil())
+}
+
+func TestExclusive(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := &Client{
+		path:      "/tmp/exclusive.db",
+		Exclusive: true,
+	}
+	DB.Register(&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Locked.
+	other := &Client{
+		path:      "/tmp/exclusive.db",
+		Exclusive: true,
+	}
+	other.Register(&TestObject{})
+	err = other.Open(false)
+	g.Expect(errors.Is(err, InUseError)).To(gomega.BeTrue())
+	// Not exclusive.
+	shared := New("/tmp/exclusive.db", &TestObject{})
+	err = shared.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = shared.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	// Released.
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = other.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = other.Close(true)
+	g.Expect(err).To(gomega.BeNil())
+	os.Remove("/tmp/exclusive.db.lock")
+}
+
+func TestFork(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				Name:   "original",
+				labels: Labels{"id": fmt.Sprintf("%d", i)},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handler := &TestHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	fork, err := DB.Fork("/tmp/fork.db")
+	g.Expect(err).To(gomega.BeNil())
+	defer fork.Close(true)
+	g.Expect(fork.Journal().Enabled()).To(gomega.BeTrue())
+	// Mutate the fork.
+	err = fork.Update(&TestObject{ID: 0, Name: "forked"})
+	g.Expect(err).To(gomega.BeNil())
+	err = fork.Delete(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = fork.Insert(&TestObject{ID: 5, Name: "forked"})
+	g.Expect(err).To(gomega.BeNil())
+	n, err := fork.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(5)))
+	n, err = fork.Count(&TestObject{}, Match(Labels{"id": "2"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Original unchanged.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(5))
+	for i, m := range list {
+		g.Expect(m.ID).To(gomega.Equal(i))
+		g.Expect(m.Name).To(gomega.Equal("original"))
+	}
+	// Watches not shared.
+	time.Sleep(time.Millisecond * 50)
+	g.Expect(handler.updated).To(gomega.BeEmpty())
+	g.Expect(handler.deleted).To(gomega.BeEmpty())
+	g.Expect(handler.created).To(gomega.Equal([]int{0, 1, 2, 3, 4}))
+}
+
+func TestImportFrom(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	src := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := src.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer src.Close(true)
+	for i := 0; i < 10; i++ {
+		object := &TestObject{
+			ID:     i,
+			Name:   fmt.Sprintf("n%d", i),
+			labels: Labels{"id": fmt.Sprintf("v%d", i)},
+		}
+		err = src.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	DB := New(
+		"/tmp/import.db",
+		&Label{},
+		&TestObject{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	// Import.
+	err = DB.ImportFrom(src, false, &TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(10)))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Match(Labels{"id": "v3"})})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].Name).To(gomega.Equal("n3"))
+	count, err = DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(10)))
+	for i := 0; i < 100; i++ {
+		if len(handler.created) == 10 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.created)).To(gomega.Equal(10))
+	// Conflict.
+	err = DB.ImportFrom(src, false, &TestObject{})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	// Upsert.
+	object := &TestObject{
+		ID:     3,
+		Name:   "changed",
+		labels: Labels{"id": "changed"},
+	}
+	err = src.Update(object)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.ImportFrom(src, true, &TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	object = &TestObject{ID: 3}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Name).To(gomega.Equal("changed"))
+	count, err = DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(10)))
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Match(Labels{"id": "changed"})})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) == 10 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.updated)).To(gomega.Equal(10))
+	for i, id := range handler.updated {
+		delta := handler.labels[i]
+		if id == 3 {
+			g.Expect(delta.Changed).To(gomega.Equal(Labels{"id": "changed"}))
+			continue
+		}
+		g.Expect(delta == nil || delta.Empty()).To(gomega.BeTrue())
+	}
+}
+
+func TestReset(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestNamed{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: Labels{"id": fmt.Sprintf("v%d", i)},
+			})
+		g.Expect(err).To(gomega.BeNil())
+		err = DB.Insert(&TestNamed{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	before, err := os.Stat("/tmp/test.db")
+	g.Expect(err).To(gomega.BeNil())
+	// Reset.
+	err = DB.Reset()
+	g.Expect(err).To(gomega.BeNil())
+	for _, m := range []Model{&TestObject{}, &TestNamed{}, &Label{}} {
+		count, err := DB.Count(m, nil)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(count).To(gomega.Equal(int64(0)))
+	}
+	after, err := os.Stat("/tmp/test.db")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(os.SameFile(before, after)).To(gomega.BeTrue())
+	for i := 0; i < 100; i++ {
+		if len(handler.deleted) == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.deleted)).To(gomega.Equal(5))
+	// Schema intact.
+	err = DB.Insert(
+		&TestObject{
+			ID:     0,
+			labels: Labels{"id": "v0"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Match(Labels{"id": "v0"})})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+}
+
+func TestPragmas(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Pragmas = map[string]string{
+		"synchronous": "OFF",
+		"cache_size":  "-4000",
+	}
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	pragma := func(name string) (n int64) {
+		row := DB.(*Client).db.QueryRow("PRAGMA " + name)
+		err := row.Scan(&n)
+		g.Expect(err).To(gomega.BeNil())
+		return
+	}
+	g.Expect(pragma("synchronous")).To(gomega.Equal(int64(0)))
+	g.Expect(pragma("cache_size")).To(gomega.Equal(int64(-4000)))
+	g.Expect(pragma("foreign_keys")).To(gomega.Equal(int64(1)))
+	DB.Close(false)
+	// Default overridden.
+	DB.(*Client).Pragmas = map[string]string{
+		"foreign_keys": "OFF",
+	}
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(pragma("foreign_keys")).To(gomega.Equal(int64(0)))
+	DB.Close(false)
+	// Driver registered once (same pragmas).
+	registered := len(drivers.names)
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Close(false)
+	g.Expect(len(drivers.names)).To(gomega.Equal(registered))
+	// Invalid.
+	DB.(*Client).Pragmas = map[string]string{
+		"synchronous": "OFF; DROP TABLE Label",
+	}
+	err = DB.Open(false)
+	g.Expect(errors.Is(err, PragmaInvalidError)).To(gomega.BeTrue())
+}
+
+func TestForeignKeys(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	for _, enforced := range []bool{true, false} {
+		logger := &TestLogger{}
+		DB := &Client{
+			path: "/tmp/test.db",
+			Log:  logger,
+		}
+		if !enforced {
+			DB.Pragmas = map[string]string{
+				"foreign_keys": "OFF",
+			}
+		}
+		DB.Register(&TestParent{}, &TestChild{})
+		err := DB.Open(true)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(DB.ForeignKeys()).To(gomega.Equal(enforced))
+		g.Expect(len(logger.entries) > 0).To(gomega.Equal(!enforced))
+		for _, pk := range []string{"p1", "p2"} {
+			err = DB.Insert(
+				&TestParent{
+					PK:     pk,
+					labels: Labels{"n": "v"},
+				})
+			g.Expect(err).To(gomega.BeNil())
+			err = DB.Insert(
+				&TestChild{
+					PK:     pk + "-c",
+					Parent: pk,
+					labels: Labels{"n": "v"},
+				})
+			g.Expect(err).To(gomega.BeNil())
+		}
+		// Delete.
+		err = DB.Delete(&TestParent{PK: "p1"})
+		g.Expect(err).To(gomega.BeNil())
+		children := []TestChild{}
+		err = DB.List(&children, ListOptions{})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(children)).To(gomega.Equal(1))
+		g.Expect(children[0].PK).To(gomega.Equal("p2-c"))
+		// Delete (all).
+		n, err := DB.DeleteAll(&TestParent{}, nil)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(n).To(gomega.Equal(int64(1)))
+		n, err = DB.Count(&TestChild{}, nil)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(n).To(gomega.Equal(int64(0)))
+		if !enforced {
+			// Labels (including children) deleted.
+			n, err = DB.Count(&Label{}, nil)
+			g.Expect(err).To(gomega.BeNil())
+			g.Expect(n).To(gomega.Equal(int64(0)))
+		}
+		DB.Close(true)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	events := []LifecycleEvent{}
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Lifecycle = func(event LifecycleEvent) {
+		events = append(events, event)
+	}
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	// Not open.
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(events)).To(gomega.Equal(2))
+	g.Expect(events[0].Action).To(gomega.Equal(Opened))
+	g.Expect(events[0].Path).To(gomega.Equal("/tmp/test.db"))
+	g.Expect(events[0].Error).To(gomega.BeNil())
+	g.Expect(events[1].Action).To(gomega.Equal(Closed))
+	g.Expect(events[1].Error).To(gomega.BeNil())
+	// Failed.
+	DB.(*Client).Pragmas = map[string]string{"bad name": "x"}
+	err = DB.Open(false)
+	g.Expect(err).ToNot(gomega.BeNil())
+	g.Expect(len(events)).To(gomega.Equal(3))
+	g.Expect(events[2].Action).To(gomega.Equal(Opened))
+	g.Expect(errors.Is(events[2].Error, PragmaInvalidError)).To(gomega.BeTrue())
+}
+
+func TestUpdateFields(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	object := &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		Age:    18,
+		labels: Labels{"n1": "v1"},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	object.Name = "Larry"
+	object.Age = 21
+	object.labels = nil
+	err = DB.UpdateFields(object, "Name")
+	g.Expect(err).To(gomega.BeNil())
+	stored := &TestObject{ID: 0}
+	err = DB.Get(stored)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stored.Name).To(gomega.Equal("Larry"))
+	g.Expect(stored.Age).To(gomega.Equal(18))
+	count, err := DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.updated).To(gomega.Equal([]int{0}))
+	// Invalid.
+	err = DB.UpdateFields(object, "Unknown")
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	err = DB.UpdateFields(object, "ID")
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	// Not found.
+	err = DB.UpdateFields(&TestObject{ID: 99}, "Name")
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+}
+
+func TestIncrement(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+		Age:  10,
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	n, err := DB.Increment(object, "Age", 5)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(15)))
+	g.Expect(object.Age).To(gomega.Equal(15))
+	// Concurrent.
+	done := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			for i := 0; i < 10; i++ {
+				_, err := DB.Increment(&TestObject{ID: 0}, "Age", 1)
+				if err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		g.Expect(<-done).To(gomega.BeNil())
+	}
+	object = &TestObject{ID: 0}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Age).To(gomega.Equal(115))
+	g.Expect(object.Name).To(gomega.Equal("Elmer"))
+	// Invalid.
+	_, err = DB.Increment(object, "Name", 1)
+	g.Expect(errors.Is(err, IntFieldTypeErr)).To(gomega.BeTrue())
+	_, err = DB.Increment(object, "Bool", 1)
+	g.Expect(errors.Is(err, IntFieldTypeErr)).To(gomega.BeTrue())
+	_, err = DB.Increment(object, "Unknown", 1)
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	_, err = DB.Increment(&TestObject{ID: 99}, "Age", 1)
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+}
+
+func TestNotLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 6; i++ {
+		labels := Labels{}
+		switch i {
+		case 1, 2:
+			labels["zone"] = "east"
+		case 3:
+			labels["zone"] = "west"
+		case 4:
+			labels["zone"] = "north"
+		case 5:
+			labels["other"] = "east"
+		}
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: labels,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(list []TestObject) []int {
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	// Not has.
+	list := []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort:      []int{2},
+			Predicate: NotHasLabel("zone"),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ids(list)).To(gomega.Equal([]int{0, 5}))
+	// Not in.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort:      []int{2},
+			Predicate: LabelNotIn("zone", "east", "west"),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ids(list)).To(gomega.Equal([]int{0, 4, 5}))
+	// Combined.
+	list = []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort: []int{2},
+			Predicate: And(
+				Gt("ID", 0),
+				LabelNotIn("zone", "east")),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ids(list)).To(gomega.Equal([]int{3, 4, 5}))
+	count, err := DB.CountOptions(
+		&TestObject{},
+		ListOptions{
+			Predicate: NotHasLabel("other"),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(5)))
+}
+
+func TestJsonArrayContains(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&TestTagged{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	_, err = DB.Exec("SELECT json('[]')")
+	if err != nil {
+		t.Skip("JSON1 not enabled (build tag: sqlite_json)")
+	}
+	for pk, tags := range map[string]string{
+		"a": `["prod","db"]`,
+		"b": `["dev","db"]`,
+		"c": `["prod",1,true]`,
+		"d": `{"prod":"prod"}`,
+		"e": ``,
+		"f": `not json`,
+	} {
+		err = DB.Insert(&TestTagged{PK: pk, Tags: tags})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	find := func(value interface{}) []string {
+		list := []TestTagged{}
+		err := DB.List(
+			&list,
+			ListOptions{
+				Predicate: JsonArrayContains("Tags", value),
+				Sort:      []int{1},
+			})
+		g.Expect(err).To(gomega.BeNil())
+		pks := []string{}
+		for _, m := range list {
+			pks = append(pks, m.PK)
+		}
+		return pks
+	}
+	g.Expect(find("prod")).To(gomega.Equal([]string{"a", "c"}))
+	g.Expect(find("db")).To(gomega.Equal([]string{"a", "b"}))
+	g.Expect(find(1)).To(gomega.Equal([]string{"c"}))
+	g.Expect(find(true)).To(gomega.Equal([]string{"c"}))
+	g.Expect(find("none")).To(gomega.Equal([]string{}))
+	// Combined.
+	n, err := DB.Count(
+		&TestTagged{},
+		And(
+			JsonArrayContains("Tags", "prod"),
+			JsonArrayContains("Tags", "db")))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Invalid.
+	err = DB.Find(&[]TestTagged{}, JsonArrayContains("Tags", 1.5))
+	g.Expect(errors.Is(err, PredicateValueErr)).To(gomega.BeTrue())
+	err = DB.Find(&[]TestTagged{}, JsonArrayContains("Other", "prod"))
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+}
+
+func TestLabelCountAtLeast(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i, labels := range []Labels{
+		{},
+		{"a": "1"},
+		{"a": "1", "b": "2"},
+		{"a": "1", "b": "2", "c": "3"},
+		{"b": "2", "c": "3", "d": "4"},
+		{"c": "3", "d": "4", "e": "5"},
+	} {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: labels,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(predicate Predicate) []int {
+		list := []TestObject{}
+		err := DB.List(
+			&list,
+			ListOptions{
+				Sort:      []int{2},
+				Predicate: predicate,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	names := []string{"a", "b", "c"}
+	g.Expect(ids(LabelCountAtLeast(names, 2))).To(gomega.Equal([]int{2, 3, 4}))
+	g.Expect(ids(LabelCountAtLeast(names, 3))).To(gomega.Equal([]int{3}))
+	g.Expect(ids(LabelCountAtLeast(names, 1))).To(gomega.Equal([]int{1, 2, 3, 4, 5}))
+	g.Expect(ids(LabelCountAtLeast(names, 0))).To(gomega.Equal([]int{0, 1, 2, 3, 4, 5}))
+	// Combined.
+	g.Expect(ids(And(LabelCountAtLeast(names, 2), Gt("ID", 2)))).To(gomega.Equal([]int{3, 4}))
+	g.Expect(ids(Or(LabelCountAtLeast(names, 3), Eq("ID", 0)))).To(gomega.Equal([]int{0, 3}))
+}
+
+func TestAnyLabel(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i, labels := range []Labels{
+		{},
+		{"color": "red"},
+		{"color": "green"},
+		{"size": "large"},
+		{"color": "green", "size": "small"},
+		{"color": "blue", "size": "large"},
+		{"shape": "red"},
+	} {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: labels,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(predicate Predicate) []int {
+		list := []TestObject{}
+		err := DB.List(
+			&list,
+			ListOptions{
+				Sort:      []int{2},
+				Predicate: predicate,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	g.Expect(ids(AnyLabel(map[string][]string{
+		"color": {"red", "blue"},
+	}))).To(gomega.Equal([]int{1, 5}))
+	g.Expect(ids(AnyLabel(map[string][]string{
+		"color": {"red", "blue"},
+		"size":  {"large"},
+	}))).To(gomega.Equal([]int{1, 3, 5}))
+	g.Expect(ids(AnyLabel(map[string][]string{
+		"color": {"green"},
+		"size":  {"small", "large"},
+	}))).To(gomega.Equal([]int{2, 3, 4, 5}))
+	// None.
+	g.Expect(ids(AnyLabel(map[string][]string{}))).To(gomega.BeEmpty())
+	g.Expect(ids(AnyLabel(map[string][]string{
+		"color": {},
+	}))).To(gomega.BeEmpty())
+	// Combined.
+	g.Expect(ids(And(
+		AnyLabel(map[string][]string{
+			"color": {"red", "green"},
+		}),
+		Gt("ID", 1)))).To(gomega.Equal([]int{2, 4}))
+	g.Expect(ids(Or(
+		AnyLabel(map[string][]string{
+			"size": {"small"},
+		}),
+		Eq("ID", 0)))).To(gomega.Equal([]int{0, 4}))
+}
+
+func TestPromotedLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 6; i++ {
+		labels := Labels{
+			"tier": "web",
+		}
+		if i%2 == 0 {
+			labels["zone"] = "east"
+		}
+		if i == 4 {
+			labels["tier"] = "db"
+		}
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: labels,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(predicate Predicate) []int {
+		list := []TestObject{}
+		err := DB.List(
+			&list,
+			ListOptions{
+				Sort:      []int{2},
+				Predicate: predicate,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	before := ids(Match(Labels{"zone": "east", "tier": "web"}))
+	g.Expect(before).To(gomega.Equal([]int{0, 2}))
+	DB.Close(false)
+	// Promoted (backfilled).
+	DB.(*Client).PromotedLabels = map[string][]string{
+		"TestObject": {"zone"},
+	}
+	DB.(*Client).DetectDrift = true
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	columns, err := Table{DB: DB.(*Client).db}.Columns(&TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(columns).To(gomega.HaveKey("label_zone"))
+	g.Expect(ids(Match(Labels{"zone": "east", "tier": "web"}))).To(gomega.Equal(before))
+	g.Expect(ids(Match(Labels{"zone": "east"}))).To(gomega.Equal([]int{0, 2, 4}))
+	count, err := DB.Count(&TestObject{}, Match(Labels{"zone": "east"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(3)))
+	// Promoted column used.
+	options := ListOptions{
+		Predicate: Match(Labels{"zone": "east"}),
+		promoted:  DB.(*Client).PromotedLabels,
+	}
+	stmt, _, err := Table{}.Render(&TestObject{}, options)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stmt).To(gomega.ContainSubstring("label_zone ="))
+	// Maintained on update.
+	err = DB.Update(
+		&TestObject{
+			ID: 1,
+			labels: Labels{
+				"tier": "web",
+				"zone": "east",
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(
+		&TestObject{
+			ID: 2,
+			labels: Labels{
+				"tier": "web",
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ids(Match(Labels{"zone": "east", "tier": "web"}))).To(gomega.Equal([]int{0, 1}))
+	DB.Close(false)
+	// Invalid.
+	DB.(*Client).PromotedLabels = map[string][]string{
+		"TestObject": {"zone; DROP TABLE Label"},
+	}
+	err = DB.Open(false)
+	g.Expect(errors.Is(err, PromotedLabelError)).To(gomega.BeTrue())
+}
+
+func TestErrors(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Not found.
+	err = DB.Get(&TestObject{ID: 1})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	err = DB.Update(&TestObject{ID: 1})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	// Conflict.
+	_, err = DB.Exec(
+		"INSERT INTO TestObject (PK, ID, Name, Age, Int8, Int16, Int32, Bool) VALUES (?,0,'',0,0,0,0,0)",
+		object.PK)
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	classified := &Error{}
+	g.Expect(errors.As(err, &classified)).To(gomega.BeTrue())
+	g.Expect(classified.Kind).To(gomega.Equal(ConflictError))
+	g.Expect(errors.Is(err, LockTimeoutError)).To(gomega.BeFalse())
+	// Transaction not valid.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(errors.Is(err, TxInvalidError)).To(gomega.BeTrue())
+	err = tx.End()
+	g.Expect(errors.Is(err, TxInvalidError)).To(gomega.BeTrue())
+	// Lock timeout.
+	DB2 := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB2.(*Client).Pragmas = map[string]string{
+		"busy_timeout": "0",
+	}
+	err = DB2.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	tx, err = DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 2})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB2.Insert(&TestObject{ID: 3})
+	g.Expect(errors.Is(err, LockTimeoutError)).To(gomega.BeTrue())
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	DB2.Close(false)
+	// Stale.
+	err = Classify(liberr.Wrap(StaleError))
+	g.Expect(errors.Is(err, StaleError)).To(gomega.BeTrue())
+	// Not open.
+	DB.Close(false)
+	err = DB.Get(object)
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+	err = DB.Insert(object)
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+	_, err = DB.Begin()
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+	_, err = DB.Count(object, nil)
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+}
+
+func TestStats(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID: i,
+				labels: Labels{
+					"n":    fmt.Sprintf("%d", i),
+					"role": "main",
+				},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(
+			&TestRelated{
+				PK:   fmt.Sprintf("%d", i),
+				ID:   i,
+				Name: "related",
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	stats, err := DB.Stats()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stats.Models).To(
+		gomega.Equal(
+			map[string]int64{
+				"TestObject":  5,
+				"TestRelated": 3,
+			}))
+	g.Expect(stats.Labels).To(gomega.Equal(int64(10)))
+	g.Expect(stats.Size > 0).To(gomega.BeTrue())
+	g.Expect(stats.WalSize).To(gomega.Equal(int64(0)))
+	DB.Close(false)
+	_, err = DB.Stats()
+	g.Expect(errors.Is(err, NotOpenError)).To(gomega.BeTrue())
+}
+
+func TestLabelKeys(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestNamed{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID: i,
+				labels: Labels{
+					"zone": "east",
+					"tier": "web",
+				},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.Insert(
+		&TestNamed{
+			PK: "0",
+			ID: 0,
+			labels: Labels{
+				"zone":  "west",
+				"owner": "elmer",
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	keys, err := DB.LabelKeys()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(keys).To(gomega.Equal([]string{"owner", "tier", "zone"}))
+	keys, err = DB.LabelKeys(&TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(keys).To(gomega.Equal([]string{"tier", "zone"}))
+	keys, err = DB.LabelKeys(&TestObject{}, &TestNamed{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(keys).To(gomega.Equal([]string{"owner", "tier", "zone"}))
+}
+
+func TestWalk(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:   i,
+				Name: fmt.Sprintf("n%d", i),
+				Age:  i * 10,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	predicate := Or(
+		Eq("Name", "n1"),
+		And(
+			Gt("ID", 2),
+			Match(Labels{"a": "b"}),
+			Or(
+				Eq("Name", "n0"),
+				Lt("ID", 0))))
+	// Count leaves.
+	leaves := 0
+	compound := 0
+	Walk(
+		predicate,
+		func(p Predicate) Predicate {
+			switch p.(type) {
+			case *AndPredicate, *OrPredicate:
+				compound++
+			default:
+				leaves++
+			}
+			return p
+		})
+	g.Expect(leaves).To(gomega.Equal(5))
+	g.Expect(compound).To(gomega.Equal(3))
+	// Rewrite.
+	rewritten := Walk(
+		predicate,
+		func(p Predicate) Predicate {
+			switch p := p.(type) {
+			case *EqPredicate:
+				if p.Field == "Name" && p.Value == "n1" {
+					return Eq("Age", 30)
+				}
+			case *LabelPredicate:
+				return nil
+			}
+			return p
+		})
+	or := rewritten.(*OrPredicate)
+	g.Expect(or.Predicates[0].(*EqPredicate).Field).To(gomega.Equal("Age"))
+	g.Expect(len(or.Predicates[1].(*AndPredicate).Predicates)).To(gomega.Equal(2))
+	g.Expect(predicate.Predicates[0].(*EqPredicate).Field).To(gomega.Equal("Name"))
+	list := []TestObject{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Sort:      []int{2},
+			Predicate: rewritten,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].ID).To(gomega.Equal(3))
+}
+
+func TestGeneration(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{ID: 0}
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(0)))
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(1)))
+	object.Name = "Elmer"
+	err = DB.Update(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(&TestObject{})).To(gomega.Equal(uint64(2)))
+	// Unrelated kind.
+	err = DB.Insert(&TestRelated{PK: "0"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(2)))
+	g.Expect(DB.Generation(&TestRelated{})).To(gomega.Equal(uint64(1)))
+	// Not committed.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(2)))
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(2)))
+	// Committed.
+	tx, err = DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(object)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(4)))
+	g.Expect(DB.Generation(&TestRelated{})).To(gomega.Equal(uint64(1)))
+}
+
+func TestInsertIfAbsent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+		labels: Labels{
+			"role": "main",
+		},
+	}
+	inserted, err := DB.InsertIfAbsent(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(inserted).To(gomega.BeTrue())
+	// No-op.
+	object = &TestObject{
+		ID:   0,
+		Name: "Fudd",
+		labels: Labels{
+			"role": "other",
+		},
+	}
+	inserted, err = DB.InsertIfAbsent(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(inserted).To(gomega.BeFalse())
+	found := &TestObject{ID: 0}
+	err = DB.Get(found)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found.Name).To(gomega.Equal("Elmer"))
+	count, err := DB.Count(&TestObject{}, Match(Labels{"role": "main"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	// Created (journaled) once.
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(1)))
+}
+
+func TestInsertOnConflict(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestUnique{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		labels: Labels{"role": "main"},
+	}
+	err = DB.InsertWith(object, InsertOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	// Error (default).
+	object = &TestObject{ID: 0, Name: "Fudd"}
+	err = DB.InsertWith(object, InsertOptions{})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	err = DB.InsertWith(object, InsertOptions{OnConflict: OnConflictError})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	found := &TestObject{ID: 0}
+	err = DB.Get(found)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found.Name).To(gomega.Equal("Elmer"))
+	// Ignore.
+	err = DB.InsertWith(object, InsertOptions{OnConflict: OnConflictIgnore})
+	g.Expect(err).To(gomega.BeNil())
+	found = &TestObject{ID: 0}
+	err = DB.Get(found)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found.Name).To(gomega.Equal("Elmer"))
+	// Replace.
+	object.labels = Labels{"role": "other"}
+	err = DB.InsertWith(object, InsertOptions{OnConflict: OnConflictReplace})
+	g.Expect(err).To(gomega.BeNil())
+	found = &TestObject{ID: 0}
+	err = DB.Get(found)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found.Name).To(gomega.Equal("Fudd"))
+	count, err := DB.Count(&TestObject{}, Match(Labels{"role": "other"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	count, err = DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	// Replace (absent).
+	err = DB.InsertWith(
+		&TestObject{ID: 1, Name: "Bugs"},
+		InsertOptions{OnConflict: OnConflictReplace})
+	g.Expect(err).To(gomega.BeNil())
+	// All.
+	err = DB.InsertAll(
+		[]Model{
+			&TestObject{ID: 1, Name: "Daffy"},
+			&TestObject{ID: 2, Name: "Porky"},
+		},
+		InsertOptions{OnConflict: OnConflictIgnore})
+	g.Expect(err).To(gomega.BeNil())
+	found = &TestObject{ID: 1}
+	err = DB.Get(found)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found.Name).To(gomega.Equal("Bugs"))
+	count, err = DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(3)))
+	// Events.
+	for i := 0; i < 100; i++ {
+		if len(handler.keys(Created)) == 3 &&
+			len(handler.keys(Updated)) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.keys(Created))).To(gomega.Equal(3))
+	g.Expect(len(handler.keys(Updated))).To(gomega.Equal(1))
+	handler.mutex.Lock()
+	for _, e := range handler.events {
+		if e.Action == Updated {
+			g.Expect(e.Model.(*TestObject).Name).To(gomega.Equal("Elmer"))
+			g.Expect(e.Updated.(*TestObject).Name).To(gomega.Equal("Fudd"))
+		}
+	}
+	handler.mutex.Unlock()
+	// All (partial): models inserted before the error are journaled.
+	err = DB.InsertAll(
+		[]Model{
+			&TestObject{ID: 3, Name: "Taz"},
+			&TestObject{ID: 0, Name: "Sam"},
+		},
+		InsertOptions{})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	for i := 0; i < 100; i++ {
+		if len(handler.keys(Created)) == 4 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.keys(Created))).To(gomega.Equal(4))
+	// Replace (unique conflict).
+	err = DB.Insert(&TestUnique{PK: "1", Email: "a@b"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.InsertWith(
+		&TestUnique{PK: "2", Email: "a@b"},
+		InsertOptions{OnConflict: OnConflictReplace})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+}
+
+func TestDeleteIf(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:   0,
+		Name: "Elmer",
+		Age:  10,
+		labels: Labels{
+			"role": "main",
+		},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// No match.
+	deleted, err := DB.DeleteIf(object, Eq("Age", 11))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(deleted).To(gomega.BeFalse())
+	err = DB.Get(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(1)))
+	// Match.
+	deleted, err = DB.DeleteIf(
+		object,
+		And(
+			Eq("Age", 10),
+			Match(Labels{"role": "main"})))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(deleted).To(gomega.BeTrue())
+	err = DB.Get(&TestObject{ID: 0})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	count, err := DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+	g.Expect(DB.Generation(object)).To(gomega.Equal(uint64(2)))
+	// Invalid predicate.
+	_, err = DB.DeleteIf(object, Eq("Unknown", 0))
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+}
+
+func TestDeleteKeys(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestParent{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	keys := []interface{}{}
+	for i := 0; i < 5; i++ {
+		object := &TestObject{
+			ID:     i,
+			labels: Labels{"id": strconv.Itoa(i)},
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+		keys = append(keys, object.PK)
+	}
+	// Present and absent.
+	n, err := DB.DeleteKeys(
+		&TestObject{},
+		[]interface{}{keys[0], keys[2], "absent", keys[4]})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].ID).To(gomega.Equal(1))
+	g.Expect(list[1].ID).To(gomega.Equal(3))
+	labels := []Label{}
+	err = DB.List(&labels, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(labels)).To(gomega.Equal(2))
+	for i := 0; i < 100; i++ {
+		if len(handler.keys(Deleted)) == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.keys(Deleted)).To(
+		gomega.ConsistOf(keys[0], keys[2], keys[4]))
+	// Absent only.
+	n, err = DB.DeleteKeys(&TestObject{}, []interface{}{"absent"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	// Batched.
+	keys = []interface{}{}
+	parents := []Model{}
+	for i := 0; i < GetAllBatch*2+1; i++ {
+		key := strconv.Itoa(i)
+		parents = append(parents, &TestParent{PK: key})
+		keys = append(keys, key)
+	}
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.InsertAll(parents, InsertOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	n, err = DB.DeleteKeys(&TestParent{}, keys)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(len(keys))))
+	count, err := DB.Count(&TestParent{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+}
+
+func TestRegister(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// Not registered.
+	DB := New("/tmp/test.db")
+	err := DB.Open(true)
+	g.Expect(errors.Is(err, NoModelsError)).To(gomega.BeTrue())
+	DB = New("/tmp/test.db", &Label{})
+	err = DB.Open(true)
+	g.Expect(errors.Is(err, NoModelsError)).To(gomega.BeTrue())
+	// Registered.
+	err = DB.Register(&TestObject{})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Register(&TestObject{}, &TestRelated{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(DB.(*Client).models)).To(gomega.Equal(3))
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestRelated{PK: "0"})
+	g.Expect(err).To(gomega.BeNil())
+	// Registered after Open().
+	err = DB.Register(&TestNamed{})
+	g.Expect(errors.Is(err, AlreadyOpenError)).To(gomega.BeTrue())
+	g.Expect(len(DB.(*Client).models)).To(gomega.Equal(3))
+	DB.Close(true)
+	// Duplicates (New).
+	DB = New("/tmp/test.db", &TestObject{}, &TestObject{})
+	g.Expect(len(DB.(*Client).models)).To(gomega.Equal(1))
+}
+
+func TestReopen(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&TestObject{})
+	DB.Register(&TestObject{})
+	for i := 0; i < 2; i++ {
+		err := DB.Open(i == 0)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(DB.(*Client).models)).To(gomega.Equal(2))
+		err = DB.Insert(
+			&TestObject{
+				ID: i,
+				labels: Labels{
+					"n": fmt.Sprintf("%d", i),
+				},
+			})
+		g.Expect(err).To(gomega.BeNil())
+		err = DB.Close(false)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err := DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(2)))
+	count, err = DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(2)))
+}
+
+func TestView(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/view.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	DB.(*Client).Pragmas = map[string]string{
+		"journal_mode": "WAL",
+	}
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		DB.Close(true)
+		os.Remove("/tmp/view.db-wal")
+		os.Remove("/tmp/view.db-shm")
+	}()
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+		err = DB.Insert(
+			&TestRelated{
+				PK: fmt.Sprintf("%d", i),
+				ID: i,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.View(func(v *View) error {
+		objects := []TestObject{}
+		err := v.List(&objects, ListOptions{})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(objects)).To(gomega.Equal(3))
+		// Concurrent write.
+		done := make(chan error)
+		go func() {
+			tx, err := DB.Begin()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer tx.End()
+			err = DB.Insert(&TestObject{ID: 3})
+			if err != nil {
+				done <- err
+				return
+			}
+			err = DB.Insert(&TestRelated{PK: "3", ID: 3})
+			if err != nil {
+				done <- err
+				return
+			}
+			done <- tx.Commit()
+		}()
+		g.Expect(<-done).To(gomega.BeNil())
+		related := []TestRelated{}
+		err = v.List(&related, ListOptions{})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(related)).To(gomega.Equal(3))
+		count, err := v.Count(&TestObject{}, nil)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(count).To(gomega.Equal(int64(3)))
+		err = v.Get(&TestObject{ID: 3})
+		g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+		return nil
+	})
+	g.Expect(err).To(gomega.BeNil())
+	count, err := DB.Count(&TestRelated{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(4)))
+	// Error returned.
+	err = DB.View(func(v *View) error {
+		return ConflictError
+	})
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+}
+
+func TestCompactLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestNamed{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID: i,
+				labels: Labels{
+					"a": "1",
+					"b": "2",
+				},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = DB.Insert(
+		&TestNamed{
+			PK: "0",
+			labels: Labels{
+				"a": "1",
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	// Nothing orphaned.
+	removed, err := DB.CompactLabels()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(removed).To(gomega.Equal(int64(0)))
+	// Orphans.
+	_, err = DB.Exec("DELETE FROM TestObject WHERE ID = 1")
+	g.Expect(err).To(gomega.BeNil())
+	orphans := []*Label{
+		{PK: "o1", Kind: "named_objects", Parent: "1", Name: "a"},
+		{PK: "o2", Kind: "Unknown", Parent: "0", Name: "a"},
+	}
+	for _, label := range orphans {
+		_, err = DB.Exec(
+			"INSERT INTO Label (PK, Kind, Parent, Name, Value, Type) VALUES (?,?,?,?,'','')",
+			label.PK,
+			label.Kind,
+			label.Parent,
+			label.Name)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	removed, err = DB.CompactLabels()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(removed).To(gomega.Equal(int64(3)))
+	count, err := DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(6)))
+	// Unregistered kind retained.
+	count, err = DB.Count(&Label{}, Eq("Kind", "Unknown"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	count, err = DB.Count(&TestObject{}, Match(Labels{"a": "1"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(2)))
+}
+
+func TestFieldRef(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:    i,
+				Age:   2,
+				Int16: int16(i),
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	ids := func(predicate Predicate) []int {
+		list := []TestObject{}
+		err := DB.List(
+			&list,
+			ListOptions{
+				Sort:      []int{2},
+				Predicate: predicate,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		ids := []int{}
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+	g.Expect(ids(Lt("ID", Ref("Age")))).To(gomega.Equal([]int{0, 1}))
+	g.Expect(ids(Gt("ID", Ref("Age")))).To(gomega.Equal([]int{3, 4}))
+	g.Expect(ids(Eq("ID", Ref("Age")))).To(gomega.Equal([]int{2}))
+	g.Expect(ids(Eq("ID", Ref("Int16")))).To(gomega.Equal([]int{0, 1, 2, 3, 4}))
+	g.Expect(ids(Neq("Age", Ref("ID")))).To(gomega.Equal([]int{0, 1, 3, 4}))
+	// Not bound.
+	options := ListOptions{Predicate: Lt("ID", Ref("Age"))}
+	stmt, params, err := Table{}.Render(&TestObject{}, options)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stmt).To(gomega.ContainSubstring("ID < Age"))
+	g.Expect(len(params)).To(gomega.Equal(0))
+	// Invalid.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Lt("ID", Ref("Unknown"))})
+	g.Expect(errors.Is(err, PredicateRefErr)).To(gomega.BeTrue())
+	err = DB.List(&list, ListOptions{Predicate: Eq("ID", Ref("Name"))})
+	g.Expect(errors.Is(err, PredicateTypeErr)).To(gomega.BeTrue())
+}
+
+func TestWithoutJournal(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	err = DB.WithoutJournal(func() error {
+		for i := 0; i < 10; i++ {
+			err := DB.Insert(&TestObject{ID: i})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(&TestObject{})).To(gomega.Equal(uint64(1)))
+	err = DB.Insert(&TestObject{ID: 10})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.Generation(&TestObject{})).To(gomega.Equal(uint64(2)))
+	// Watched.
+	handler := &TestHandler{name: "A"}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	called := false
+	err = DB.WithoutJournal(func() error {
+		called = true
+		return nil
+	})
+	g.Expect(errors.Is(err, JournalWatchedError)).To(gomega.BeTrue())
+	g.Expect(called).To(gomega.BeFalse())
+}
+
+func BenchmarkInsert(b *testing.B) {
+	DB := New(
+		"/tmp/bench.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	b.ResetTimer()
+	tx, _ := DB.Begin()
+	defer tx.Commit()
+	for i := 0; i < b.N; i++ {
+		err := DB.Insert(&TestObject{ID: i, labels: Labels{"n": "1"}})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateSnapshot(b *testing.B) {
+	DB := New(
+		"/tmp/bench.db",
+		&Label{},
+		&TestLarge{})
+	err := DB.Open(true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for _, snapshot := range []int8{0, 1, 2} {
+		b.Run(
+			fmt.Sprintf("snapshot=%d", snapshot),
+			func(b *testing.B) {
+				m := &TestLarge{
+					PK:       "0",
+					data:     make([]int64, 4096),
+					snapshot: snapshot,
+				}
+				err := DB.Insert(m)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				tx, _ := DB.Begin()
+				defer tx.Commit()
+				for i := 0; i < b.N; i++ {
+					m.Name = strconv.Itoa(i)
+					err := DB.Update(m)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+				err = DB.Delete(m)
+				if err != nil {
+					b.Fatal(err)
+				}
+			})
+	}
+}
+
+func BenchmarkInsertWithoutJournal(b *testing.B) {
+	DB := New(
+		"/tmp/bench.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	b.ResetTimer()
+	err = DB.WithoutJournal(func() error {
+		tx, _ := DB.Begin()
+		defer tx.Commit()
+		for i := 0; i < b.N; i++ {
+			err := DB.Insert(&TestObject{ID: i, labels: Labels{"n": "1"}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+func TestJournalBounds(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	journal := DB.Journal()
+	journal.Enable()
+	journal.MaxStaged = 5
+	journal.QueueSize = 2
+	// Staged cap exceeded.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	g.Expect(journal.Overflow()).To(gomega.BeTrue())
+	err = tx.Commit()
+	g.Expect(errors.Is(err, JournalFullError)).To(gomega.BeTrue())
+	g.Expect(journal.Overflow()).To(gomega.BeFalse())
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+	g.Expect(DB.Generation(&TestObject{})).To(gomega.Equal(uint64(0)))
+	// Within cap.
+	tx, err = DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	count, err = DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(5)))
+	// Watch queue full (not started).
+	handler := &TestHandler{name: "A"}
+	_, err = journal.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 5; i < 8; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	g.Expect(len(handler.err)).To(gomega.Equal(1))
+}
+
+func TestWatch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	// Handler A
+	handlerA := &TestHandler{name: "A"}
+	watchA, err := DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(watchA).ToNot(gomega.BeNil())
+	N := 10
+	// Insert
+	for i := 0; i < N; i++ {
+		object := &TestObject{
+			ID:   i,
+			Name: "Elmer",
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Handler B
+	handlerB := &TestHandler{name: "B"}
+	watchB, err := DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(watchB).ToNot(gomega.BeNil())
+	// Update
+	for i := 0; i < N; i++ {
+		object := &TestObject{
+			ID:   i,
+			Name: "Fudd",
+		}
+		err = DB.Update(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Handler C
+	handlerC := &TestHandler{name: "C"}
+	watchC, err := DB.Watch(&TestObject{}, handlerC)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(watchC).ToNot(gomega.BeNil())
+	// Delete
+	for i := 0; i < N; i++ {
+		object := &TestObject{
+			ID: i,
+		}
+		err = DB.Delete(object)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	for i := 0; i < N; i++ {
+		time.Sleep(time.Millisecond * 10)
+		if len(handlerA.created) != N ||
+			len(handlerA.updated) != N ||
+			len(handlerA.deleted) != N ||
+			len(handlerB.created) != N ||
+			len(handlerB.updated) != N ||
+			len(handlerB.deleted) != N ||
+			len(handlerC.created) != N ||
+			len(handlerC.deleted) != N {
+			continue
+		} else {
+			break
+		}
+	}
+}
+
+func TestWatchResync(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handlerA := &TestHandler{name: "A"}
+	watchA, err := DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	handlerB := &TestHandler{name: "B"}
+	_, err = DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	// Resync (A only).
+	err = watchA.Resync()
+	g.Expect(err).To(gomega.BeNil())
+	// Live.
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		time.Sleep(time.Millisecond * 10)
+		if len(handlerA.created) == 4 && len(handlerB.created) == 4 {
+			break
+		}
+	}
+	g.Expect(handlerA.created).To(gomega.Equal([]int{0, 1, 2, 3}))
+	g.Expect(handlerA.deleted).To(gomega.Equal([]int{0}))
+	g.Expect(handlerA.resync).To(gomega.Equal([]int{1, 2}))
+	g.Expect(handlerB.created).To(gomega.Equal([]int{0, 1, 2, 3}))
+	g.Expect(len(handlerB.resync)).To(gomega.Equal(0))
+}
+
+type TestSnapshotHandler struct {
+	TestEventHandler
+	snapshots [][]Model
+}
+
+func (w *TestSnapshotHandler) Snapshot(models []Model) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.snapshots = append(w.snapshots, models)
+}
+
+func (w *TestSnapshotHandler) count() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return len(w.snapshots)
+}
+
+func TestWatchSnapshot(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handlerA := &TestSnapshotHandler{}
+	watchA, err := DB.Watch(&TestObject{}, handlerA)
+	g.Expect(err).To(gomega.BeNil())
+	handlerB := &TestEventHandler{}
+	_, err = DB.Watch(&TestObject{}, handlerB)
+	g.Expect(err).To(gomega.BeNil())
+	// Live.
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if len(handlerA.keys(Created)) == 1 && len(handlerB.keys(Created)) == 4 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handlerA.count()).To(gomega.Equal(1))
+	g.Expect(len(handlerA.snapshots[0])).To(gomega.Equal(3))
+	g.Expect(len(handlerA.keys(Created))).To(gomega.Equal(1))
+	g.Expect(len(handlerB.keys(Created))).To(gomega.Equal(4))
+	// Resync.
+	err = watchA.Resync()
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if handlerA.count() == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handlerA.count()).To(gomega.Equal(2))
+	ids := []int{}
+	for _, m := range handlerA.snapshots[1] {
+		ids = append(ids, m.(*TestObject).ID)
+	}
+	g.Expect(ids).To(gomega.Equal([]int{0, 1, 2, 3}))
+	g.Expect(len(handlerA.keys(Created))).To(gomega.Equal(1))
+}
+
+//
+// Records the event sequence by key.
+type TestOrderHandler struct {
+	mutex   sync.Mutex
+	events  map[int][]Event
+	ordered bool
+	count   int
+}
+
+func TestWatchInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Handler must opt-in.
+	_, err = DB.WatchWith(
+		&TestObject{},
+		&TestEventHandler{},
+		WatchOptions{Interval: time.Millisecond * 50})
+	g.Expect(errors.Is(err, SnapshotHandlerError)).To(gomega.BeTrue())
+	interval := time.Millisecond * 50
+	handlerA := &TestSnapshotHandler{}
+	_, err = DB.WatchWith(
+		&TestObject{},
+		handlerA,
+		WatchOptions{
+			Interval:     interval,
+			SnapshotOnly: true,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	handlerB := &TestSnapshotHandler{}
+	_, err = DB.WatchWith(
+		&TestObject{},
+		handlerB,
+		WatchOptions{Interval: interval})
+	g.Expect(err).To(gomega.BeNil())
+	// Initial only (suppressed when not changed).
+	time.Sleep(interval * 4)
+	g.Expect(handlerA.count()).To(gomega.Equal(1))
+	g.Expect(handlerB.count()).To(gomega.Equal(1))
+	// Changed.
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if handlerA.count() == 2 && handlerB.count() == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handlerA.count()).To(gomega.Equal(2))
+	g.Expect(handlerB.count()).To(gomega.Equal(2))
+	ids := []int{}
+	handlerA.mutex.Lock()
+	for _, m := range handlerA.snapshots[1] {
+		ids = append(ids, m.(*TestObject).ID)
+	}
+	handlerA.mutex.Unlock()
+	g.Expect(ids).To(gomega.ConsistOf(1, 2, 3))
+	// Live events (snapshot only).
+	g.Expect(len(handlerA.keys(Created))).To(gomega.Equal(0))
+	g.Expect(len(handlerA.keys(Deleted))).To(gomega.Equal(0))
+	g.Expect(len(handlerB.keys(Created))).To(gomega.Equal(1))
+	g.Expect(len(handlerB.keys(Deleted))).To(gomega.Equal(1))
+	// Suppressed (not changed).
+	time.Sleep(interval * 4)
+	g.Expect(handlerA.count()).To(gomega.Equal(2))
+	g.Expect(handlerB.count()).To(gomega.Equal(2))
+}
+
+func (w *TestOrderHandler) add(id int, e Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	list := w.events[id]
+	if len(list) > 0 && list[len(list)-1].Seq >= e.Seq {
+		w.ordered = false
+	}
+	w.events[id] = append(list, e)
+	w.count++
+}
+
+func (w *TestOrderHandler) Created(e Event) {
+	w.add(e.Model.(*TestObject).ID, e)
+}
+
+func (w *TestOrderHandler) Updated(e Event) {
+	w.add(e.Model.(*TestObject).ID, e)
+}
+
+func (w *TestOrderHandler) Deleted(e Event) {
+	w.add(e.Model.(*TestObject).ID, e)
+}
+
+func (w *TestOrderHandler) Error(err error) {
+}
+
+func (w *TestOrderHandler) End() {
+}
+
+func TestWatchOrder(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	handler := &TestOrderHandler{
+		events:  map[int][]Event{},
+		ordered: true,
+	}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	N := 20
+	R := 5
+	wg := sync.WaitGroup{}
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for r := 0; r < R; r++ {
+				object := &TestObject{ID: id}
+				err := DB.Insert(object)
+				g.Expect(err).To(gomega.BeNil())
+				object.Age = r
+				err = DB.Update(object)
+				g.Expect(err).To(gomega.BeNil())
+				err = DB.Delete(object)
+				g.Expect(err).To(gomega.BeNil())
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 200; i++ {
+		time.Sleep(time.Millisecond * 10)
+		handler.mutex.Lock()
+		count := handler.count
+		handler.mutex.Unlock()
+		if count == N*R*3 {
+			break
+		}
+	}
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	g.Expect(handler.count).To(gomega.Equal(N * R * 3))
+	g.Expect(handler.ordered).To(gomega.BeTrue())
+	for _, list := range handler.events {
+		for i, e := range list {
+			switch i % 3 {
+			case 0:
+				g.Expect(e.Action).To(gomega.Equal(Created))
+			case 1:
+				g.Expect(e.Action).To(gomega.Equal(Updated))
+			case 2:
+				g.Expect(e.Action).To(gomega.Equal(Deleted))
+			}
+		}
+	}
+}
+
+func TestWatchLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	object := &TestObject{
+		ID: 0,
+		labels: Labels{
+			"n1": "v1",
+			"n2": "v2",
+			"n3": "v3",
+		},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	object.labels = Labels{
+		"n1": "v1",
+		"n2": "changed",
+		"n4": "v4",
+	}
+	err = DB.Update(object)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if len(handler.labels) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.labels)).To(gomega.Equal(1))
+	delta := handler.labels[0]
+	g.Expect(delta.Added).To(gomega.Equal(Labels{"n4": "v4"}))
+	g.Expect(delta.Removed).To(gomega.Equal(Labels{"n3": "v3"}))
+	g.Expect(delta.Changed).To(gomega.Equal(Labels{"n2": "changed"}))
+}
+
+func TestWatchUpdatedLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	object := &TestObject{
+		ID:     0,
+		labels: Labels{"n1": "v1", "n2": "v2"},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Update.
+	object.labels = Labels{"n1": "v1", "n2": "changed"}
+	err = DB.Update(object)
+	g.Expect(err).To(gomega.BeNil())
+	// Update changed.
+	object.labels = Labels{"n3": "v3"}
+	changed, err := DB.UpdateChanged(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(changed).To(gomega.BeTrue())
+	// Update fields (labels not changed).
+	object.Name = "A"
+	err = DB.UpdateFields(object, "Name")
+	g.Expect(err).To(gomega.BeNil())
+	updated := func() []Event {
+		handler.mutex.Lock()
+		defer handler.mutex.Unlock()
+		list := []Event{}
+		for _, e := range handler.events {
+			if e.Action == Updated {
+				list = append(list, e)
+			}
+		}
+		return list
+	}
+	for i := 0; i < 100; i++ {
+		if len(updated()) == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	events := updated()
+	g.Expect(len(events)).To(gomega.Equal(3))
+	g.Expect(events[0].Model.Labels()).To(gomega.Equal(Labels{"n1": "v1", "n2": "v2"}))
+	g.Expect(events[0].Updated.Labels()).To(gomega.Equal(Labels{"n1": "v1", "n2": "changed"}))
+	g.Expect(events[1].Model.Labels()).To(gomega.Equal(Labels{"n1": "v1", "n2": "changed"}))
+	g.Expect(events[1].Updated.Labels()).To(gomega.Equal(Labels{"n3": "v3"}))
+	g.Expect(events[2].Model.Labels()).To(gomega.Equal(Labels{"n3": "v3"}))
+	g.Expect(events[2].Updated.Labels()).To(gomega.Equal(Labels{"n3": "v3"}))
+}
+
+func TestJournalSnapshot(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestLarge{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestLarge{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Custom.
+	custom := &TestLarge{PK: "1", ID: 1, data: []int64{1}, snapshot: 1}
+	err = DB.Insert(custom)
+	g.Expect(err).To(gomega.BeNil())
+	// Shared (not copied).
+	shared := &TestLarge{PK: "2", ID: 2, data: []int64{2}, snapshot: 2}
+	err = DB.Insert(shared)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(shared)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(custom)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if len(handler.keys(Deleted)) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.keys(Created)).To(gomega.Equal([]string{"1", "2"}))
+	g.Expect(handler.keys(Deleted)).To(gomega.Equal([]string{"2", "1"}))
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	events := handler.events
+	g.Expect(events[0].Model == custom).To(gomega.BeFalse())
+	g.Expect(events[0].Model.(*TestLarge).data).To(gomega.BeNil())
+	g.Expect(events[1].Model == shared).To(gomega.BeTrue())
+	// Deleted (stored model).
+	g.Expect(events[2].Model == shared).To(gomega.BeFalse())
+	g.Expect(events[2].Model.(*TestLarge).ID).To(gomega.Equal(2))
+	g.Expect(events[3].Model == custom).To(gomega.BeFalse())
+}
+
+func TestWatchCollapsed(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	err = DB.Insert(&TestObject{ID: 0, Name: "A", labels: Labels{"n1": "v1"}})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1, Name: "A"})
+	g.Expect(err).To(gomega.BeNil())
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Name: "B", labels: Labels{"n1": "v1", "n2": "v2"}})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 1, Name: "B"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Name: "C", labels: Labels{"n2": "changed"}})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Name: "D", labels: Labels{"n2": "changed", "n3": "v3"}})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	updated := func() []Event {
+		handler.mutex.Lock()
+		defer handler.mutex.Unlock()
+		list := []Event{}
+		for _, e := range handler.events {
+			if e.Action == Updated {
+				list = append(list, e)
+			}
+		}
+		return list
+	}
+	for i := 0; i < 100; i++ {
+		if len(updated()) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 10)
+	events := updated()
+	g.Expect(len(events)).To(gomega.Equal(2))
+	g.Expect(events[0].Model.(*TestObject).ID).To(gomega.Equal(1))
+	g.Expect(events[1].Model.(*TestObject).ID).To(gomega.Equal(0))
+	g.Expect(events[1].Model.(*TestObject).Name).To(gomega.Equal("A"))
+	g.Expect(events[1].Updated.(*TestObject).Name).To(gomega.Equal("D"))
+	g.Expect(events[1].Labels.Added).To(gomega.Equal(Labels{"n2": "changed", "n3": "v3"}))
+	g.Expect(events[1].Labels.Removed).To(gomega.Equal(Labels{"n1": "v1"}))
+	g.Expect(events[1].Labels.Changed).To(gomega.Equal(Labels{}))
+	g.Expect(events[1].Seq > events[0].Seq).To(gomega.BeTrue())
+}
+
+//
+// Remove leading __ to enable.
+func __TestConcurrency(t *testing.T) {
+	var err error
+
+	DB := New("/tmp/test.db", &TestObject{})
+	DB.Open(true)
+
+	N := 1000
+
+	direct := func(done chan int) {
+		for i := 0; i < N; i++ {
+			m := &TestObject{
+				ID:   i,
+				Name: "direct",
+			}
+			err := DB.Insert(m)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("direct|%d\n", i)
+			time.Sleep(time.Millisecond * 10)
+		}
+		done <- 0
+	}
+	read := func(done chan int) {
+		time.Sleep(time.Second)
+		for i := 0; i < N; i++ {
+			m := &TestObject{
+				ID:   i,
+				Name: "direct",
+			}
+			go func() {
+				err := DB.Get(m)
+				if err != nil {
+					if errors.Is(err, NotFound) {
+						fmt.Printf("read|%d _____%s\n", i, err)
+					} else {
+						panic(err)
+					}
+				}
+				fmt.Printf("read|%d\n", i)
+			}()
+			time.Sleep(time.Millisecond * time.Duration(100))
+		}
+		done <- 0
+	}
+	del := func(done chan int) {
+		time.Sleep(time.Second * 3)
+		for i := 0; i < N/2; i++ {
+			m := &TestObject{
+				ID: i,
+			}
+			go func() {
+				err := DB.Delete(m)
+				if err != nil {
+					if errors.Is(err, NotFound) {
+						fmt.Printf("del|%d _____%s\n", i, err)
+					} else {
+						panic(err)
+					}
+				}
+				fmt.Printf("del|%d\n", i)
+			}()
+			time.Sleep(time.Millisecond * time.Duration(300))
+		}
+		done <- 0
+	}
+	update := func(done chan int) {
+		for i := 0; i < N; i++ {
+			m := &TestObject{
+				ID:   i,
+				Name: "direct",
+			}
+			go func() {
+				err := DB.Update(m)
+				if err != nil {
+					if errors.Is(err, NotFound) {
+						fmt.Printf("update|%d _____%s\n", i, err)
+					} else {
+						panic(err)
+					}
+				}
+				fmt.Printf("update|%d\n", i)
+			}()
+			time.Sleep(time.Millisecond * time.Duration(20))
+		}
+		done <- 0
+	}
+	transaction := func(done chan int) {
+		var tx *Tx
+		threshold := float64(10)
+		for i := N; i < N*2; i++ {
+			if i == N || math.Mod(float64(i), threshold) == 0 {
+				tx, err = DB.Begin()
+				if err != nil {
+					panic(err)
+				}
+			}
+			m := &TestObject{
+				ID:   i,
+				Name: "transaction",
+			}
+			DB.Insert(m)
+			if err != nil {
+				panic(err)
+			}
+			//time.Sleep(time.Second*3)
+			if math.Mod(float64(i), threshold) == 0 {
+				err = tx.Commit()
+				if err != nil {
+					panic(err)
+				}
+				fmt.Printf("commit|%d\n", i)
+			}
+			fmt.Printf("transaction|%d\n", i)
+			time.Sleep(time.Millisecond * time.Duration(100))
+		}
+		done <- 0
+	}
+
+	mark := time.Now()
+
+	done := make(chan int)
+	fnList := []func(chan int){
+		direct,
+		transaction,
+		read,
+		update,
+		del,
+	}
+	for _, fn := range fnList {
+		go fn(done)
+	}
+	for range fnList {
+		<-done
+	}
+
+	fmt.Println(time.Since(mark))
+}
+
+func TestNotNull(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ddl, err := Table{}.DDL(&TestRequired{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("PK TEXT PRIMARY KEY NOT NULL"))
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Name TEXT NOT NULL"))
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestRequired{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	// Accepted.
+	err = DB.Insert(&TestRequired{PK: "0", Name: "Elmer"})
+	g.Expect(err).To(gomega.BeNil())
+	m := &TestRequired{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Age).To(gomega.Equal(18))
+	_, err = DB.InsertIfAbsent(&TestRequired{PK: "1", Name: "Fudd"})
+	g.Expect(err).To(gomega.BeNil())
+	// Rejected.
+	err = DB.Insert(&TestRequired{PK: "2"})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	_, err = DB.InsertIfAbsent(&TestRequired{PK: "2"})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	err = DB.Update(&TestRequired{PK: "0", Age: 18})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	err = DB.Update(&TestRequired{PK: "0", Name: "Elmer"})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	err = DB.UpdateFields(&TestRequired{PK: "0"}, "Name")
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	n, err := DB.Count(&TestRequired{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	DB.Close(true)
+	// PK (zero value) accepted.
+	DB = New(
+		"/tmp/test.db",
+		&Label{},
+		&TestNumbered{},
+		&TestNillable{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	err = DB.Insert(&TestNumbered{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	// Nullable.
+	ddl, err = Table{}.DDL(&TestNillable{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Rank INTEGER NOT NULL"))
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Nick TEXT NOT NULL"))
+	g.Expect(ddl[0]).To(gomega.ContainSubstring("Note TEXT NULL"))
+	nick := ""
+	err = DB.Insert(
+		&TestNillable{
+			PK:   "0",
+			Rank: Optional[int]{Valid: true},
+			Nick: &nick,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	nillable := &TestNillable{PK: "0"}
+	err = DB.Get(nillable)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(nillable.Rank).To(gomega.Equal(Optional[int]{Valid: true}))
+	g.Expect(nillable.Nick).ToNot(gomega.BeNil())
+	g.Expect(*nillable.Nick).To(gomega.Equal(""))
+	g.Expect(nillable.Note).To(gomega.BeNil())
+	note := "hello"
+	nillable.Note = &note
+	err = DB.Update(nillable)
+	g.Expect(err).To(gomega.BeNil())
+	nillable = &TestNillable{PK: "0"}
+	err = DB.Get(nillable)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(*nillable.Note).To(gomega.Equal("hello"))
+	err = DB.Insert(
+		&TestNillable{
+			PK:   "1",
+			Rank: Optional[int]{Valid: true},
+		})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+	err = DB.Insert(
+		&TestNillable{
+			PK:   "1",
+			Nick: &nick,
+		})
+	g.Expect(errors.Is(err, NotNullErr)).To(gomega.BeTrue())
+}
+
+func TestApproxCount(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	n, err := DB.ApproxCount(&TestRelated{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 10000; i++ {
+		err = DB.Insert(
+			&TestRelated{
+				PK:   fmt.Sprintf("%d", i),
+				ID:   i,
+				Name: "related",
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	for i := 0; i < 10000; i += 3 {
+		err = DB.Delete(&TestRelated{PK: fmt.Sprintf("%d", i)})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	exact, err := DB.Count(&TestRelated{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	within := func(n int64) bool {
+		return n >= exact/2 && n <= exact*2
+	}
+	// Not analyzed.
+	n, err = DB.ApproxCount(&TestRelated{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(within(n)).To(gomega.BeTrue())
+	// Analyzed.
+	_, err = DB.Exec("ANALYZE")
+	g.Expect(err).To(gomega.BeNil())
+	n, err = DB.ApproxCount(&TestRelated{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(exact))
+}
+
+func TestReplicas(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/replica.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Pragmas = map[string]string{
+		"journal_mode": "WAL",
+	}
+	DB.(*Client).Replicas = 2
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		os.Remove("/tmp/replica.db")
+		os.Remove("/tmp/replica.db-wal")
+		os.Remove("/tmp/replica.db-shm")
+	}()
+	client := DB.(*Client)
+	g.Expect(len(client.replicas)).To(gomega.Equal(2))
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(&TestObject{ID: i, Name: "Elmer"})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Reads balanced across replicas.
+	next := client.nextReplica
+	m := &TestObject{ID: 1}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer"))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+	g.Expect(client.nextReplica).To(gomega.Equal(next + 3))
+	// Writes not routed to replicas.
+	err = DB.Update(&TestObject{ID: 1, Name: "Fudd"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(client.nextReplica).To(gomega.Equal(next + 3))
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Fudd"))
+	// Replicas are read-only.
+	for _, replica := range client.replicas {
+		_, err = replica.Exec("DELETE FROM TestObject")
+		g.Expect(err).ToNot(gomega.BeNil())
+	}
+	// Closed.
+	DB.Close(false)
+	g.Expect(client.replicas).To(gomega.BeNil())
+	// Path escaped.
+	path := "/tmp/replica #1%.db"
+	DB = New(
+		path,
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Replicas = 1
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1, Name: "Elmer"})
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestObject{ID: 1}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer"))
+	DB.Close(true)
+	// Not supported in-memory.
+	DB = New(
+		":memory:",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Replicas = 1
+	err = DB.Open(true)
+	g.Expect(errors.Is(err, ReplicaNotSupportedError)).To(gomega.BeTrue())
+}
+
+func TestTxRead(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1, Name: "Elmer"})
+	g.Expect(err).To(gomega.BeNil())
+	// Client (committed).
+	m := &TestObject{ID: 1}
+	err = DB.Get(m)
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(0))
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	// Tx (staged).
+	m = &TestObject{ID: 1}
+	err = tx.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer"))
+	list = []TestObject{}
+	err = tx.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	n, err = tx.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Ended (discarded).
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Get(&TestObject{ID: 1})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	err = tx.Get(&TestObject{ID: 1})
+	g.Expect(errors.Is(err, TxInvalidError)).To(gomega.BeTrue())
+}
+
+func TestUpdateAll(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:   i,
+				Name: "Elmer",
+				Age:  i,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	n, err := DB.UpdateAll(
+		&TestObject{},
+		map[string]interface{}{
+			"Name": "Fudd",
+			"Bool": true,
+		},
+		Gt("Age", 2))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Name", "Fudd")})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	for _, m := range list {
+		g.Expect(m.Bool).To(gomega.BeTrue())
+	}
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.updated).To(gomega.Equal([]int{3, 4}))
+	// All.
+	n, err = DB.UpdateAll(
+		&TestObject{},
+		map[string]interface{}{"Age": 40},
+		nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(5)))
+	// Invalid.
+	_, err = DB.UpdateAll(
+		&TestObject{},
+		map[string]interface{}{"ID": 1},
+		nil)
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	_, err = DB.UpdateAll(
+		&TestObject{},
+		map[string]interface{}{"Unknown": 1},
+		nil)
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+}
+
+func TestNaming(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	normalized := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	g.Expect(SnakeCase("TestObject")).To(gomega.Equal("test_object"))
+	g.Expect(SnakeCase("VMCount")).To(gomega.Equal("vm_count"))
+	g.Expect(SnakeCase("PK")).To(gomega.Equal("pk"))
+	g.Expect(SnakeCase("Int8")).To(gomega.Equal("int8"))
+	g.Expect(LowerCase("FirstName")).To(gomega.Equal("firstname"))
+	// DDL.
+	table := Table{Naming: SnakeCase}
+	ddl, err := table.DDL(&TestSnake{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(ddl[0])).To(gomega.Equal(
+		"CREATE TABLE IF NOT EXISTS test_snake ( " +
+			"pk TEXT PRIMARY KEY NOT NULL ," +
+			"first_name TEXT NOT NULL ," +
+			"vm_count INTEGER NOT NULL );"))
+	g.Expect(normalized(ddl[1])).To(gomega.Equal(
+		"CREATE INDEX IF NOT EXISTS test_snakeIndex " +
+			"ON test_snake ( first_name );"))
+	g.Expect(normalized(ddl[2])).To(gomega.Equal(
+		"CREATE INDEX IF NOT EXISTS test_snake_count " +
+			"ON test_snake ( vm_count ) WHERE vm_count > 0 ;"))
+	// Predicate.
+	stmt, _, err := table.Render(
+		&TestSnake{},
+		ListOptions{
+			Predicate: And(
+				Eq("FirstName", "Elmer"),
+				Gt("vm_count", 1)),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(normalized(stmt)).To(gomega.Equal(
+		"SELECT pk ,first_name ,vm_count " +
+			"FROM test_snake " +
+			"WHERE first_name = :first_name0 AND vm_count > :vm_count1 ;"))
+	// CRUD.
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestSnake{})
+	DB.(*Client).Naming = SnakeCase
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(
+			&TestSnake{
+				FirstName: fmt.Sprintf("Elmer%d", i),
+				VMCount:   i,
+				labels:    Labels{"role": "main"},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	m := &TestSnake{FirstName: "Elmer1"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.VMCount).To(gomega.Equal(1))
+	m.VMCount = 10
+	err = DB.UpdateFields(m, "VMCount")
+	g.Expect(err).To(gomega.BeNil())
+	list := []TestSnake{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: And(
+				Gt("VMCount", 5),
+				Match(Labels{"role": "main"})),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].FirstName).To(gomega.Equal("Elmer1"))
+	counts, err := DB.LabelCounts(&TestSnake{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(counts).To(gomega.Equal(map[string]int64{"role": 3}))
+	labels := []Label{}
+	err = DB.List(&labels, ListOptions{Predicate: Eq("Kind", "test_snake")})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(labels)).To(gomega.Equal(3))
+	n, err := DB.UpdateAll(
+		&TestSnake{},
+		map[string]interface{}{"VMCount": 3},
+		Eq("FirstName", "Elmer0"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+}
+
+func TestComputedField(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ddl, err := Table{}.DDL(&TestComputed{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(ddl[0]).ToNot(gomega.ContainSubstring("Full"))
+	g.Expect(ddl[0]).ToNot(gomega.ContainSubstring("Adult"))
+	stmt, _, err := Table{}.RenderInsert(&TestComputed{PK: "0", Full: "x"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stmt).ToNot(gomega.ContainSubstring("Full"))
+	_, err = Table{}.DDL(
+		&struct {
+			PK string `sql:"pk"`
+			ID int    `sql:"key" computed:"1"`
+		}{})
+	g.Expect(errors.Is(err, ComputedFieldErr)).To(gomega.BeTrue())
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestComputed{})
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	err = DB.Insert(
+		&TestComputed{
+			PK:    "0",
+			First: "Elmer",
+			Last:  "Fudd",
+			Age:   40,
+			Full:  "ignored",
+		})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(
+		&TestComputed{
+			PK:    "1",
+			First: "Bugs",
+			Last:  "Bunny",
+			Age:   10,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	// Get.
+	m := &TestComputed{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Full).To(gomega.Equal("Elmer Fudd"))
+	g.Expect(m.Adult).To(gomega.BeTrue())
+	// Update.
+	m.Last = "Jr"
+	m.Full = "ignored"
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Full).To(gomega.Equal("Elmer Jr"))
+	err = DB.UpdateFields(m, "Full")
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	// List.
+	list := []TestComputed{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Adult", false)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].Full).To(gomega.Equal("Bugs Bunny"))
+	n, err := DB.Count(&TestComputed{}, Eq("Full", "Elmer Jr"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+}
+
+func TestListKeys(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 10; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				Age:    i,
+				labels: Labels{"even": fmt.Sprintf("%t", i%2 == 0)},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// All.
+	keys, err := DB.ListKeys(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	expected := []interface{}{}
+	for _, m := range list {
+		expected = append(expected, m.PK)
+	}
+	g.Expect(keys).To(gomega.ConsistOf(expected...))
+	// Predicate.
+	predicate := And(
+		Gt("Age", 2),
+		Match(Labels{"even": "true"}))
+	keys, err = DB.ListKeys(&TestObject{}, predicate)
+	g.Expect(err).To(gomega.BeNil())
+	list = []TestObject{}
+	err = DB.Find(&list, predicate)
+	g.Expect(err).To(gomega.BeNil())
+	expected = []interface{}{}
+	for _, m := range list {
+		expected = append(expected, m.PK)
+	}
+	g.Expect(len(keys)).To(gomega.Equal(3))
+	g.Expect(keys).To(gomega.ConsistOf(expected...))
+	// None.
+	keys, err = DB.ListKeys(&TestRelated{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(keys).To(gomega.BeEmpty())
+}
+
+func TestDiff(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	a := &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		Age:    18,
+		labels: Labels{"n1": "v1"},
+		typed:  TypedLabels{"t1": 1},
+	}
+	// Identical.
+	b := &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		Age:    18,
+		labels: Labels{"n1": "v1"},
+		typed:  TypedLabels{"t1": 1},
+	}
+	g.Expect(Diff(a, b)).To(gomega.BeEmpty())
+	// Scalar changes.
+	b.Name = "Fudd"
+	b.Bool = true
+	g.Expect(Diff(a, b)).To(gomega.Equal([]string{"Name", "Bool"}))
+	// Label changes.
+	b = &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		Age:    18,
+		labels: Labels{"n1": "changed", "n2": "v2"},
+		typed:  TypedLabels{"t1": "1"},
+	}
+	g.Expect(Diff(a, b)).To(
+		gomega.Equal(
+			[]string{
+				"label:n1",
+				"label:n2",
+				"label:t1",
+			}))
+	// Computed fields ignored.
+	g.Expect(Diff(&TestComputed{Full: "a"}, &TestComputed{Full: "b"})).To(gomega.BeEmpty())
+	// Not comparable.
+	changed, err := Diff(a, TestValue{})
+	g.Expect(errors.Is(err, MustBePtrErr)).To(gomega.BeTrue())
+	g.Expect(changed).To(gomega.BeNil())
+}
+
+func TestSweep(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := &Client{path: "/tmp/test.db"}
+	DB.Register(&TestExpiring{}, &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	now := time.Now().Unix()
+	for pk, expires := range map[string]int64{
+		"past1":  now - 100,
+		"past2":  now - 1,
+		"future": now + 1000,
+		"never":  0,
+	} {
+		err = DB.Insert(
+			&TestExpiring{
+				PK:      pk,
+				Expires: expires,
+				labels:  Labels{"n": "v"},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestExpiring{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Excluded.
+	DB.ExcludeExpired = true
+	list := []TestExpiring{}
+	err = DB.List(&list, ListOptions{Sort: []int{1}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].PK).To(gomega.Equal("future"))
+	g.Expect(list[1].PK).To(gomega.Equal("never"))
+	n, err := DB.Count(&TestExpiring{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	err = DB.Get(&TestExpiring{PK: "past1"})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	err = DB.Get(&TestExpiring{PK: "future"})
+	g.Expect(err).To(gomega.BeNil())
+	DB.ExcludeExpired = false
+	n, err = DB.Count(&TestExpiring{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(4)))
+	// Sweep.
+	n, err = DB.Sweep(&TestExpiring{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	list = []TestExpiring{}
+	err = DB.List(&list, ListOptions{Sort: []int{1}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	g.Expect(list[0].PK).To(gomega.Equal("future"))
+	g.Expect(list[1].PK).To(gomega.Equal("never"))
+	n, err = DB.Count(&Label{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	g.Eventually(func() []string {
+		return handler.keys(Deleted)
+	}).Should(gomega.ConsistOf("past1", "past2"))
+	// Not expiring.
+	_, err = DB.Sweep(&TestObject{})
+	g.Expect(errors.Is(err, NotExpiringErr)).To(gomega.BeTrue())
+}
+
+func TestSweeper(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := &Client{
+		path:          "/tmp/test.db",
+		SweepInterval: time.Millisecond * 10,
+	}
+	DB.Register(&TestExpiring{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	now := time.Now().Unix()
+	err = DB.Insert(&TestExpiring{PK: "past", Expires: now - 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestExpiring{PK: "future", Expires: now + 1000})
+	g.Expect(err).To(gomega.BeNil())
+	g.Eventually(func() int64 {
+		n, _ := DB.Count(&TestExpiring{}, nil)
+		return n
+	}).Should(gomega.Equal(int64(1)))
+	err = DB.Get(&TestExpiring{PK: "future"})
+	g.Expect(err).To(gomega.BeNil())
+	// Skipped while a transaction is in progress.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestExpiring{PK: "past2", Expires: now - 1})
+	g.Expect(err).To(gomega.BeNil())
+	time.Sleep(time.Millisecond * 50)
+	n, err := tx.Count(&TestExpiring{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	g.Eventually(func() int64 {
+		n, _ := DB.Count(&TestExpiring{}, nil)
+		return n
+	}).Should(gomega.Equal(int64(1)))
+	// Stopped.
+	err = DB.Close(true)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(DB.sweeperStop).To(gomega.BeNil())
+}
+
+func TestReconcile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				Name:   "Elmer",
+				labels: Labels{"n": "v"},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Not in scope.
+	err = DB.Insert(&TestObject{ID: 10, Name: "Fudd"})
+	g.Expect(err).To(gomega.BeNil())
+	desired := []Model{
+		&TestObject{ID: 1, Name: "Elmer", labels: Labels{"n": "v"}},
+		&TestObject{ID: 2, Name: "Elmer", Age: 62, labels: Labels{"n": "v"}},
+		&TestObject{ID: 3, Name: "Elmer", labels: Labels{"n": "v2"}},
+		&TestObject{ID: 5, Name: "Elmer"},
+		&TestObject{ID: 6, Name: "Elmer"},
+	}
+	result, err := DB.Reconcile(&TestObject{}, desired, Eq("Name", "Elmer"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(result).To(gomega.Equal(
+		ReconcileResult{
+			Created:   2,
+			Updated:   2,
+			Deleted:   2,
+			Unchanged: 1,
+		}))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}, Detail: DetailLabels})
+	g.Expect(err).To(gomega.BeNil())
+	ids := []int{}
+	for _, m := range list {
+		ids = append(ids, m.ID)
+		switch m.ID {
+		case 2:
+			g.Expect(m.Age).To(gomega.Equal(62))
+		case 3:
+			g.Expect(m.labels).To(gomega.Equal(Labels{"n": "v2"}))
+		}
+	}
+	g.Expect(ids).To(gomega.Equal([]int{1, 2, 3, 5, 6, 10}))
+	// Converged.
+	result, err = DB.Reconcile(&TestObject{}, desired, Eq("Name", "Elmer"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(result).To(gomega.Equal(ReconcileResult{Unchanged: 5}))
+	// Empty (scope) deleted.
+	result, err = DB.Reconcile(&TestObject{}, nil, Eq("Name", "Elmer"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(result).To(gomega.Equal(ReconcileResult{Deleted: 5}))
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Stored (not in scope) updated.
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	// Snapshot.
+	for i := 0; i < 100; i++ {
+		if len(handler.created) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.created).To(gomega.Equal([]int{10}))
+	result, err = DB.Reconcile(
+		&TestObject{},
+		[]Model{
+			&TestObject{ID: 10, Name: "Elmer"},
+		},
+		Eq("Name", "Elmer"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(result).To(gomega.Equal(ReconcileResult{Updated: 1}))
+	m := &TestObject{ID: 10}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("Elmer"))
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.updated).To(gomega.Equal([]int{10}))
+	g.Expect(handler.created).To(gomega.Equal([]int{10}))
+}
+
+func TestUpdateChanged(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Journal().End(watch)
+	object := &TestObject{
+		ID:     0,
+		Name:   "Elmer",
+		labels: Labels{"n1": "v1"},
+	}
+	err = DB.Insert(object)
+	g.Expect(err).To(gomega.BeNil())
+	generation := DB.Generation(object)
+	// Unchanged (natural keys, PK not set).
+	changed, err := DB.UpdateChanged(
+		&TestObject{
+			ID:     0,
+			Name:   "Elmer",
+			labels: Labels{"n1": "v1"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(changed).To(gomega.BeFalse())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(generation))
+	// Unchanged (PK set).
+	changed, err = DB.UpdateChanged(
+		&TestObject{
+			PK:     object.PK,
+			ID:     0,
+			Name:   "Elmer",
+			labels: Labels{"n1": "v1"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(changed).To(gomega.BeFalse())
+	g.Expect(DB.Generation(object)).To(gomega.Equal(generation))
+	// Changed (field).
+	changed, err = DB.UpdateChanged(
+		&TestObject{
+			ID:     0,
+			Name:   "Fudd",
+			labels: Labels{"n1": "v1"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(changed).To(gomega.BeTrue())
+	// Changed (label).
+	changed, err = DB.UpdateChanged(
+		&TestObject{
+			ID:     0,
+			Name:   "Fudd",
+			labels: Labels{"n1": "v2"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(changed).To(gomega.BeTrue())
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.updated).To(gomega.Equal([]int{0, 0}))
+	g.Expect(handler.labels[1].Changed).To(gomega.Equal(Labels{"n1": "v2"}))
+	g.Expect(DB.Generation(object)).To(gomega.Equal(generation + 2))
+	// Not found.
+	_, err = DB.UpdateChanged(&TestObject{ID: 99})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+}
+
+func TestGetAll(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	keys := []interface{}{}
+	for i := 0; i < 5; i++ {
+		object := &TestObject{
+			ID:     i,
+			Name:   fmt.Sprintf("Elmer%d", i),
+			labels: Labels{"id": fmt.Sprintf("v%d", i)},
+		}
+		err = DB.Insert(object)
+		g.Expect(err).To(gomega.BeNil())
+		if i%2 == 0 {
+			keys = append(keys, object.PK)
+		}
+	}
+	keys = append(keys, "absent")
+	models, err := DB.GetAll(&TestObject{}, keys)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(models)).To(gomega.Equal(3))
+	found := map[int]*TestObject{}
+	for _, m := range models {
+		object := m.(*TestObject)
+		found[object.ID] = object
+	}
+	for _, id := range []int{0, 2, 4} {
+		object, exists := found[id]
+		g.Expect(exists).To(gomega.BeTrue())
+		g.Expect(object.Name).To(gomega.Equal(fmt.Sprintf("Elmer%d", id)))
+		g.Expect(object.labels).To(gomega.Equal(Labels{"id": fmt.Sprintf("v%d", id)}))
+	}
+	// Batched.
+	keys = []interface{}{}
+	for i := 0; i < GetAllBatch*2+1; i++ {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+	for i := 0; i < 5; i++ {
+		object := &TestObject{ID: i}
+		err = DB.Get(object)
+		g.Expect(err).To(gomega.BeNil())
+		keys = append(keys, object.PK)
+	}
+	models, err = DB.GetAll(&TestObject{}, keys)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(models)).To(gomega.Equal(5))
+	// None.
+	models, err = DB.GetAll(&TestRelated{}, []interface{}{"0"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(models).To(gomega.BeEmpty())
+}
+
+func TestNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	os.Remove("/tmp/test.db")
+	open := func(namespace string) DB {
+		DB := New(
+			"/tmp/test.db",
+			&Label{},
+			&TestObject{})
+		DB.(*Client).Namespace = namespace
+		err := DB.Open(false)
+		g.Expect(err).To(gomega.BeNil())
+		return DB
+	}
+	dbA := open("a_")
+	defer dbA.Close(true)
+	dbB := open("b_")
+	defer dbB.Close(false)
+	// Same (PK) model and kind with different labels.
+	err := dbA.Insert(
+		&TestObject{
+			ID:     0,
+			labels: Labels{"owner": "a"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	err = dbB.Update(
+		&TestObject{
+			ID:     0,
+			labels: Labels{"owner": "b"},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	all := []Label{}
+	err = dbA.List(&all, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(all)).To(gomega.Equal(2))
+	kinds := []string{}
+	for _, l := range all {
+		kinds = append(kinds, l.Kind)
+	}
+	g.Expect(kinds).To(gomega.ConsistOf("a_TestObject", "b_TestObject"))
+	// Selectors.
+	n, err := dbA.Count(&TestObject{}, Match(Labels{"owner": "a"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	n, err = dbA.Count(&TestObject{}, Match(Labels{"owner": "b"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(0)))
+	n, err = dbB.Count(&TestObject{}, Match(Labels{"owner": "b"}))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	counts, err := dbB.LabelValueCounts(&TestObject{}, "owner")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(counts).To(gomega.Equal(map[string]int64{"b": 1}))
+	// Compact and delete within the namespace only.
+	removed, err := dbA.CompactLabels()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(removed).To(gomega.Equal(int64(0)))
+	err = dbA.Delete(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	all = []Label{}
+	err = dbB.List(&all, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(all)).To(gomega.Equal(1))
+	g.Expect(all[0].Kind).To(gomega.Equal("b_TestObject"))
+	// Invalid.
+	DB := New("/tmp/ns.db", &TestObject{})
+	DB.(*Client).Namespace = "a'"
+	err = DB.Open(true)
+	g.Expect(errors.Is(err, NamespaceInvalidError)).To(gomega.BeTrue())
+}
+
+func TestCloseInTx(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	DB.Journal().Enable()
+	handler := &TestHandler{name: "A"}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Strict.
+	DB.(*Client).StrictClose = true
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(errors.Is(err, TxInProgressError)).To(gomega.BeTrue())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	// Rolled back.
+	DB.(*Client).StrictClose = false
+	tx, err = DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.End()
+	g.Expect(errors.Is(err, TxInvalidError)).To(gomega.BeTrue())
+	g.Expect(len(DB.Journal().watches)).To(gomega.Equal(0))
+	g.Expect(len(DB.Journal().staged)).To(gomega.Equal(0))
+	// Reopened.
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	tx, err = DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.End()
+	g.Expect(err).To(gomega.BeNil())
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	for i := 0; i < 100; i++ {
+		if len(handler.created) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.created).To(gomega.Equal([]int{0}))
+}
+
+//
+// Context cancelled after `n` calls to Err().
+type TestCountdown struct {
+	context.Context
+	cancel context.CancelFunc
+	n      int
+}
+
+func (c *TestCountdown) Err() error {
+	c.n--
+	if c.n < 0 {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
+
+func TestWatchCancelled(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 2000; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	// Cancelled during replay.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	countdown := &TestCountdown{Context: ctx, cancel: cancel, n: 1000}
+	handler := &TestHandler{name: "A"}
+	watch, err := DB.WatchContext(countdown, &TestObject{}, handler)
+	g.Expect(errors.Is(err, context.Canceled)).To(gomega.BeTrue())
+	g.Expect(watch).To(gomega.BeNil())
+	g.Expect(len(DB.Journal().watches)).To(gomega.Equal(0))
+	// Cancelled before replay.
+	_, err = DB.WatchContext(ctx, &TestObject{}, handler)
+	g.Expect(errors.Is(err, context.Canceled)).To(gomega.BeTrue())
+	g.Expect(len(DB.Journal().watches)).To(gomega.Equal(0))
+	// Not cancelled.
+	handler2 := &TestHandler{name: "B"}
+	_, err = DB.Watch(&TestObject{}, handler2)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(DB.Journal().watches)).To(gomega.Equal(1))
+	err = DB.Insert(&TestObject{ID: 2000})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if len(handler2.created) == 2001 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler2.created)).To(gomega.Equal(2001))
+	g.Expect(len(handler.created)).To(gomega.Equal(0))
+	g.Expect(len(handler.err)).To(gomega.Equal(0))
+}
+
+type TestBulkHandler struct {
+	TestHandler
+	bulk []BulkEvent
+}
+
+func (w *TestBulkHandler) Bulk(e BulkEvent) {
+	w.bulk = append(w.bulk, e)
+}
+
+func TestBulkEvent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for i := 0; i < 10; i++ {
+		err = DB.Insert(&TestObject{ID: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	optIn := &TestBulkHandler{}
+	_, err = DB.Watch(&TestObject{}, optIn)
+	g.Expect(err).To(gomega.BeNil())
+	handler := &TestHandler{name: "A"}
+	_, err = DB.Watch(&TestObject{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Bulk delete.
+	n, err := DB.DeleteAll(&TestObject{}, Lt("ID", 5))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(5)))
+	err = DB.Insert(&TestObject{ID: 10})
+	g.Expect(err).To(gomega.BeNil())
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(6)))
+	for i := 0; i < 100; i++ {
+		if len(handler.created) == 11 && len(optIn.created) == 11 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	// Default.
+	g.Expect(handler.deleted).To(gomega.Equal([]int{0, 1, 2, 3, 4}))
+	g.Expect(len(handler.created)).To(gomega.Equal(11))
+	// Opt-in.
+	g.Expect(len(optIn.deleted)).To(gomega.Equal(0))
+	g.Expect(len(optIn.created)).To(gomega.Equal(11))
+	g.Expect(len(optIn.bulk)).To(gomega.Equal(1))
+	bulk := optIn.bulk[0]
+	g.Expect(bulk.Action).To(gomega.Equal(Deleted))
+	g.Expect(len(bulk.Events)).To(gomega.Equal(5))
+	for i, event := range bulk.Events {
+		g.Expect(event.Model.(*TestObject).ID).To(gomega.Equal(i))
+		g.Expect(event.Action).To(gomega.Equal(Deleted))
+	}
+	g.Expect(bulk.Seq).To(gomega.Equal(bulk.Events[4].Seq))
+	// Bulk update.
+	n, err = DB.UpdateAll(&TestObject{}, map[string]interface{}{"Name": "X"}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(6)))
+	for i := 0; i < 100; i++ {
+		if len(handler.updated) == 6 && len(optIn.bulk) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(len(handler.updated)).To(gomega.Equal(6))
+	g.Expect(len(optIn.updated)).To(gomega.Equal(0))
+	g.Expect(len(optIn.bulk)).To(gomega.Equal(2))
+	g.Expect(optIn.bulk[1].Action).To(gomega.Equal(Updated))
+	g.Expect(len(optIn.bulk[1].Events)).To(gomega.Equal(6))
+}
+
+func TestSearch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestDocument{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	search := func(query string) []string {
+		list, err := DB.Search(&TestDocument{}, query)
+		g.Expect(err).To(gomega.BeNil())
+		found := []string{}
+		for _, m := range list {
+			found = append(found, m.Pk())
+		}
+		return found
+	}
+	for _, d := range []*TestDocument{
+		{PK: "a", Title: "Red fox", Body: "The quick brown fox jumps."},
+		{PK: "b", Title: "Dogs", Body: "The lazy dog sleeps. A fox watches the dog."},
+		{PK: "c", Title: "Birds", Body: "A brown bird sings."},
+	} {
+		err = DB.Insert(d)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Term.
+	g.Expect(search("fox")).To(gomega.Equal([]string{"a", "b"}))
+	g.Expect(search("brown")).To(gomega.Equal([]string{"a", "c"}))
+	g.Expect(search("dog")).To(gomega.Equal([]string{"b"}))
+	g.Expect(search("cat")).To(gomega.Equal([]string{}))
+	// Ranked.
+	g.Expect(search("dog OR bird")).To(gomega.Equal([]string{"b", "c"}))
+	// Phrase.
+	g.Expect(search(`"brown fox"`)).To(gomega.Equal([]string{"a"}))
+	g.Expect(search(`"brown bird"`)).To(gomega.Equal([]string{"c"}))
+	// Column.
+	g.Expect(search("title:fox")).To(gomega.Equal([]string{"a"}))
+	// Models.
+	list, err := DB.Search(&TestDocument{}, "sings")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].(*TestDocument).Title).To(gomega.Equal("Birds"))
+	// Update.
+	err = DB.Update(&TestDocument{PK: "c", Title: "Birds", Body: "A red bird sings."})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(search("brown")).To(gomega.Equal([]string{"a"}))
+	g.Expect(search("red")).To(gomega.Equal([]string{"a", "c"}))
+	// Not indexed field updated.
+	err = DB.Update(&TestDocument{PK: "c", Title: "Birds", Body: "A red bird sings.", Rev: 2})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(search("red")).To(gomega.Equal([]string{"a", "c"}))
+	// Delete.
+	err = DB.Delete(&TestDocument{PK: "a"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(search("fox")).To(gomega.Equal([]string{"b"}))
+	// Existing rows indexed.
+	_, err = DB.Exec("DROP TABLE TestDocument_fts")
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Close(false)
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Open(false)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(search("red")).To(gomega.Equal([]string{"c"}))
+	g.Expect(search("dog")).To(gomega.Equal([]string{"b"}))
+	// Not indexed.
+	_, err = DB.Search(&TestObject{}, "fox")
+	g.Expect(errors.Is(err, FtsNotIndexedErr)).To(gomega.BeTrue())
+	// Reset.
+	err = DB.Reset()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(search("dog")).To(gomega.Equal([]string{}))
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	// Never completes.
+	slow := "WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM n) " +
+		"SELECT x AS ID FROM n WHERE x < 0"
+	DB.(*Client).DefaultTimeout = time.Millisecond * 100
+	mark := time.Now()
+	_, err = DB.Query(&TestObject{}, slow)
+	g.Expect(errors.Is(err, TimeoutError)).To(gomega.BeTrue())
+	g.Expect(time.Since(mark) < time.Second*10).To(gomega.BeTrue())
+	// Not exceeded.
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	n, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Exceeded before started.
+	DB.(*Client).DefaultTimeout = time.Nanosecond
+	_, err = DB.Count(&TestObject{}, nil)
+	g.Expect(errors.Is(err, TimeoutError)).To(gomega.BeTrue())
+	// Not applied to writes.
+	err = DB.Insert(&TestObject{ID: 2})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("UPDATE TestObject SET Age = 1")
+	g.Expect(err).To(gomega.BeNil())
+	// Not applied within a transaction.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	n, err = tx.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	DB.(*Client).DefaultTimeout = time.Second
+	n, err = DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(3)))
+}
+
+func TestModelForKind(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&TestObject{},
+		&TestRelated{},
+		&TestNamed{})
+	// Registered.
+	for kind, expected := range map[string]interface{}{
+		"TestObject":    &TestObject{},
+		"TestRelated":   &TestRelated{},
+		"named_objects": &TestNamed{},
+	} {
+		m, err := DB.ModelForKind(kind)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(m).To(gomega.BeAssignableToTypeOf(expected))
+		g.Expect(m).To(gomega.Equal(expected))
 	}
+	// Fresh instance.
+	a, _ := DB.ModelForKind("TestObject")
+	b, _ := DB.ModelForKind("TestObject")
+	a.(*TestObject).ID = 1
+	g.Expect(b.(*TestObject).ID).To(gomega.Equal(0))
+	// Unknown.
+	_, err := DB.ModelForKind("TestNamed")
+	g.Expect(errors.Is(err, KindNotFoundError)).To(gomega.BeTrue())
+	_, err = DB.ModelForKind("Label")
+	g.Expect(errors.Is(err, KindNotFoundError)).To(gomega.BeTrue())
+	// Namespaced (indexed on open).
+	DB.(*Client).Namespace = "ns1_"
+	err = DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	m, err := DB.ModelForKind("ns1_TestObject")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m).To(gomega.BeAssignableToTypeOf(&TestObject{}))
+	_, err = DB.ModelForKind("TestObject")
+	g.Expect(errors.Is(err, KindNotFoundError)).To(gomega.BeTrue())
+	_, err = DB.ModelForKind("ns1_Label")
+	g.Expect(err).To(gomega.BeNil())
+	// Labels resolved.
+	err = DB.Insert(&TestObject{ID: 1, labels: Labels{"a": "1"}})
+	g.Expect(err).To(gomega.BeNil())
+	labels := []Label{}
+	err = DB.List(&labels, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(labels)).To(gomega.Equal(1))
+	m, err = DB.ModelForKind(labels[0].Kind)
+	g.Expect(err).To(gomega.BeNil())
+	object := m.(*TestObject)
+	object.PK = labels[0].Parent
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.ID).To(gomega.Equal(1))
 }
 
-func (w *TestHandler) Updated(e Event) {
-	if object, cast := e.Model.(*TestObject); cast {
-		w.updated = append(w.updated, object.ID)
+func TestViewConsistency(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/view.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).Pragmas = map[string]string{
+		"journal_mode": "WAL",
+	}
+	DB.(*Client).Naming = SnakeCase
+	DB.(*Client).Namespace = "ns1_"
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		DB.Close(true)
+		os.Remove("/tmp/view.db-wal")
+		os.Remove("/tmp/view.db-shm")
+	}()
+	for i := 0; i < 3; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				Age:    1,
+				labels: Labels{"n": fmt.Sprintf("%d", i)},
+			})
+		g.Expect(err).To(gomega.BeNil())
 	}
+	update := func() {
+		done := make(chan error)
+		go func() {
+			_, err := DB.UpdateAll(
+				&TestObject{},
+				map[string]interface{}{"Age": 2},
+				nil)
+			done <- err
+		}()
+		g.Expect(<-done).To(gomega.BeNil())
+	}
+	// Without view.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list[0].Age).To(gomega.Equal(1))
+	update()
+	object := &TestObject{ID: 0}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Age).To(gomega.Equal(2))
+	// With view.
+	err = DB.View(func(v *View) error {
+		list := []TestObject{}
+		err := v.List(&list, ListOptions{Predicate: Match(Labels{"n": "0"})})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(list)).To(gomega.Equal(1))
+		g.Expect(list[0].Age).To(gomega.Equal(2))
+		_, err = DB.UpdateAll(
+			&TestObject{},
+			map[string]interface{}{"Age": 3},
+			nil)
+		g.Expect(err).To(gomega.BeNil())
+		object := &TestObject{ID: 0}
+		err = v.Get(object)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(object.Age).To(gomega.Equal(2))
+		n, err := v.Count(&TestObject{}, Eq("Age", 2))
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(n).To(gomega.Equal(int64(3)))
+		return nil
+	})
+	g.Expect(err).To(gomega.BeNil())
+	object = &TestObject{ID: 0}
+	err = DB.Get(object)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(object.Age).To(gomega.Equal(3))
 }
-func (w *TestHandler) Deleted(e Event) {
-	if object, cast := e.Model.(*TestObject); cast {
-		w.deleted = append(w.deleted, object.ID)
+
+func TestWatchFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestObject{ID: i, Age: i * 3})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Derived: age in months >= 72.
+	handler := &TestHandler{name: "A"}
+	_, err = DB.WatchWith(
+		&TestObject{},
+		handler,
+		WatchOptions{
+			Filter: func(m Model) bool {
+				return m.(*TestObject).Age*12 >= 72
+			},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 5, Age: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 6, Age: 9})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Age: 10})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 2, Age: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 1, Age: 4})
+	g.Expect(err).To(gomega.BeNil())
+	// Deleted by PK (filtered using the stored model).
+	err = DB.Delete(&TestObject{ID: 3})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestObject{ID: 5})
+	g.Expect(err).To(gomega.BeNil())
+	deleted, err := DB.DeleteIf(&TestObject{ID: 4}, Eq("Age", 12))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(deleted).To(gomega.BeTrue())
+	err = DB.Delete(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 100; i++ {
+		if len(handler.deleted) == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
 	}
+	g.Expect(handler.created).To(gomega.Equal([]int{2, 3, 4, 6}))
+	g.Expect(handler.updated).To(gomega.Equal([]int{0, 2}))
+	g.Expect(handler.deleted).To(gomega.Equal([]int{3, 4}))
 }
 
-func (w *TestHandler) Error(err error) {
-	w.err = append(w.err, err)
+func TestWatchKinds(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestRelated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestRelated{PK: "R0", ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	handler := &TestEventHandler{}
+	_, err = DB.WatchWith(
+		&TestObject{},
+		handler,
+		WatchOptions{
+			Kinds: []Model{&TestRelated{}},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	// Interleaved kinds committed by a transaction.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestRelated{PK: "R1", ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestObject{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Delete(&TestRelated{PK: "R0", ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Update(&TestObject{ID: 0, Name: "A"})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	kinds := func() []string {
+		handler.mutex.Lock()
+		defer handler.mutex.Unlock()
+		kinds := []string{}
+		for _, e := range handler.events {
+			kinds = append(kinds, fmt.Sprintf("%T:%d", e.Model, e.Action))
+		}
+		return kinds
+	}
+	for i := 0; i < 100; i++ {
+		if len(kinds()) == 6 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(kinds()).To(
+		gomega.Equal([]string{
+			"*model.TestObject:1",
+			"*model.TestRelated:1",
+			"*model.TestRelated:1",
+			"*model.TestObject:1",
+			"*model.TestRelated:4",
+			"*model.TestObject:2",
+		}))
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	for i := 3; i < len(handler.events); i++ {
+		g.Expect(handler.events[i].Seq > handler.events[i-1].Seq).To(gomega.BeTrue())
+	}
 }
 
-func (w *TestHandler) End() {
+func TestGetByUnique(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestUnique{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestUnique{
+				PK:     fmt.Sprintf("pk%d", i),
+				Email:  fmt.Sprintf("e%d@x.com", i),
+				Region: fmt.Sprintf("r%d", i%2),
+				Host:   fmt.Sprintf("h%d", i),
+				Name:   fmt.Sprintf("n%d", i),
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Single column.
+	m := &TestUnique{Email: "e3@x.com"}
+	err = DB.GetByUnique(m, "email")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.PK).To(gomega.Equal("pk3"))
+	g.Expect(m.Name).To(gomega.Equal("n3"))
+	// Composite.
+	m = &TestUnique{Region: "r0", Host: "h2"}
+	err = DB.GetByUnique(m, "host")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.PK).To(gomega.Equal("pk2"))
+	g.Expect(m.Email).To(gomega.Equal("e2@x.com"))
+	// Not found.
+	m = &TestUnique{Region: "r1", Host: "h2"}
+	err = DB.GetByUnique(m, "host")
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	// Unknown index.
+	err = DB.GetByUnique(m, "other")
+	g.Expect(errors.Is(err, UniqueIndexErr)).To(gomega.BeTrue())
+}
+
+func TestMaxListRows(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	DB.(*Client).MaxListRows = 10
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 25; i++ {
+		err = DB.Insert(&TestObject{ID: i, Age: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Truncated.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(errors.Is(err, ResultTruncated)).To(gomega.BeTrue())
+	g.Expect(len(list)).To(gomega.Equal(10))
+	// Not exceeded.
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Lt("Age", 10)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(10))
+	// Explicit (smaller) limit.
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Page: &Page{Limit: 5}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(5))
+	// Explicit (larger) limit.
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Page: &Page{Limit: 20}})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(20))
+	// Disabled.
+	DB.(*Client).MaxListRows = 0
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(25))
+}
+
+func TestLintIndexes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger := &TestLogger{}
+	DB := &Client{
+		path:        "/tmp/test.db",
+		Log:         logger,
+		LintIndexes: true,
+	}
+	DB.Register(&Label{}, &TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestObject{ID: i, Name: "A"})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	suggested := "Full table scan, consider an index."
+	// Indexed.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("ID", 1)})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Count(&TestObject{}, Eq("ID", 1))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(logger.entries).ToNot(gomega.ContainElement(suggested))
+	// Not filtered.
+	err = DB.List(&list, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(logger.entries).ToNot(gomega.ContainElement(suggested))
+	// Not indexed.
+	err = DB.List(&list, ListOptions{Predicate: Eq("Name", "A")})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(logger.entries).To(gomega.Equal([]string{suggested}))
+	g.Expect(logger.values[0][3]).To(gomega.Equal([]string{"Name"}))
+	_, err = DB.Count(&TestObject{}, Eq("Name", "A"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(logger.entries).To(gomega.Equal([]string{suggested, suggested}))
+	// Disabled.
+	DB.LintIndexes = false
+	err = DB.List(&list, ListOptions{Predicate: Eq("Name", "A")})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(logger.entries)).To(gomega.Equal(2))
+}
+
+func TestEnumField(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestEnum{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	phases := []TestPhase{
+		TestPending,
+		TestRunning,
+		TestRunning,
+		TestSucceeded,
+		TestFailed,
+	}
+	for i, phase := range phases {
+		err = DB.Insert(
+			&TestEnum{
+				PK:    strconv.Itoa(i),
+				Phase: phase,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Round trip.
+	m := &TestEnum{PK: "3"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Phase).To(gomega.Equal(TestSucceeded))
+	m.Phase = TestFailed
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestEnum{PK: "3"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Phase).To(gomega.Equal(TestFailed))
+	// Eq.
+	list := []TestEnum{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Phase", TestRunning)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	for _, m := range list {
+		g.Expect(m.Phase).To(gomega.Equal(TestRunning))
+	}
+	// In.
+	list = []TestEnum{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: In("Phase", []TestPhase{TestPending, TestFailed}),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	// Count.
+	n, err := DB.Count(&TestEnum{}, Gt("Phase", TestRunning))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(2)))
+}
+
+func TestDurationField(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestTimer{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	timeouts := []time.Duration{
+		time.Millisecond * 500,
+		time.Second,
+		time.Second * 30,
+		time.Minute,
+		time.Hour,
+	}
+	for i, timeout := range timeouts {
+		err = DB.Insert(
+			&TestTimer{
+				PK:       strconv.Itoa(i),
+				Timeout:  timeout,
+				Interval: -timeout,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Round trip.
+	m := &TestTimer{PK: "2"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Timeout).To(gomega.Equal(time.Second * 30))
+	g.Expect(m.Interval).To(gomega.Equal(-time.Second * 30))
+	m.Timeout = time.Minute + time.Nanosecond
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestTimer{PK: "2"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Timeout).To(gomega.Equal(time.Minute + time.Nanosecond))
+	// Stored as (int) nanoseconds.
+	var stored int64
+	err = DB.(*Client).db.QueryRow(
+		"SELECT timeout FROM TestTimer WHERE pk = '0'").Scan(&stored)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stored).To(gomega.Equal(int64(time.Millisecond * 500)))
+	// Gt.
+	list := []TestTimer{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Gt("Timeout", time.Second*30),
+			Sort:      []int{1},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	for _, m := range list {
+		g.Expect(m.Timeout > time.Second*30).To(gomega.BeTrue())
+	}
+	// Lt.
+	list = []TestTimer{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Lt("Timeout", time.Second),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].Timeout).To(gomega.Equal(time.Millisecond * 500))
+	// Field reference.
+	list = []TestTimer{}
+	err = DB.List(
+		&list,
+		ListOptions{
+			Predicate: Gt("Timeout", Ref("Interval")),
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(len(timeouts)))
+}
+
+func TestQueryInto(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 10; i++ {
+		err = DB.Insert(&TestObject{ID: i, Name: fmt.Sprintf("n%d", i%3), Age: i})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	type Report struct {
+		Name   string
+		Count  int64
+		MaxAge int
+		hidden int
+	}
+	list := []Report{}
+	err = DB.QueryInto(
+		&list,
+		"SELECT Name, COUNT(*) AS count, MAX(Age) AS MaxAge, 1 AS other "+
+			"FROM TestObject WHERE Age > ? GROUP BY Name ORDER BY Name",
+		0)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal([]Report{
+			{Name: "n0", Count: 3, MaxAge: 9},
+			{Name: "n1", Count: 3, MaxAge: 7},
+			{Name: "n2", Count: 3, MaxAge: 8},
+		}))
+	// Not a struct list.
+	err = DB.QueryInto(&[]int{}, "SELECT 1")
+	g.Expect(errors.Is(err, MustBeObjectErr)).To(gomega.BeTrue())
+	err = DB.QueryInto(list, "SELECT 1")
+	g.Expect(errors.Is(err, MustBeSlicePtrErr)).To(gomega.BeTrue())
+}
+
+func TestListProjection(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:   i,
+				Name: "n" + strconv.Itoa(i),
+				Age:  i * 10,
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	type Summary struct {
+		ID      int
+		Name    string
+		Unknown string
+	}
+	list := []Summary{}
+	err = DB.ListProjection(
+		&list,
+		&TestObject{},
+		[]string{"ID", "Name"},
+		ListOptions{
+			Predicate: Gt("Age", 10),
+			Sort:      []int{1},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(list).To(
+		gomega.Equal(
+			[]Summary{
+				{ID: 2, Name: "n2"},
+				{ID: 3, Name: "n3"},
+				{ID: 4, Name: "n4"},
+			}))
+	// Converted.
+	type Age struct {
+		Age int64
+	}
+	ages := []Age{}
+	err = DB.ListProjection(&ages, &TestObject{}, []string{"Age"}, ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(ages)).To(gomega.Equal(5))
+	// Unknown field.
+	err = DB.ListProjection(&list, &TestObject{}, []string{"Unknown"}, ListOptions{})
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	// Not a slice.
+	err = DB.ListProjection(&Summary{}, &TestObject{}, []string{"ID"}, ListOptions{})
+	g.Expect(errors.Is(err, MustBeSlicePtrErr)).To(gomega.BeTrue())
+}
+
+func TestTouch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{},
+		&TestStamped{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	m := &TestStamped{PK: "0", Name: "A", Age: 1}
+	err = DB.Insert(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Updated > 0).To(gomega.BeTrue())
+	inserted := m.Updated
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestStamped{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Touch (other fields not changed).
+	touched := &TestStamped{PK: "0", Name: "B", Age: 2}
+	err = DB.Touch(touched)
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestStamped{PK: "0"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Updated > inserted).To(gomega.BeTrue())
+	g.Expect(m.Name).To(gomega.Equal("A"))
+	g.Expect(m.Age).To(gomega.Equal(1))
+	for i := 0; i < 100; i++ {
+		if len(handler.keys(Updated)) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	g.Expect(handler.keys(Updated)).To(gomega.Equal([]string{"0"}))
+	handler.mutex.Lock()
+	event := handler.events[len(handler.events)-1]
+	handler.mutex.Unlock()
+	g.Expect(event.Model.(*TestStamped).Updated).To(gomega.Equal(inserted))
+	g.Expect(event.Updated.(*TestStamped).Updated).To(gomega.Equal(m.Updated))
+	g.Expect(event.Updated.(*TestStamped).Name).To(gomega.Equal("A"))
+	// Update.
+	m.Age = 3
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Updated > event.Updated.(*TestStamped).Updated).To(gomega.BeTrue())
+	// Not found.
+	err = DB.Touch(&TestStamped{PK: "1"})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	// Without an updated field.
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Touch(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
 }
 
-func TestCRUD(t *testing.T) {
-	var err error
+func TestListSince(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New(
 		"/tmp/test.db",
 		&Label{},
-		&TestObject{})
-	err = DB.Open(true)
+		&TestObject{},
+		&TestStamped{})
+	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	objA := &TestObject{
-		ID:    0,
-		Name:  "Elmer",
-		Age:   18,
-		Int8:  8,
-		Int16: 16,
-		Int32: 32,
-		Bool:  true,
-		labels: Labels{
-			"n1": "v1",
-			"n2": "v2",
-		},
-	}
-	assertEqual := func(a, b *TestObject) {
-		g.Expect(a.PK).To(gomega.Equal(b.PK))
-		g.Expect(a.ID).To(gomega.Equal(b.ID))
-		g.Expect(a.Name).To(gomega.Equal(b.Name))
-		g.Expect(a.Age).To(gomega.Equal(b.Age))
-		g.Expect(a.Int8).To(gomega.Equal(b.Int8))
-		g.Expect(a.Int16).To(gomega.Equal(b.Int16))
-		g.Expect(a.Int32).To(gomega.Equal(b.Int32))
-		g.Expect(a.Bool).To(gomega.Equal(b.Bool))
-		for k, v := range objA.labels {
-			l := &Label{
-				Kind:   ref.ToKind(a),
-				Parent: a.PK,
-				Name:   k,
-			}
-			g.Expect(DB.Get(l)).To(gomega.BeNil())
-			g.Expect(v).To(gomega.Equal(l.Value))
-		}
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestStamped{PK: strconv.Itoa(i), Age: i})
+		g.Expect(err).To(gomega.BeNil())
 	}
-	// Insert
-	err = DB.Insert(objA)
+	time.Sleep(time.Millisecond)
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	err = DB.Insert(&TestStamped{PK: "5", Age: 5})
 	g.Expect(err).To(gomega.BeNil())
-	objB := &TestObject{ID: objA.ID}
-	// Get
-	err = DB.Get(objB)
+	err = DB.Update(&TestStamped{PK: "1", Age: 10})
 	g.Expect(err).To(gomega.BeNil())
-	assertEqual(objA, objB)
-	// Update
-	objA.Name = "Larry"
-	objA.Age = 21
-	objA.Bool = false
-	err = DB.Update(objA)
+	err = DB.Touch(&TestStamped{PK: "3"})
 	g.Expect(err).To(gomega.BeNil())
-	// Get
-	objB = &TestObject{ID: objA.ID}
-	err = DB.Get(objB)
+	n, err := DB.UpdateAll(
+		&TestStamped{},
+		map[string]interface{}{"Name": "X"},
+		Eq("PK", "2"))
 	g.Expect(err).To(gomega.BeNil())
-	assertEqual(objA, objB)
-	// Delete
-	objA = &TestObject{ID: objA.ID}
-	err = DB.Delete(objA)
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	incremented := &TestStamped{PK: "4"}
+	n, err = DB.Increment(incremented, "Age", 1)
 	g.Expect(err).To(gomega.BeNil())
-	// Get (not found)
-	objB = &TestObject{ID: objA.ID}
-	err = DB.Get(objB)
-	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	g.Expect(n).To(gomega.Equal(int64(5)))
+	g.Expect(incremented.Updated > since.UnixNano()).To(gomega.BeTrue())
+	// Since.
+	list := []TestStamped{}
+	err = DB.ListSince(&list, since, ListOptions{Sort: []int{1}})
+	g.Expect(err).To(gomega.BeNil())
+	pks := []string{}
+	for _, m := range list {
+		pks = append(pks, m.PK)
+	}
+	g.Expect(pks).To(gomega.Equal([]string{"1", "2", "3", "4", "5"}))
+	// With predicate.
+	list = []TestStamped{}
+	err = DB.ListSince(&list, since, ListOptions{Predicate: Gt("Age", 4)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	// Now.
+	list = []TestStamped{}
+	err = DB.ListSince(&list, time.Now(), ListOptions{})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(0))
+	// Without an updated field.
+	err = DB.ListSince(&[]TestObject{}, since, ListOptions{})
+	g.Expect(errors.Is(err, NotUpdatedErr)).To(gomega.BeTrue())
 }
 
-func TestTransactions(t *testing.T) {
+func TestDeferForeignKeys(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New(
 		"/tmp/test.db",
 		&Label{},
-		&TestObject{})
+		&TestParent{},
+		&TestChild{})
 	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	// Begin
+	defer DB.Close(true)
+	DB.Journal().Enable()
+	handler := &TestEventHandler{}
+	_, err = DB.Watch(&TestChild{}, handler)
+	g.Expect(err).To(gomega.BeNil())
+	// Not deferred.
 	tx, err := DB.Begin()
-	defer tx.End()
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
-	object := &TestObject{
-		ID:   0,
-		Name: "Elmer",
-	}
-	err = DB.Insert(object)
+	err = DB.Insert(&TestChild{PK: "C0", Parent: "P0"})
+	g.Expect(err).ToNot(gomega.BeNil())
+	err = tx.End()
 	g.Expect(err).To(gomega.BeNil())
-	// Get (not found)
-	object = &TestObject{ID: object.ID}
-	err = DB.Get(object)
-	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
-	tx.Commit()
-	// Get (found)
-	object = &TestObject{ID: object.ID}
-	err = DB.Get(object)
+	// Deferred (child before parent).
+	tx, err = DB.BeginWith(TxOptions{DeferForeignKeys: true})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestChild{PK: "C0", Parent: "P0"})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestParent{PK: "P0"})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
 	g.Expect(err).To(gomega.BeNil())
+	n, err := DB.Count(&TestChild{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Deferred (violated).
+	tx, err = DB.BeginWith(TxOptions{DeferForeignKeys: true})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.Insert(&TestChild{PK: "C1", Parent: "P1"})
+	g.Expect(err).To(gomega.BeNil())
+	err = tx.Commit()
+	g.Expect(errors.Is(err, ConflictError)).To(gomega.BeTrue())
+	n, err = DB.Count(&TestChild{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(int64(1)))
+	// Not deferred after the transaction.
+	err = DB.Insert(&TestChild{PK: "C2", Parent: "P2"})
+	g.Expect(err).ToNot(gomega.BeNil())
+	time.Sleep(time.Millisecond * 50)
+	g.Expect(handler.keys(Created)).To(gomega.Equal([]string{"C0"}))
 }
 
-func TestGetForUpdate(t *testing.T) {
+func TestRelatedModels(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New(
 		"/tmp/test.db",
 		&Label{},
-		&TestObject{})
+		&TestParent{},
+		&TestChild{})
 	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	// Insert
-	object := &TestObject{
-		ID:   0,
-		Name: "Elmer",
+	defer DB.Close(true)
+	for i := 0; i < 2; i++ {
+		err = DB.Insert(&TestParent{PK: fmt.Sprintf("P%d", i), Name: fmt.Sprintf("n%d", i)})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestChild{PK: fmt.Sprintf("C%d", i), Parent: fmt.Sprintf("P%d", i%2)})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Related.
+	parent := &TestParent{}
+	err = DB.Related(&TestChild{PK: "C3", Parent: "P1"}, "Parent", parent)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(parent.PK).To(gomega.Equal("P1"))
+	g.Expect(parent.Name).To(gomega.Equal("n1"))
+	err = DB.Related(&TestChild{PK: "C3", Parent: "P9"}, "Parent", &TestParent{})
+	g.Expect(errors.Is(err, NotFound)).To(gomega.BeTrue())
+	err = DB.Related(&TestChild{}, "Other", &TestParent{})
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+	// List.
+	children := []TestChild{}
+	err = DB.RelatedList(&TestParent{PK: "P0"}, &TestChild{}, "Parent", &children)
+	g.Expect(err).To(gomega.BeNil())
+	pks := []string{}
+	for _, m := range children {
+		pks = append(pks, m.PK)
+	}
+	g.Expect(pks).To(gomega.ConsistOf("C0", "C2", "C4"))
+	err = DB.RelatedList(&TestParent{PK: "P0"}, &TestChild{}, "Other", &children)
+	g.Expect(errors.Is(err, FieldRefErr)).To(gomega.BeTrue())
+}
+
+func TestGenerateID(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := &Client{
+		path:       "/tmp/test.db",
+		GenerateID: UUID,
 	}
+	DB.Register(
+		&Label{},
+		&TestObject{},
+		&TestParent{},
+		&TestGenerated{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	// Client generator.
+	m := &TestParent{Name: "A"}
+	err = DB.Insert(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.PK).To(gomega.MatchRegexp(
+		"^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"))
+	id := m.PK
+	m.Name = "B"
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.PK).To(gomega.Equal(id))
+	m = &TestParent{PK: id}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("B"))
+	// Set (not generated).
+	m = &TestParent{PK: "P0"}
+	err = DB.Insert(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.PK).To(gomega.Equal("P0"))
+	// Model generator.
+	generated := &TestGenerated{Name: "A"}
+	err = DB.Insert(generated)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(generated.PK).To(gomega.Equal("G-A"))
+	// Natural keys (sha1).
+	object := &TestObject{ID: 1}
 	err = DB.Insert(object)
 	g.Expect(err).To(gomega.BeNil())
-	tx, err := DB.GetForUpdate(object)
+	g.Expect(len(object.PK)).To(gomega.Equal(40))
+	// ULID.
+	ulid := &ULID{}
+	DB.GenerateID = ulid.Generate
+	m = &TestParent{}
+	err = DB.Insert(m)
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(tx.ref).To(gomega.Equal(DB.(*Client).tx))
-	tx.Commit()
+	g.Expect(m.PK).To(gomega.MatchRegexp("^[0-9A-HJKMNP-TV-Z]{26}$"))
+	last := m.PK
+	for i := 0; i < 1000; i++ {
+		next := ulid.Generate()
+		g.Expect(next > last).To(gomega.BeTrue())
+		last = next
+	}
 }
 
-func TestList(t *testing.T) {
-	var err error
+func TestListChunked(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New(
 		"/tmp/test.db",
 		&Label{},
 		&TestObject{})
-	err = DB.Open(true)
+	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	for i := 0; i < 10; i++ {
-		object := &TestObject{
-			ID: i,
-			labels: Labels{
-				"id": fmt.Sprintf("v%d", i),
-			},
-		}
-		err = DB.Insert(object)
+	defer DB.Close(true)
+	for i := 0; i < 25; i++ {
+		err = DB.Insert(&TestObject{ID: i, Age: i})
 		g.Expect(err).To(gomega.BeNil())
 	}
-	// List all.
-	list := []TestObject{}
-	err = DB.List(&list, ListOptions{})
+	// All.
+	sizes := []int{}
+	seen := map[string]int{}
+	err = DB.ListChunked(
+		&TestObject{},
+		ListOptions{},
+		10,
+		func(chunk []Model) error {
+			sizes = append(sizes, len(chunk))
+			for _, m := range chunk {
+				seen[m.(*TestObject).PK]++
+			}
+			return nil
+		})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(10))
-	// List = (single).
-	list = []TestObject{}
-	err = DB.List(
-		&list,
-		ListOptions{
-			Predicate: Eq("ID", 0),
+	g.Expect(sizes).To(gomega.Equal([]int{10, 10, 5}))
+	g.Expect(len(seen)).To(gomega.Equal(25))
+	for _, n := range seen {
+		g.Expect(n).To(gomega.Equal(1))
+	}
+	// Predicate.
+	sizes = []int{}
+	ids := []int{}
+	err = DB.ListChunked(
+		&TestObject{},
+		ListOptions{Predicate: Gt("Age", 4)},
+		5,
+		func(chunk []Model) error {
+			sizes = append(sizes, len(chunk))
+			for _, m := range chunk {
+				ids = append(ids, m.(*TestObject).ID)
+			}
+			return nil
 		})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(1))
-	g.Expect(list[0].ID).To(gomega.Equal(0))
-	// List != AND
-	list = []TestObject{}
-	err = DB.List(
-		&list,
-		ListOptions{
-			Predicate: And( // Even only.
-				Neq("ID", 1),
-				Neq("ID", 3),
-				Neq("ID", 5),
-				Neq("ID", 7),
-				Neq("ID", 9)),
+	g.Expect(sizes).To(gomega.Equal([]int{5, 5, 5, 5}))
+	expected := []int{}
+	for i := 5; i < 25; i++ {
+		expected = append(expected, i)
+	}
+	g.Expect(ids).To(gomega.ConsistOf(expected))
+	// Deleted while listing.
+	n := 0
+	err = DB.ListChunked(
+		&TestObject{},
+		ListOptions{},
+		10,
+		func(chunk []Model) error {
+			for _, m := range chunk {
+				n++
+				err := DB.Delete(m)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(5))
-	g.Expect(list[0].ID).To(gomega.Equal(0))
-	g.Expect(list[1].ID).To(gomega.Equal(2))
-	g.Expect(list[2].ID).To(gomega.Equal(4))
-	g.Expect(list[3].ID).To(gomega.Equal(6))
-	g.Expect(list[4].ID).To(gomega.Equal(8))
-	// List OR =.
-	list = []TestObject{}
-	err = DB.List(
+	g.Expect(n).To(gomega.Equal(25))
+	count, err := DB.Count(&TestObject{}, nil)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(0)))
+	// Error.
+	stop := errors.New("stop")
+	err = DB.Insert(&TestObject{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	err = DB.ListChunked(
+		&TestObject{},
+		ListOptions{},
+		1,
+		func(chunk []Model) error {
+			return stop
+		})
+	g.Expect(errors.Is(err, stop)).To(gomega.BeTrue())
+	err = DB.ListChunked(
+		&TestObject{},
+		ListOptions{},
+		0,
+		func(chunk []Model) error {
+			return nil
+		})
+	g.Expect(errors.Is(err, ChunkSizeErr)).To(gomega.BeTrue())
+}
+
+func TestListAfter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 30; i++ {
+		err = DB.Insert(&TestObject{ID: i, Age: i % 5})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Page (by Age) with inserts between pages.
+	options := ListOptions{
+		Sort: []int{4},
+		Page: &Page{Limit: 7},
+	}
+	pages := 0
+	ages := []int{}
+	seen := map[int]int{}
+	for {
+		list := []TestObject{}
+		next, err := DB.ListAfter(&list, options)
+		g.Expect(err).To(gomega.BeNil())
+		for _, m := range list {
+			ages = append(ages, m.Age)
+			seen[m.ID]++
+		}
+		pages++
+		if pages == 1 {
+			// before the page.
+			err = DB.Insert(&TestObject{ID: 100, Age: 0})
+			g.Expect(err).To(gomega.BeNil())
+			// after the page.
+			err = DB.Insert(&TestObject{ID: 101, Age: 4})
+			g.Expect(err).To(gomega.BeNil())
+		}
+		if next == nil {
+			break
+		}
+		options.After = next
+	}
+	g.Expect(pages).To(gomega.Equal(5))
+	g.Expect(len(seen)).To(gomega.Equal(31))
+	for id, n := range seen {
+		g.Expect(id).ToNot(gomega.Equal(100))
+		g.Expect(n).To(gomega.Equal(1))
+	}
+	g.Expect(seen[101]).To(gomega.Equal(1))
+	for i := 1; i < len(ages); i++ {
+		g.Expect(ages[i] >= ages[i-1]).To(gomega.BeTrue())
+	}
+	// List honors the token.
+	list := []TestObject{}
+	next, err := DB.ListAfter(
 		&list,
 		ListOptions{
-			Predicate: Or(
-				Eq("ID", 0),
-				Eq("ID", 6)),
+			Sort: []int{4},
+			Page: &Page{Limit: 3},
 		})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(2))
-	g.Expect(list[0].ID).To(gomega.Equal(0))
-	g.Expect(list[1].ID).To(gomega.Equal(6))
-	// List < (lt).
+	g.Expect(len(next)).To(gomega.Equal(2))
 	list = []TestObject{}
 	err = DB.List(
 		&list,
 		ListOptions{
-			Predicate: Lt("ID", 2),
+			Sort:      []int{4},
+			After:     next,
+			Predicate: Eq("Age", 0),
 		})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(2))
-	g.Expect(list[0].ID).To(gomega.Equal(0))
-	g.Expect(list[1].ID).To(gomega.Equal(1))
-	// List > (gt).
-	list = []TestObject{}
+	g.Expect(len(list)).To(gomega.Equal(4))
+	// Not valid.
 	err = DB.List(
 		&list,
 		ListOptions{
-			Predicate: Gt("ID", 7),
+			Sort:  []int{4},
+			After: []interface{}{1},
 		})
-	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(2))
-	g.Expect(list[0].ID).To(gomega.Equal(8))
-	g.Expect(list[1].ID).To(gomega.Equal(9))
-	// By label.
-	list = []TestObject{}
+	g.Expect(errors.Is(err, AfterTokenErr)).To(gomega.BeTrue())
 	err = DB.List(
 		&list,
 		ListOptions{
-			Sort: []int{2},
-			Predicate: Or(
-				Match(Labels{"id": "v4"}),
-				Eq("ID", 8)),
+			Sort:  []int{40},
+			After: []interface{}{1},
 		})
+	g.Expect(errors.Is(err, SortErr)).To(gomega.BeTrue())
+}
+
+func TestListDetail(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestObject{})
+	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(len(list)).To(gomega.Equal(2))
-	g.Expect(list[0].ID).To(gomega.Equal(4))
-	g.Expect(list[1].ID).To(gomega.Equal(8))
-	// Test count all.
-	count, err := DB.Count(&TestObject{}, nil)
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(
+			&TestObject{
+				ID:     i,
+				labels: Labels{"id": fmt.Sprintf("%d", i)},
+			})
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Default.
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(count).To(gomega.Equal(int64(10)))
-	// Test count with predicate.
-	count, err = DB.Count(&TestObject{}, Gt("ID", 0))
+	g.Expect(len(list)).To(gomega.Equal(5))
+	for _, m := range list {
+		g.Expect(m.labels).To(gomega.BeNil())
+	}
+	// None.
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Detail: DetailNone})
+	g.Expect(err).To(gomega.BeNil())
+	for _, m := range list {
+		g.Expect(m.labels).To(gomega.BeNil())
+	}
+	// Labels.
+	for _, detail := range []Detail{DetailLabels, DetailAll} {
+		list = []TestObject{}
+		err = DB.List(&list, ListOptions{Detail: detail})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(len(list)).To(gomega.Equal(5))
+		for _, m := range list {
+			g.Expect(m.labels).To(
+				gomega.Equal(Labels{"id": fmt.Sprintf("%d", m.ID)}))
+		}
+	}
+	// View.
+	err = DB.View(func(v *View) error {
+		list = []TestObject{}
+		return v.List(&list, ListOptions{Detail: DetailLabels})
+	})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(count).To(gomega.Equal(int64(9)))
+	for _, m := range list {
+		g.Expect(m.labels).To(
+			gomega.Equal(Labels{"id": fmt.Sprintf("%d", m.ID)}))
+	}
 }
 
-func TestWatch(t *testing.T) {
+type TestTxHandler struct {
+	TestHandler
+	boundary []TxBoundary
+	// len(created) at each boundary.
+	marks []int
+}
+
+func (w *TestTxHandler) TxBoundary(b TxBoundary) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.boundary = append(w.boundary, b)
+	w.marks = append(w.marks, len(w.created))
+}
+
+func TestTxBoundary(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	DB := New(
 		"/tmp/test.db",
@@ -315,203 +7060,313 @@ func TestWatch(t *testing.T) {
 		&TestObject{})
 	err := DB.Open(true)
 	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
 	DB.Journal().Enable()
-	// Handler A
-	handlerA := &TestHandler{name: "A"}
-	watchA, err := DB.Watch(&TestObject{}, handlerA)
+	handler := &TestTxHandler{}
+	_, err = DB.Watch(&TestObject{}, handler)
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(watchA).ToNot(gomega.BeNil())
-	N := 10
-	// Insert
-	for i := 0; i < N; i++ {
-		object := &TestObject{
-			ID:   i,
-			Name: "Elmer",
-		}
-		err = DB.Insert(object)
-		g.Expect(err).To(gomega.BeNil())
-	}
-	// Handler B
-	handlerB := &TestHandler{name: "B"}
-	watchB, err := DB.Watch(&TestObject{}, handlerB)
+	plain := &TestHandler{}
+	_, err = DB.Watch(&TestObject{}, plain)
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(watchB).ToNot(gomega.BeNil())
-	// Update
-	for i := 0; i < N; i++ {
-		object := &TestObject{
-			ID:   i,
-			Name: "Fudd",
-		}
-		err = DB.Update(object)
-		g.Expect(err).To(gomega.BeNil())
+	// Concurrent transactions.
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 2)
+	for _, base := range []int{0, 100} {
+		wg.Add(1)
+		go func(base int) {
+			defer wg.Done()
+			tx, err := DB.Begin()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer tx.End()
+			for i := base; i < base+10; i++ {
+				err = DB.Insert(&TestObject{ID: i})
+				if err != nil {
+					errs <- err
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+			errs <- tx.Commit()
+		}(base)
 	}
-	// Handler C
-	handlerC := &TestHandler{name: "C"}
-	watchC, err := DB.Watch(&TestObject{}, handlerC)
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		g.Expect(<-errs).To(gomega.BeNil())
+	}
+	// Implicit transaction.
+	err = DB.Insert(&TestObject{ID: 200})
 	g.Expect(err).To(gomega.BeNil())
-	g.Expect(watchC).ToNot(gomega.BeNil())
-	// Delete
-	for i := 0; i < N; i++ {
-		object := &TestObject{
-			ID: i,
-		}
-		err = DB.Delete(object)
-		g.Expect(err).To(gomega.BeNil())
+	received := func() bool {
+		handler.mutex.Lock()
+		defer handler.mutex.Unlock()
+		plain.mutex.Lock()
+		defer plain.mutex.Unlock()
+		return len(handler.boundary) == 3 && len(plain.created) == 21
 	}
-	for i := 0; i < N; i++ {
-		time.Sleep(time.Millisecond * 10)
-		if len(handlerA.created) != N ||
-			len(handlerA.updated) != N ||
-			len(handlerA.created) != N ||
-			len(handlerB.created) != N ||
-			len(handlerB.updated) != N ||
-			len(handlerB.created) != N ||
-			len(handlerC.created) != N ||
-			len(handlerC.created) != N {
-			continue
-		} else {
+	for i := 0; i < 100; i++ {
+		if received() {
 			break
 		}
+		time.Sleep(time.Millisecond * 10)
 	}
-}
-
-//
-// Remove leading __ to enable.
-func __TestConcurrency(t *testing.T) {
-	var err error
-
-	DB := New("/tmp/test.db", &TestObject{})
-	DB.Open(true)
-
-	N := 1000
-
-	direct := func(done chan int) {
-		for i := 0; i < N; i++ {
-			m := &TestObject{
-				ID:   i,
-				Name: "direct",
-			}
-			err := DB.Insert(m)
-			if err != nil {
-				panic(err)
-			}
-			fmt.Printf("direct|%d\n", i)
-			time.Sleep(time.Millisecond * 10)
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	plain.mutex.Lock()
+	defer plain.mutex.Unlock()
+	g.Expect(len(handler.boundary)).To(gomega.Equal(3))
+	g.Expect(handler.marks).To(gomega.Equal([]int{10, 20, 21}))
+	g.Expect(handler.boundary[0].Count).To(gomega.Equal(10))
+	g.Expect(handler.boundary[1].Count).To(gomega.Equal(10))
+	g.Expect(handler.boundary[2].Count).To(gomega.Equal(1))
+	g.Expect(handler.boundary[2].Seq).To(gomega.Equal(uint64(21)))
+	for _, tx := range [][]int{
+		handler.created[0:10],
+		handler.created[10:20],
+	} {
+		base := tx[0] - tx[0]%100
+		for i, id := range tx {
+			g.Expect(id).To(gomega.Equal(base + i))
 		}
-		done <- 0
 	}
-	read := func(done chan int) {
-		time.Sleep(time.Second)
-		for i := 0; i < N; i++ {
-			m := &TestObject{
-				ID:   i,
-				Name: "direct",
-			}
-			go func() {
-				err := DB.Get(m)
-				if err != nil {
-					if errors.Is(err, NotFound) {
-						fmt.Printf("read|%d _____%s\n", i, err)
-					} else {
-						panic(err)
-					}
-				}
-				fmt.Printf("read|%d\n", i)
-			}()
-			time.Sleep(time.Millisecond * time.Duration(100))
-		}
-		done <- 0
+	g.Expect(handler.created[0]).ToNot(gomega.Equal(handler.created[10]))
+	g.Expect(handler.created[20]).To(gomega.Equal(200))
+	g.Expect(plain.created).To(gomega.Equal(handler.created))
+}
+
+func TestNullable(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := New(
+		"/tmp/test.db",
+		&Label{},
+		&TestOptional{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	// NULL.
+	err = DB.Insert(&TestOptional{PK: "null"})
+	g.Expect(err).To(gomega.BeNil())
+	m := &TestOptional{
+		PK:    "null",
+		Note:  sql.NullString{String: "x", Valid: true},
+		Rank:  Optional[int]{V: 1, Valid: true},
+		Alias: Optional[string]{V: "x", Valid: true},
 	}
-	del := func(done chan int) {
-		time.Sleep(time.Second * 3)
-		for i := 0; i < N/2; i++ {
-			m := &TestObject{
-				ID: i,
-			}
-			go func() {
-				err := DB.Delete(m)
-				if err != nil {
-					if errors.Is(err, NotFound) {
-						fmt.Printf("del|%d _____%s\n", i, err)
-					} else {
-						panic(err)
-					}
-				}
-				fmt.Printf("del|%d\n", i)
-			}()
-			time.Sleep(time.Millisecond * time.Duration(300))
-		}
-		done <- 0
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Note).To(gomega.Equal(sql.NullString{}))
+	g.Expect(m.Count).To(gomega.Equal(sql.NullInt64{}))
+	g.Expect(m.Rank).To(gomega.Equal(Optional[int]{}))
+	g.Expect(m.Flag).To(gomega.Equal(Optional[bool]{}))
+	g.Expect(m.Alias).To(gomega.Equal(Optional[string]{}))
+	n := 0
+	row := DB.(*Client).db.QueryRow(
+		"SELECT COUNT(*) FROM TestOptional WHERE Note IS NULL AND Rank IS NULL")
+	err = row.Scan(&n)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(n).To(gomega.Equal(1))
+	// Zero (valid).
+	err = DB.Insert(
+		&TestOptional{
+			PK:    "zero",
+			Note:  sql.NullString{String: "", Valid: true},
+			Count: sql.NullInt64{Int64: 0, Valid: true},
+			Rank:  Optional[int]{V: 0, Valid: true},
+			Flag:  Optional[bool]{V: false, Valid: true},
+			Alias: Optional[string]{V: "", Valid: true},
+		})
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestOptional{PK: "zero"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Note).To(gomega.Equal(sql.NullString{String: "", Valid: true}))
+	g.Expect(m.Count).To(gomega.Equal(sql.NullInt64{Int64: 0, Valid: true}))
+	g.Expect(m.Rank).To(gomega.Equal(Optional[int]{V: 0, Valid: true}))
+	g.Expect(m.Flag).To(gomega.Equal(Optional[bool]{V: false, Valid: true}))
+	g.Expect(m.Alias).To(gomega.Equal(Optional[string]{V: "", Valid: true}))
+	// Update to and from NULL.
+	m.Note = sql.NullString{}
+	m.Rank = Optional[int]{V: 7, Valid: true}
+	m.Flag = Optional[bool]{V: true, Valid: true}
+	err = DB.Update(m)
+	g.Expect(err).To(gomega.BeNil())
+	m = &TestOptional{PK: "zero"}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Note.Valid).To(gomega.BeFalse())
+	g.Expect(m.Rank).To(gomega.Equal(Optional[int]{V: 7, Valid: true}))
+	g.Expect(m.Flag).To(gomega.Equal(Optional[bool]{V: true, Valid: true}))
+	// List.
+	list := []TestOptional{}
+	err = DB.List(&list, ListOptions{Predicate: Eq("Rank", 7)})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	g.Expect(list[0].PK).To(gomega.Equal("zero"))
+	g.Expect(list[0].Alias).To(gomega.Equal(Optional[string]{V: "", Valid: true}))
+	// Scanner.
+	opt := Optional[int]{}
+	err = DB.(*Client).db.QueryRow("SELECT 4").Scan(&opt)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(opt).To(gomega.Equal(Optional[int]{V: 4, Valid: true}))
+	err = DB.(*Client).db.QueryRow("SELECT NULL").Scan(&opt)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(opt).To(gomega.Equal(Optional[int]{}))
+	phase := Optional[TestPhase]{}
+	err = DB.(*Client).db.QueryRow("SELECT 2").Scan(&phase)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(phase).To(gomega.Equal(Optional[TestPhase]{V: 2, Valid: true}))
+	small := Optional[int8]{}
+	err = DB.(*Client).db.QueryRow("SELECT 300").Scan(&small)
+	g.Expect(errors.Is(err, OverflowErr)).To(gomega.BeTrue())
+	alias := Optional[string]{}
+	err = DB.(*Client).db.QueryRow("SELECT 'x'").Scan(&alias)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(alias).To(gomega.Equal(Optional[string]{V: "x", Valid: true}))
+	// Valuer.
+	v, err := Optional[TestPhase]{V: 2, Valid: true}.Value()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(v).To(gomega.Equal(int64(2)))
+	v, err = Optional[bool]{}.Value()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(v).To(gomega.BeNil())
+}
+
+func TestCache(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	DB := &Client{
+		path:      "/tmp/test.db",
+		CacheSize: 100,
 	}
-	update := func(done chan int) {
-		for i := 0; i < N; i++ {
-			m := &TestObject{
-				ID:   i,
-				Name: "direct",
-			}
-			go func() {
-				err := DB.Update(m)
-				if err != nil {
-					if errors.Is(err, NotFound) {
-						fmt.Printf("update|%d _____%s\n", i, err)
-					} else {
-						panic(err)
-					}
-				}
-				fmt.Printf("update|%d\n", i)
-			}()
-			time.Sleep(time.Millisecond * time.Duration(20))
-		}
-		done <- 0
+	DB.Register(
+		&TestObject{},
+		&TestRelated{},
+		&TestNillable{})
+	err := DB.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	defer DB.Close(true)
+	for i := 0; i < 5; i++ {
+		err = DB.Insert(&TestObject{ID: i, Name: "A"})
+		g.Expect(err).To(gomega.BeNil())
 	}
-	transaction := func(done chan int) {
-		var tx *Tx
-		threshold := float64(10)
-		for i := N; i < N*2; i++ {
-			if i == N || math.Mod(float64(i), threshold) == 0 {
-				tx, err = DB.Begin()
-				if err != nil {
-					panic(err)
-				}
-			}
-			m := &TestObject{
-				ID:   i,
-				Name: "transaction",
-			}
-			DB.Insert(m)
-			if err != nil {
-				panic(err)
-			}
-			//time.Sleep(time.Second*3)
-			if math.Mod(float64(i), threshold) == 0 {
-				err = tx.Commit()
-				if err != nil {
-					panic(err)
-				}
-				fmt.Printf("commit|%d\n", i)
-			}
-			fmt.Printf("transaction|%d\n", i)
-			time.Sleep(time.Millisecond * time.Duration(100))
+	names := func() []string {
+		list := []TestObject{}
+		err := DB.List(&list, ListOptions{Sort: []int{2}})
+		g.Expect(err).To(gomega.BeNil())
+		names := []string{}
+		for _, m := range list {
+			names = append(names, m.Name)
 		}
-		done <- 0
+		return names
 	}
-
-	mark := time.Now()
-
-	done := make(chan int)
-	fnList := []func(chan int){
-		direct,
-		transaction,
-		read,
-		update,
-		del,
+	// Changed behind the cache (not journaled).
+	backdoor := func(name string) {
+		_, err := DB.db.Exec("UPDATE TestObject SET Name = ?", name)
+		g.Expect(err).To(gomega.BeNil())
 	}
-	for _, fn := range fnList {
-		go fn(done)
+	g.Expect(names()).To(gomega.Equal([]string{"A", "A", "A", "A", "A"}))
+	backdoor("B")
+	g.Expect(names()).To(gomega.Equal([]string{"A", "A", "A", "A", "A"}))
+	// Get.
+	m := &TestObject{ID: 0}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("B"))
+	backdoor("C")
+	m = &TestObject{ID: 0}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("B"))
+	// Returned (copy) changed by the caller.
+	m.Name = "X"
+	m = &TestObject{ID: 0}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("B"))
+	list := []TestObject{}
+	err = DB.List(&list, ListOptions{Sort: []int{2}})
+	g.Expect(err).To(gomega.BeNil())
+	list[0].Name = "X"
+	g.Expect(names()[0]).To(gomega.Equal("A"))
+	// Change to another kind.
+	err = DB.Insert(&TestRelated{ID: 0})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(names()[0]).To(gomega.Equal("A"))
+	// Change to the kind.
+	err = DB.Insert(&TestObject{ID: 5, Name: "C"})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(names()).To(gomega.Equal([]string{"C", "C", "C", "C", "C", "C"}))
+	m = &TestObject{ID: 0}
+	err = DB.Get(m)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal("C"))
+	// Exec.
+	_, err = DB.Exec("UPDATE TestObject SET Name = 'D'")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(names()[0]).To(gomega.Equal("D"))
+	// Within a transaction.
+	tx, err := DB.Begin()
+	g.Expect(err).To(gomega.BeNil())
+	_, err = DB.Exec("UPDATE TestObject SET Name = 'E'")
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(names()[0]).To(gomega.Equal("D"))
+	err = tx.Commit()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(names()[0]).To(gomega.Equal("E"))
+	// Subquery (not cached).
+	predicate := Subquery("ID", &TestRelated{}, "ID", nil)
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: predicate})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(1))
+	err = DB.Insert(&TestRelated{ID: 1})
+	g.Expect(err).To(gomega.BeNil())
+	list = []TestObject{}
+	err = DB.List(&list, ListOptions{Predicate: predicate})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(2))
+	// Stats.
+	stats, err := DB.Stats()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stats.CacheHits).To(gomega.Equal(int64(7)))
+	g.Expect(stats.CacheMisses).To(gomega.Equal(int64(6)))
+	// Copied (deep).
+	nick := "Elmer"
+	err = DB.Insert(
+		&TestNillable{
+			PK:   "0",
+			Rank: Optional[int]{Valid: true},
+			Nick: &nick,
+		})
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 3; i++ {
+		nillable := &TestNillable{PK: "0"}
+		err = DB.Get(nillable)
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(*nillable.Nick).To(gomega.Equal("Elmer"))
+		*nillable.Nick = "X"
 	}
-	for range fnList {
-		<-done
+	g.Expect(nick).To(gomega.Equal("Elmer"))
+	// Labels (not cached).
+	err = DB.Insert(&TestObject{ID: 6, labels: Labels{"n1": "v1"}})
+	g.Expect(err).To(gomega.BeNil())
+	stats, err = DB.Stats()
+	g.Expect(err).To(gomega.BeNil())
+	hits := stats.CacheHits
+	for i := 0; i < 3; i++ {
+		list = []TestObject{}
+		err = DB.List(
+			&list,
+			ListOptions{
+				Predicate: Eq("ID", 6),
+				Detail:    DetailLabels,
+			})
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(list[0].labels).To(gomega.Equal(Labels{"n1": "v1"}))
+		list[0].labels["n1"] = "X"
 	}
-
-	fmt.Println(time.Since(mark))
+	stats, err = DB.Stats()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(stats.CacheHits).To(gomega.Equal(hits))
 }
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+//
+// Naming strategy.
+// Maps model (type) and field names to table and
+// column names. The identity strategy is used when nil.
+// The strategy must not change for the life of a DB.
+type Naming func(name string) string
+
+//
+// Lower case naming strategy.
+// Example: TestObject => testobject.
+func LowerCase(name string) string {
+	return strings.ToLower(name)
+}
+
+//
+// Snake case naming strategy.
+// Example: TestObject => test_object, VMName => vm_name.
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	bfr := strings.Builder{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) ||
+				unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && next) {
+				bfr.WriteRune('_')
+			}
+		}
+		bfr.WriteRune(unicode.ToLower(r))
+	}
+
+	return bfr.String()
+}
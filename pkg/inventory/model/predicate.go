@@ -4,6 +4,7 @@ import (
 	"bytes"
 	liberr "github.com/konveyor/controller/pkg/error"
 	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 )
@@ -12,7 +13,11 @@ import (
 // Label SQL.
 var LabelSQL = `
 {{ $kind := .Kind -}}
-{{ if .Len }}
+{{ range $i,$c := .Columns -}}
+{{ if $i }} AND {{ end }}{{ $c.Name }} = {{ $c.Value }}
+{{ end -}}
+{{ if .List }}
+{{- if .Columns }} AND {{ end -}}
 {{ .Pk.Name }} IN
 (
 {{ range $i,$l := .List -}}
@@ -29,6 +34,94 @@ value = {{ $l.Value }}
 {{ end -}}
 `
 
+//
+// Label (negated) SQL.
+var LabelNotSQL = `
+NOT EXISTS
+(
+SELECT 1
+FROM Label
+WHERE kind = '{{ .Kind }}' AND
+parent = {{ .Table }}.{{ .Pk.Name }} AND
+name = {{ .Name }}
+{{- if .In }} AND
+value IN (
+{{- range $i,$v := .Values -}}
+{{ if $i }},{{ end }}{{ $v }}
+{{- end -}}
+)
+{{- end }}
+)
+`
+
+//
+// Label (integer) comparison SQL.
+var LabelCmpSQL = `
+{{ .Pk.Name }} IN
+(
+SELECT parent
+FROM Label
+WHERE kind = '{{ .Kind }}' AND
+name = {{ .Name }} AND
+type = '{{ .Type }}' AND
+CAST(value AS INTEGER) {{ .Operator }} {{ .Value }}
+)
+`
+
+//
+// Label (any) SQL.
+var LabelAnySQL = `
+{{ .Pk.Name }} IN
+(
+SELECT parent
+FROM Label
+WHERE kind = '{{ .Kind }}' AND
+(
+{{ range $i,$p := .Pairs -}}
+{{ if $i }}OR {{ end -}}
+(name = {{ $p.Name }} AND value IN (
+{{- range $j,$v := $p.Values -}}
+{{ if $j }},{{ end }}{{ $v }}
+{{- end -}}
+))
+{{ end -}}
+)
+)
+`
+
+//
+// Label count (at least) SQL.
+var LabelAtLeastSQL = `
+(
+SELECT COUNT(DISTINCT name)
+FROM Label
+WHERE kind = '{{ .Kind }}' AND
+parent = {{ .Table }}.{{ .Pk.Name }} AND
+name IN (
+{{- range $i,$n := .Names -}}
+{{ if $i }},{{ end }}{{ $n }}
+{{- end -}}
+)
+) >= {{ .Count }}
+`
+
+//
+// JSON array (contains) SQL.
+// Values of fields not storing a valid JSON array
+// are treated as an empty array.
+var JsonArraySQL = `
+EXISTS
+(
+SELECT 1
+FROM json_each(
+CASE WHEN json_valid({{ .Column }}) AND json_type({{ .Column }}) = 'array'
+THEN {{ .Column }}
+ELSE '[]'
+END)
+WHERE value = {{ .Value }}
+)
+`
+
 //
 // New Eq (=) predicate.
 func Eq(field string, value interface{}) *EqPredicate {
@@ -73,6 +166,20 @@ func Lt(field string, value interface{}) *LtPredicate {
 	}
 }
 
+//
+// New In predicate.
+// The `values` must be a slice.
+// Example:
+//   In("Phase", []Phase{Pending, Running})
+func In(field string, values interface{}) *InPredicate {
+	return &InPredicate{
+		SimplePredicate{
+			Field: field,
+			Value: values,
+		},
+	}
+}
+
 //
 // AND predicate.
 func And(predicates ...Predicate) *AndPredicate {
@@ -101,6 +208,144 @@ func Match(labels Labels) *LabelPredicate {
 	}
 }
 
+//
+// Label greater than (>) predicate.
+// Matches integer labels only.
+func LabelGt(name string, value int64) *LabelCmpPredicate {
+	return &LabelCmpPredicate{
+		Name:     name,
+		Value:    value,
+		operator: ">",
+	}
+}
+
+//
+// Label less than (<) predicate.
+// Matches integer labels only.
+func LabelLt(name string, value int64) *LabelCmpPredicate {
+	return &LabelCmpPredicate{
+		Name:     name,
+		Value:    value,
+		operator: "<",
+	}
+}
+
+//
+// Subquery predicate.
+// Matches when the `field` value is IN the `selectField`
+// values of the `model` selected by the predicate.
+// The predicate may be nil.
+func Subquery(field string, model Model, selectField string, predicate Predicate) *SubqueryPredicate {
+	return &SubqueryPredicate{
+		Field:       field,
+		Model:       model,
+		SelectField: selectField,
+		Predicate:   predicate,
+	}
+}
+
+//
+// Label does not exist predicate.
+// Matches models without the named label.
+func NotHasLabel(name string) *LabelNotPredicate {
+	return &LabelNotPredicate{
+		Name: name,
+	}
+}
+
+//
+// Label value not in predicate.
+// Matches models without the named label or with
+// a label value not in the list of values.
+func LabelNotIn(name string, values ...string) *LabelNotPredicate {
+	return &LabelNotPredicate{
+		Name:   name,
+		Values: values,
+		in:     true,
+	}
+}
+
+//
+// Label (any) predicate.
+// Matches models with at least one of the labels having
+// any of the listed values. Example:
+//   AnyLabel(map[string][]string{
+//       "color": {"red", "blue"},
+//       "size":  {"large"},
+//   })
+func AnyLabel(pairs map[string][]string) *LabelAnyPredicate {
+	return &LabelAnyPredicate{
+		Pairs: pairs,
+	}
+}
+
+//
+// Label count predicate.
+// Matches models with at least `n` of the named labels
+// (any value).
+func LabelCountAtLeast(names []string, n int) *LabelCountPredicate {
+	return &LabelCountPredicate{
+		Names: names,
+		Count: n,
+	}
+}
+
+//
+// JSON array (contains) predicate.
+// Matches models with the (str) field storing a JSON
+// array containing the value. The value is compared
+// with each (scalar) element. Requires the sqlite JSON1
+// extension (go build tag: sqlite_json). Example:
+//   JsonArrayContains("Tags", "prod")
+func JsonArrayContains(field string, value interface{}) *JsonArrayPredicate {
+	return &JsonArrayPredicate{
+		SimplePredicate{
+			Field: field,
+			Value: value,
+		},
+	}
+}
+
+//
+// Walk the predicate tree.
+// The `fn` is called for each predicate (node) depth-first
+// with children visited before the parent. The predicate
+// returned by `fn` replaces the node. Returning nil removes
+// the node. Compound (And/Or) predicates are rebuilt with
+// the replaced children and the tree passed is not modified.
+// Subquery predicates are not descended because they
+// reference another model.
+// Example (rewrite):
+//   p = Walk(p, func(p Predicate) Predicate {
+//       if eq, cast := p.(*EqPredicate); cast && eq.Field == "Name" {
+//           return Eq("Alias", eq.Value)
+//       }
+//       return p
+//   })
+func Walk(predicate Predicate, fn func(Predicate) Predicate) Predicate {
+	if predicate == nil {
+		return nil
+	}
+	walk := func(list []Predicate) []Predicate {
+		walked := []Predicate{}
+		for _, p := range list {
+			p = Walk(p, fn)
+			if p != nil {
+				walked = append(walked, p)
+			}
+		}
+		return walked
+	}
+	switch p := predicate.(type) {
+	case *AndPredicate:
+		predicate = And(walk(p.Predicates)...)
+	case *OrPredicate:
+		predicate = Or(walk(p.Predicates)...)
+	}
+
+	return fn(predicate)
+}
+
 //
 // List predicate.
 type Predicate interface {
@@ -125,7 +370,7 @@ type SimplePredicate struct {
 // Find referenced field.
 func (p *SimplePredicate) match(fields []*Field) (*Field, bool) {
 	for _, f := range fields {
-		if f.Name == p.Field {
+		if f.Match(p.Field) {
 			return f, true
 		}
 	}
@@ -133,6 +378,47 @@ func (p *SimplePredicate) match(fields []*Field) (*Field, bool) {
 	return nil, false
 }
 
+//
+// Render the (right) operand.
+// A FieldRef value renders the referenced column. Else, the
+// value is converted and bound as a parameter.
+func (p *SimplePredicate) operand(f *Field, options *ListOptions) (string, error) {
+	if ref, cast := p.Value.(FieldRef); cast {
+		for _, other := range options.fields {
+			if !other.Match(ref.Name) {
+				continue
+			}
+			if other.Type() != f.Type() {
+				return "", liberr.Wrap(PredicateTypeErr)
+			}
+			return other.Column(), nil
+		}
+		return "", liberr.Wrap(PredicateRefErr)
+	}
+	v, err := f.AsValue(p.Value)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+
+	return options.Param(f.Name, v), nil
+}
+
+//
+// Field (column) reference.
+// Used as a predicate value to compare two fields.
+type FieldRef struct {
+	// Field name.
+	Name string
+}
+
+//
+// New field reference.
+// Example:
+//   Lt("Observed", Ref("Desired"))
+func Ref(name string) FieldRef {
+	return FieldRef{Name: name}
+}
+
 //
 // Equals (=) predicate.
 type EqPredicate struct {
@@ -146,11 +432,11 @@ func (p *EqPredicate) Build(options *ListOptions) error {
 	if !found {
 		return liberr.Wrap(PredicateRefErr)
 	}
-	v, err := f.AsValue(p.Value)
+	v, err := p.operand(f, options)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	p.expr = f.Name + " = " + options.Param(f.Name, v)
+	p.expr = f.Column() + " = " + v
 	return nil
 }
 
@@ -173,11 +459,11 @@ func (p *NeqPredicate) Build(options *ListOptions) error {
 	if !found {
 		return liberr.Wrap(PredicateRefErr)
 	}
-	v, err := f.AsValue(p.Value)
+	v, err := p.operand(f, options)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	p.expr = f.Name + " != " + options.Param(f.Name, v)
+	p.expr = f.Column() + " != " + v
 	return nil
 }
 
@@ -209,11 +495,11 @@ func (p *GtPredicate) Build(options *ListOptions) error {
 		reflect.Int16,
 		reflect.Int32,
 		reflect.Int64:
-		v, err := f.AsValue(p.Value)
+		v, err := p.operand(f, options)
 		if err != nil {
 			return liberr.Wrap(err)
 		}
-		p.expr = f.Name + " > " + options.Param(f.Name, v)
+		p.expr = f.Column() + " > " + v
 		return nil
 	default:
 		return FieldTypeErr
@@ -248,11 +534,11 @@ func (p *LtPredicate) Build(options *ListOptions) error {
 		reflect.Int16,
 		reflect.Int32,
 		reflect.Int64:
-		v, err := f.AsValue(p.Value)
+		v, err := p.operand(f, options)
 		if err != nil {
 			return liberr.Wrap(err)
 		}
-		p.expr = f.Name + " < " + options.Param(f.Name, v)
+		p.expr = f.Column() + " < " + v
 		return nil
 	default:
 		return FieldTypeErr
@@ -265,6 +551,108 @@ func (p *LtPredicate) Expr() string {
 	return p.expr
 }
 
+//
+// In predicate.
+type InPredicate struct {
+	SimplePredicate
+}
+
+//
+// Build.
+// An empty list matches nothing.
+func (p *InPredicate) Build(options *ListOptions) error {
+	f, found := p.match(options.fields)
+	if !found {
+		return liberr.Wrap(PredicateRefErr)
+	}
+	list := reflect.ValueOf(p.Value)
+	if list.Kind() != reflect.Slice {
+		return liberr.Wrap(PredicateValueErr)
+	}
+	if list.Len() == 0 {
+		p.expr = "0"
+		return nil
+	}
+	params := []string{}
+	for i := 0; i < list.Len(); i++ {
+		v, err := f.AsValue(list.Index(i).Interface())
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		params = append(params, options.Param(f.Name, v))
+	}
+	p.expr = f.Column() + " IN (" + strings.Join(params, ",") + ")"
+	return nil
+}
+
+//
+// Render the expression.
+func (p *InPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// JSON array (contains) predicate.
+type JsonArrayPredicate struct {
+	SimplePredicate
+}
+
+//
+// Build.
+func (p *JsonArrayPredicate) Build(options *ListOptions) error {
+	f, found := p.match(options.fields)
+	if !found {
+		return liberr.Wrap(PredicateRefErr)
+	}
+	if f.Value.Kind() != reflect.String {
+		return liberr.Wrap(PredicateTypeErr)
+	}
+	var value interface{}
+	v := reflect.ValueOf(p.Value)
+	switch v.Kind() {
+	case reflect.String:
+		value = v.String()
+	case reflect.Bool:
+		value = v.Bool()
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		value = v.Int()
+	default:
+		return liberr.Wrap(PredicateValueErr)
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(JsonArraySQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Column string
+			Value  string
+		}{
+			Column: f.Column(),
+			Value:  options.Param(f.Name, value),
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	p.expr = bfr.String()
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *JsonArrayPredicate) Expr() string {
+	return p.expr
+}
+
 //
 // Compound predicate.
 type CompoundPredicate struct {
@@ -293,10 +681,19 @@ func (p *AndPredicate) Build(options *ListOptions) error {
 
 //
 // Render the expression.
+// Nested OR predicates are parenthesized. Matches all
+// models when empty.
 func (p *AndPredicate) Expr() string {
+	if len(p.Predicates) == 0 {
+		return "1"
+	}
 	predicates := []string{}
 	for _, p := range p.Predicates {
-		predicates = append(predicates, p.Expr())
+		expr := p.Expr()
+		if or, cast := p.(*OrPredicate); cast && len(or.Predicates) > 1 {
+			expr = "(" + expr + ")"
+		}
+		predicates = append(predicates, expr)
 	}
 
 	expr := strings.Join(predicates, " AND ")
@@ -325,7 +722,11 @@ func (p *OrPredicate) Build(options *ListOptions) error {
 
 //
 // Render the expression.
+// Matches no models when empty.
 func (p *OrPredicate) Expr() string {
+	if len(p.Predicates) == 0 {
+		return "0"
+	}
 	predicates := []string{}
 	for _, p := range p.Predicates {
 		predicates = append(predicates, p.Expr())
@@ -345,6 +746,10 @@ type LabelPredicate struct {
 	options *ListOptions
 	// Parent PK field name.
 	pk *Field
+	// Labels matched using the Label table.
+	list []Label
+	// Labels matched using promoted columns.
+	columns []Label
 	// SQL expression.
 	expr string
 }
@@ -359,6 +764,35 @@ func (p *LabelPredicate) Build(options *ListOptions) error {
 			break
 		}
 	}
+	promoted := map[string]bool{}
+	for _, name := range options.promoted[options.table] {
+		promoted[name] = true
+	}
+	p.list = []Label{}
+	p.columns = []Label{}
+	names := []string{}
+	for k := range p.Labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := p.Labels[k]
+		if promoted[k] {
+			p.columns = append(
+				p.columns,
+				Label{
+					Name:  PromotedColumn(k),
+					Value: options.Param("v", v),
+				})
+			continue
+		}
+		p.list = append(
+			p.list,
+			Label{
+				Name:  options.Param("k", k),
+				Value: options.Param("v", v),
+			})
+	}
 	tpl := template.New("")
 	tpl, err := tpl.Parse(LabelSQL)
 	if err != nil {
@@ -378,7 +812,7 @@ func (p *LabelPredicate) Build(options *ListOptions) error {
 //
 // Label (parent) kind.
 func (p *LabelPredicate) Kind() string {
-	return p.options.table
+	return p.options.kind()
 }
 
 //
@@ -388,21 +822,15 @@ func (p *LabelPredicate) Pk() *Field {
 }
 
 //
-// List of labels.
+// List of labels matched using the Label table.
 func (p *LabelPredicate) List() []Label {
-	list := []Label{}
-	for k, v := range p.Labels {
-		k = p.options.Param("k", k)
-		v = p.options.Param("v", v)
-		list = append(
-			list,
-			Label{
-				Name:  k,
-				Value: v,
-			})
-	}
+	return p.list
+}
 
-	return list
+//
+// List of labels matched using promoted columns.
+func (p *LabelPredicate) Columns() []Label {
+	return p.columns
 }
 
 //
@@ -416,3 +844,385 @@ func (p *LabelPredicate) Len() int {
 func (p *LabelPredicate) Expr() string {
 	return p.expr
 }
+
+//
+// Label (integer) comparison predicate.
+type LabelCmpPredicate struct {
+	// Label name.
+	Name string
+	// Label value.
+	Value int64
+	// Comparison operator.
+	operator string
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+func (p *LabelCmpPredicate) Build(options *ListOptions) error {
+	var pk *Field
+	for _, f := range options.fields {
+		if f.Pk() {
+			pk = f
+			break
+		}
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelCmpSQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Pk       *Field
+			Kind     string
+			Type     string
+			Name     string
+			Value    string
+			Operator string
+		}{
+			Pk:       pk,
+			Kind:     options.kind(),
+			Type:     LabelInt,
+			Name:     options.Param("k", p.Name),
+			Value:    options.Param("v", p.Value),
+			Operator: p.operator,
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	p.expr = bfr.String()
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *LabelCmpPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// Subquery predicate.
+type SubqueryPredicate struct {
+	// Field name.
+	Field string
+	// Selected model.
+	Model Model
+	// Selected field name.
+	SelectField string
+	// Selection predicate.
+	Predicate Predicate
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+func (p *SubqueryPredicate) Build(options *ListOptions) error {
+	var f, selected *Field
+	for _, field := range options.fields {
+		if field.Match(p.Field) {
+			f = field
+			break
+		}
+	}
+	if f == nil {
+		return liberr.Wrap(PredicateRefErr)
+	}
+	table := Table{
+		Naming:    options.naming,
+		Namespace: options.namespace,
+	}
+	fields, err := table.Fields(p.Model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	for _, field := range fields {
+		if field.Match(p.SelectField) {
+			selected = field
+			break
+		}
+	}
+	if selected == nil {
+		return liberr.Wrap(PredicateRefErr)
+	}
+	inner := &ListOptions{
+		Predicate: p.Predicate,
+		params:    options.params,
+		promoted:  options.promoted,
+		naming:    options.naming,
+		namespace: options.namespace,
+	}
+	err = inner.Build(table.Name(p.Model), fields)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	options.params = inner.params
+	expr := f.Column() + " IN (SELECT " + selected.Column() + " FROM " + inner.table
+	if p.Predicate != nil {
+		expr += " WHERE " + p.Predicate.Expr()
+	}
+
+	p.expr = expr + ")"
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *SubqueryPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// Label (negated) predicate.
+type LabelNotPredicate struct {
+	// Label name.
+	Name string
+	// Label values.
+	Values []string
+	// Match values.
+	in bool
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+func (p *LabelNotPredicate) Build(options *ListOptions) error {
+	var pk *Field
+	for _, f := range options.fields {
+		if f.Pk() {
+			pk = f
+			break
+		}
+	}
+	name := options.Param("k", p.Name)
+	values := []string{}
+	for _, v := range p.Values {
+		values = append(values, options.Param("v", v))
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelNotSQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Table  string
+			Pk     *Field
+			Kind   string
+			Name   string
+			Values []string
+			In     bool
+		}{
+			Table:  options.table,
+			Pk:     pk,
+			Kind:   options.kind(),
+			Name:   name,
+			Values: values,
+			In:     p.in,
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	p.expr = bfr.String()
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *LabelNotPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// Label (any) predicate.
+type LabelAnyPredicate struct {
+	// Map of: label name => values.
+	Pairs map[string][]string
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+// Matches nothing when no values are listed.
+func (p *LabelAnyPredicate) Build(options *ListOptions) error {
+	var pk *Field
+	for _, f := range options.fields {
+		if f.Pk() {
+			pk = f
+			break
+		}
+	}
+	type pair struct {
+		Name   string
+		Values []string
+	}
+	names := []string{}
+	for name, values := range p.Pairs {
+		if len(values) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		p.expr = "0"
+		return nil
+	}
+	sort.Strings(names)
+	pairs := []pair{}
+	for _, name := range names {
+		values := []string{}
+		for _, v := range p.Pairs[name] {
+			values = append(values, options.Param("v", v))
+		}
+		pairs = append(
+			pairs,
+			pair{
+				Name:   options.Param("k", name),
+				Values: values,
+			})
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelAnySQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Pk    *Field
+			Kind  string
+			Pairs []pair
+		}{
+			Pk:    pk,
+			Kind:  options.kind(),
+			Pairs: pairs,
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	p.expr = bfr.String()
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *LabelAnyPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// Label count predicate.
+type LabelCountPredicate struct {
+	// Label names.
+	Names []string
+	// Minimum number of labels.
+	Count int
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+func (p *LabelCountPredicate) Build(options *ListOptions) error {
+	var pk *Field
+	for _, f := range options.fields {
+		if f.Pk() {
+			pk = f
+			break
+		}
+	}
+	names := []string{}
+	for _, name := range p.Names {
+		names = append(names, options.Param("k", name))
+	}
+	tpl := template.New("")
+	tpl, err := tpl.Parse(LabelAtLeastSQL)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Table string
+			Pk    *Field
+			Kind  string
+			Names []string
+			Count string
+		}{
+			Table: options.table,
+			Pk:    pk,
+			Kind:  options.kind(),
+			Names: names,
+			Count: options.Param("n", p.Count),
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	p.expr = bfr.String()
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *LabelCountPredicate) Expr() string {
+	return p.expr
+}
+
+//
+// Keyset (after) predicate.
+// Matches models with a sort key greater than the values
+// using a row value comparison. Unlike Gt(), string keys
+// are supported. See: ListOptions.After.
+type afterPredicate struct {
+	// Sort key fields.
+	fields []*Field
+	// Sort key values.
+	values []interface{}
+	// SQL expression.
+	expr string
+}
+
+//
+// Build.
+func (p *afterPredicate) Build(options *ListOptions) error {
+	if len(p.values) != len(p.fields) {
+		return liberr.Wrap(AfterTokenErr)
+	}
+	columns := []string{}
+	params := []string{}
+	for i, f := range p.fields {
+		v, err := f.AsValue(p.values[i])
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		columns = append(columns, f.Column())
+		params = append(params, options.Param(f.Name, v))
+	}
+	p.expr = "(" + strings.Join(columns, ",") + ") > (" + strings.Join(params, ",") + ")"
+
+	return nil
+}
+
+//
+// Render the expression.
+func (p *afterPredicate) Expr() string {
+	return p.expr
+}
@@ -0,0 +1,466 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	liberr "github.com/konveyor/controller/pkg/error"
+	"sort"
+	"strings"
+)
+
+//
+// Table info SQL.
+var TableInfoSQL = `
+SELECT name, type
+FROM pragma_table_info(:table)
+;
+`
+
+//
+// Index list SQL.
+// Created (c) and unique constraint (u) indexes.
+var IndexListSQL = `
+SELECT name, origin
+FROM pragma_index_list(:table)
+WHERE origin IN ('c', 'u')
+;
+`
+
+//
+// Index info SQL.
+var IndexInfoSQL = `
+SELECT name
+FROM pragma_index_info(:index)
+ORDER BY seqno
+;
+`
+
+//
+// Foreign key list SQL.
+var ForeignKeyListSQL = `
+SELECT "table", "from", "to"
+FROM pragma_foreign_key_list(:table)
+;
+`
+
+//
+// Schema drift error.
+// The schema of the DB does not match the models.
+type SchemaDrift struct {
+	// Description of each mismatch.
+	Mismatches []string
+}
+
+//
+// Error description.
+func (e *SchemaDrift) Error() string {
+	return fmt.Sprintf(
+		"schema drift detected: %s",
+		strings.Join(e.Mismatches, "; "))
+}
+
+//
+// Get the mismatches between the model and
+// the (live) table schema in the DB.
+// The `columns` are additional (expected) columns that are
+// not model fields.
+func (t Table) Drift(model interface{}, columns ...string) ([]string, error) {
+	mismatches := []string{}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	table := t.Name(model)
+	found, err := t.Columns(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	if len(found) == 0 {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: table not found", table))
+		return mismatches, nil
+	}
+	names := map[string]string{}
+	for name := range found {
+		names[strings.ToLower(name)] = name
+	}
+	expected := map[string]bool{}
+	for _, name := range columns {
+		expected[strings.ToLower(name)] = true
+	}
+	for _, f := range t.StoredFields(fields) {
+		key := strings.ToLower(f.Name)
+		kind, exists := found[names[key]]
+		if !exists {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("%s: column %s not found", table, f.Name))
+			continue
+		}
+		delete(names, key)
+		if !strings.EqualFold(kind, f.Type()) {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf(
+					"%s: column %s type %s expected: %s",
+					table,
+					f.Name,
+					kind,
+					f.Type()))
+		}
+	}
+	for key, name := range names {
+		if expected[key] {
+			continue
+		}
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: column %s not expected", table, name))
+	}
+
+	return mismatches, nil
+}
+
+//
+// Get the mismatches between the model indexes and constraints
+// (unique, foreign key) and the (live) table schema in the DB.
+// The `columns` are additional (expected) indexed columns that
+// are not model fields.
+func (t Table) IndexDrift(model interface{}, columns ...string) ([]string, error) {
+	mismatches := []string{}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	fields = t.StoredFields(fields)
+	table := t.Name(model)
+	live, err := t.Columns(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	if len(live) == 0 {
+		// Reported by Drift().
+		return mismatches, nil
+	}
+	// Expected.
+	names := func(list []*Field) string {
+		names := []string{}
+		for _, f := range list {
+			names = append(names, f.Name)
+		}
+		return strings.ToLower(strings.Join(names, ","))
+	}
+	indexes := map[string]string{}
+	indexNames := map[string]string{}
+	expect := func(name, columns string) {
+		indexes[strings.ToLower(name)] = columns
+		indexNames[strings.ToLower(name)] = name
+	}
+	if keys := t.KeyFields(fields); len(keys) > 0 {
+		expect(table+"Index", names(keys))
+	}
+	declared := map[string][]*Field{}
+	for _, f := range fields {
+		for _, index := range f.Indexes() {
+			declared[index.Name] = append(declared[index.Name], f)
+		}
+	}
+	for name, list := range declared {
+		expect(table+"_"+name, names(list))
+	}
+	for _, column := range columns {
+		expect(table+"_"+column, strings.ToLower(column))
+	}
+	unique := map[string]bool{}
+	grouped := map[string][]*Field{}
+	for _, f := range fields {
+		for _, name := range f.Unique() {
+			grouped[name] = append(grouped[name], f)
+		}
+	}
+	for _, list := range grouped {
+		unique[names(list)] = true
+	}
+	fks := map[string]bool{}
+	for _, f := range fields {
+		fk := f.Fk()
+		if fk == nil {
+			continue
+		}
+		key := fmt.Sprintf(
+			"(%s) references %s (%s)",
+			f.Name,
+			t.ident(fk.Table),
+			t.ident(fk.Field))
+		fks[strings.ToLower(key)] = true
+	}
+	// Indexes and unique constraints.
+	found, err := t.indexList(table)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	for name, index := range found {
+		key := strings.ToLower(name)
+		if index.origin == "u" {
+			if unique[index.columns] {
+				delete(unique, index.columns)
+				continue
+			}
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("%s: unique (%s) not expected", table, index.columns))
+			continue
+		}
+		expected, exists := indexes[key]
+		if !exists {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("%s: index %s not expected", table, name))
+			continue
+		}
+		delete(indexes, key)
+		if index.columns != expected {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf(
+					"%s: index %s columns (%s) expected: (%s)",
+					table,
+					name,
+					index.columns,
+					expected))
+		}
+	}
+	for key := range indexes {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: index %s not found", table, indexNames[key]))
+	}
+	for columns := range unique {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: unique (%s) not found", table, columns))
+	}
+	// Foreign keys.
+	cursor, err := t.DB.Query(ForeignKeyListSQL, sql.Named("table", table))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		var ref, from, to string
+		err = cursor.Scan(&ref, &from, &to)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		key := strings.ToLower(fmt.Sprintf("(%s) references %s (%s)", from, ref, to))
+		if fks[key] {
+			delete(fks, key)
+			continue
+		}
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: foreign key %s not expected", table, key))
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	for key := range fks {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: foreign key %s not found", table, key))
+	}
+	sort.Strings(mismatches)
+
+	return mismatches, nil
+}
+
+//
+// Live index.
+type liveIndex struct {
+	// Origin: (c)reated, (u)nique constraint.
+	origin string
+	// Indexed columns (lower case, comma separated).
+	columns string
+}
+
+//
+// Get the (live) indexes of the table in the DB.
+// Returns a map of: name => index.
+func (t Table) indexList(table string) (map[string]liveIndex, error) {
+	indexes := map[string]liveIndex{}
+	cursor, err := t.DB.Query(IndexListSQL, sql.Named("table", table))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		name := ""
+		index := liveIndex{}
+		err = cursor.Scan(&name, &index.origin)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		indexes[name] = index
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	cursor.Close()
+	for name, index := range indexes {
+		columns := []string{}
+		list, err := t.DB.Query(IndexInfoSQL, sql.Named("index", name))
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		for list.Next() {
+			column := ""
+			err = list.Scan(&column)
+			if err != nil {
+				list.Close()
+				return nil, liberr.Wrap(err)
+			}
+			columns = append(columns, column)
+		}
+		err = list.Err()
+		list.Close()
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		index.columns = strings.ToLower(strings.Join(columns, ","))
+		indexes[name] = index
+	}
+
+	return indexes, nil
+}
+
+//
+// Add missing columns.
+// Columns for model fields not found in the (live) table
+// are added using ALTER TABLE. Existing rows are populated
+// with the default value (or NULL when nullable). Fields
+// without a default that are not nullable cannot be added.
+// Returns the names of the added columns.
+func (t Table) AddColumns(model interface{}) ([]string, error) {
+	added := []string{}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	err = t.Validate(fields)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	columns, err := t.Columns(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	found := map[string]bool{}
+	for name := range columns {
+		found[strings.ToLower(name)] = true
+	}
+	for _, f := range t.StoredFields(fields) {
+		if found[strings.ToLower(f.Name)] {
+			continue
+		}
+		if !f.Defaulted() && !f.Nullable() {
+			return nil, liberr.Wrap(AddColumnErr)
+		}
+		_, err = t.DB.Exec("ALTER TABLE " + t.Name(model) + " ADD COLUMN " + f.DDL())
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		added = append(added, f.Name)
+	}
+
+	return added, nil
+}
+
+//
+// Get the (live) table columns in the DB.
+// Returns a map of: name => type.
+func (t Table) Columns(model interface{}) (map[string]string, error) {
+	columns := map[string]string{}
+	cursor, err := t.DB.Query(TableInfoSQL, sql.Named("table", t.Name(model)))
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		name := ""
+		kind := ""
+		err = cursor.Scan(&name, &kind)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		columns[name] = kind
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return columns, nil
+}
+
+//
+// Verify the (live) DB schema matches the registered models.
+// May be called at any time to detect changes made to the
+// schema by other (external) means. The columns (and types),
+// indexes and constraints (unique, foreign key) are compared.
+// Returns a SchemaDrift error listing the mismatches.
+// Example:
+//   err := client.Verify()
+//   drift := &model.SchemaDrift{}
+//   if errors.As(err, &drift) {
+//       for _, m := range drift.Mismatches {
+//           ...
+//       }
+//   }
+func (r *Client) Verify() error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	err := r.drift(r.db, true)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// Detect schema drift.
+// Returns a SchemaDrift error when the DB schema does not
+// match the models. The indexes and constraints are compared
+// only when `indexes` is true.
+func (r *Client) drift(db DBTX, indexes bool) error {
+	drift := &SchemaDrift{}
+	table := r.table(db)
+	for _, m := range r.models {
+		columns := []string{}
+		for _, name := range r.PromotedLabels[table.Name(m)] {
+			columns = append(columns, PromotedColumn(name))
+		}
+		mismatches, err := table.Drift(m, columns...)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		drift.Mismatches = append(drift.Mismatches, mismatches...)
+		if !indexes {
+			continue
+		}
+		mismatches, err = table.IndexDrift(m, columns...)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		drift.Mismatches = append(drift.Mismatches, mismatches...)
+	}
+	if len(drift.Mismatches) > 0 {
+		return liberr.Wrap(drift)
+	}
+
+	return nil
+}
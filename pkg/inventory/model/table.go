@@ -12,13 +12,22 @@ import (
 	"github.com/mattn/go-sqlite3"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 )
 
 const (
 	Tag = "sql"
+	// Column default value tag.
+	DefaultTag = "default"
+	// Computed (read-only) field tag.
+	ComputedTag = "computed"
+	// Max number of keys bound in a single
+	// statement by GetAll().
+	GetAllBatch = 500
 )
 
 //
@@ -46,6 +55,21 @@ ON {{.Table}}
 );
 `
 
+var PartialIndexDDL = `
+CREATE INDEX IF NOT EXISTS {{.Table}}_{{.Name}}
+ON {{.Table}}
+(
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Name }}
+{{ end -}}
+)
+{{ if .Where -}}
+WHERE {{ .Where }}
+{{ end -}}
+;
+`
+
 //
 // SQL templates.
 var InsertSQL = `
@@ -60,7 +84,17 @@ VALUES (
 {{ if $i }},{{ end -}}
 {{ $f.Param }}
 {{ end -}}
-);
+)
+{{- if .IfAbsent }}
+ON CONFLICT DO NOTHING
+{{- else if .Replace }}
+ON CONFLICT ({{ .Pk.Name }}) DO UPDATE SET
+{{ range $i,$f := .Replace -}}
+{{ if $i }},{{ end -}}
+{{ $f.Name }} = excluded.{{ $f.Name }}
+{{ end -}}
+{{- end -}}
+;
 `
 
 var UpdateSQL = `
@@ -75,10 +109,53 @@ WHERE
 ;
 `
 
+var UpdateAllSQL = `
+UPDATE {{.Table}}
+SET
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Name }} = {{ $f.Param }}
+{{ end -}}
+{{ if .Options -}}
+WHERE
+{{ .Predicate.Expr }}
+{{ end -}}
+;
+`
+
 var DeleteSQL = `
 DELETE FROM {{.Table}}
 WHERE
 {{ .Pk.Name }} = {{ .Pk.Param }}
+{{ if .Options -}}
+AND ({{ .Predicate.Expr }})
+{{ end -}}
+;
+`
+
+var DeleteInSQL = `
+DELETE FROM {{.Table}}
+WHERE
+{{ .Field.Name }} IN (
+{{- range $i,$k := .Keys -}}
+{{ if $i }},{{ end }}:k{{ $i }}
+{{- end -}}
+)
+{{ if .Options -}}
+AND ({{ .Predicate.Expr }})
+{{ end -}}
+;
+`
+
+var IncrementSQL = `
+UPDATE {{.Table}}
+SET
+{{ .Field.Name }} = {{ .Field.Name }} + :delta
+{{ if .Updated -}}
+,{{ .Updated.Name }} = {{ .Updated.Param }}
+{{ end -}}
+WHERE
+{{ .Pk.Name }} = {{ .Pk.Param }}
 ;
 `
 
@@ -86,7 +163,7 @@ var GetSQL = `
 SELECT
 {{ range $i,$f := .Fields -}}
 {{ if $i }},{{ end -}}
-{{ $f.Name }}
+{{ $f.Select }}
 {{ end -}}
 FROM {{.Table}}
 WHERE
@@ -94,6 +171,22 @@ WHERE
 ;
 `
 
+var GetAllSQL = `
+SELECT
+{{ range $i,$f := .Fields -}}
+{{ if $i }},{{ end -}}
+{{ $f.Select }}
+{{ end -}}
+FROM {{.Table}}
+WHERE
+{{ .Pk.Name }} IN (
+{{- range $i,$k := .Keys -}}
+{{ if $i }},{{ end }}:k{{ $i }}
+{{- end -}}
+)
+;
+`
+
 var ListSQL = `
 SELECT
 {{ if .Count -}}
@@ -101,7 +194,7 @@ COUNT(*)
 {{ else -}}
 {{ range $i,$f := .Fields -}}
 {{ if $i }},{{ end -}}
-{{ $f.Name }}
+{{ $f.Select }}
 {{ end -}}
 {{ end -}}
 FROM {{.Table}}
@@ -123,6 +216,17 @@ LIMIT {{.Page.Limit}} OFFSET {{.Page.Offset}}
 ;
 `
 
+//
+// Approximate count SQL.
+// Row estimate collected by ANALYZE.
+var ApproxCountSQL = `
+SELECT stat
+FROM sqlite_stat1
+WHERE tbl = :table AND (idx IS NULL OR idx LIKE 'sqlite_autoindex_%')
+LIMIT 1
+;
+`
+
 //
 // Errors
 var (
@@ -146,6 +250,46 @@ var (
 	PredicateTypeErr = errors.New("predicate type not valid for field")
 	// Invalid predicate value.
 	PredicateValueErr = errors.New("predicate value not valid")
+	// Invalid field referenced.
+	FieldRefErr = errors.New("referenced unknown or immutable field")
+	// Integer field type error.
+	IntFieldTypeErr = errors.New("field type must be (int)")
+	// Label value type error.
+	LabelTypeErr = errors.New("label value must be (int, str, bool)")
+	// Index predicate references unknown column.
+	IndexWhereErr = errors.New("index predicate not valid")
+	// Default value not valid for the field type.
+	DefaultValueErr = errors.New("default value not valid for field")
+	// Column cannot be added.
+	AddColumnErr = errors.New("column (without default) cannot be added")
+	// Computed field cannot be (pk, key).
+	ComputedFieldErr = errors.New("computed field cannot be (pk, key)")
+	// Field (notnull) must not be the zero value.
+	NotNullErr = errors.New("notnull field must not be zero value")
+	// Full-text search (fts) field must be (str).
+	FtsFieldErr = errors.New("fts field must be (str) and not computed")
+	// Full-text search on model without fts fields.
+	FtsNotIndexedErr = errors.New("model has no fts fields")
+	// Chunk size not valid.
+	ChunkSizeErr = errors.New("chunk size must be > 0")
+	// Sort position not valid.
+	SortErr = errors.New("sort position not valid")
+	// Keyset (after) token not valid.
+	AfterTokenErr = errors.New("after token not valid for sort")
+	// Nullable field cannot be (pk, key).
+	NullableFieldErr = errors.New("nullable field cannot be (pk, key)")
+	// Expiry (expires) field must be (int).
+	ExpiresFieldErr = errors.New("expires field must be (int) and not computed")
+	// Sweep of model without an expires field.
+	NotExpiringErr = errors.New("model has no expires field")
+	// Updated (timestamp) field must be (int64).
+	UpdatedFieldErr = errors.New("updated field must be (int64) and not computed")
+	// Model without an updated (timestamp) field.
+	NotUpdatedErr = errors.New("model has no updated field")
+	// Unique index (group) not defined by the model.
+	UniqueIndexErr = errors.New("unique index not found")
+	// Scanned value overflows the (int) field type.
+	OverflowErr = errors.New("value overflows field type")
 )
 
 //
@@ -161,17 +305,50 @@ var (
 type Table struct {
 	// Database connection.
 	DB DBTX
+	// Naming strategy.
+	// Optional.
+	Naming Naming
+	// Label kind namespace (prefix).
+	// Optional.
+	Namespace string
+	// Generate the (str) PK of models without natural keys
+	// inserted with the PK not set.
+	// Optional. See: IDGenerator.
+	GenerateID func() string
 }
 
 //
 // Get the table name for the model.
+// The name is provided by models implementing `TableNamed`.
+// Else, the name of the model type.
 func (t Table) Name(model interface{}) string {
+	if m, cast := model.(TableNamed); cast {
+		return m.TableName()
+	}
 	mt := reflect.TypeOf(model)
 	if mt.Kind() == reflect.Ptr {
 		mt = mt.Elem()
 	}
 
-	return mt.Name()
+	return t.ident(mt.Name())
+}
+
+//
+// Get the label kind for the model.
+// The table name prefixed by the namespace.
+func (t Table) Kind(model interface{}) string {
+	return t.Namespace + t.Name(model)
+}
+
+//
+// Get the (table or column) identifier for a name
+// using the naming strategy.
+func (t Table) ident(name string) string {
+	if t.Naming == nil {
+		return name
+	}
+
+	return t.Naming(name)
 }
 
 //
@@ -191,6 +368,63 @@ func (t Table) Validate(fields []*Field) error {
 	return nil
 }
 
+//
+// Validate required (notnull) field values.
+// Returns NotNullErr when a notnull field has the zero value
+// or, when nullable, is not valid (nil).
+// Fields with a default value are exempt when inserted.
+func (t Table) Required(fields []*Field, insert bool) error {
+	for _, f := range fields {
+		if !f.NotNull() {
+			continue
+		}
+		if f.Nullable() {
+			if f.valid.Bool() {
+				continue
+			}
+		} else {
+			if !f.Value.IsZero() {
+				continue
+			}
+		}
+		if insert && f.defaulted {
+			continue
+		}
+		return liberr.Wrap(NotNullErr)
+	}
+
+	return nil
+}
+
+//
+// Get the fields to be inserted.
+// Fields with a default value are omitted when the value
+// is the zero value (or, when nullable, is not valid) so the
+// DB default is applied. The model is not updated with the
+// applied default.
+func (t Table) InsertFields(fields []*Field) []*Field {
+	list := []*Field{}
+	for _, f := range fields {
+		if f.Computed != "" {
+			continue
+		}
+		if f.defaulted {
+			if f.Nullable() {
+				if !f.valid.Bool() {
+					continue
+				}
+			} else {
+				if f.Value.IsZero() {
+					continue
+				}
+			}
+		}
+		list = append(list, f)
+	}
+
+	return list
+}
+
 //
 // Get table and index create DDL.
 func (t Table) DDL(model interface{}) ([]string, error) {
@@ -204,6 +438,7 @@ func (t Table) DDL(model interface{}) ([]string, error) {
 	if err != nil {
 		return nil, liberr.Wrap(err)
 	}
+	fields = t.StoredFields(fields)
 	// Table
 	tpl, err = tpl.Parse(TableDDL)
 	if err != nil {
@@ -223,6 +458,7 @@ func (t Table) DDL(model interface{}) ([]string, error) {
 	}
 	list = append(list, bfr.String())
 	// Index.
+	allFields := fields
 	fields = t.KeyFields(fields)
 	if len(fields) > 0 {
 		tpl, err = tpl.Parse(IndexDDL)
@@ -241,24 +477,130 @@ func (t Table) DDL(model interface{}) ([]string, error) {
 		}
 		list = append(list, bfr.String())
 	}
+	// Partial (and other) indexes.
+	indexes, err := t.Indexes(t.Name(model), allFields)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	list = append(list, indexes...)
+	// Full-text search.
+	fts, err := t.FtsDDL(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	list = append(list, fts...)
+
+	return list, nil
+}
+
+//
+// Index.
+type Index struct {
+	// Table name.
+	Table string
+	// Index name.
+	Name string
+	// Indexed fields.
+	Fields []*Field
+	// Predicate (partial index).
+	Where string
+}
+
+//
+// Get the DDL for indexes declared using `index(G)` tags.
+func (t Table) Indexes(table string, fields []*Field) ([]string, error) {
+	list := []string{}
+	names := []string{}
+	indexes := map[string]*Index{}
+	for _, f := range fields {
+		for _, opt := range f.Indexes() {
+			index, found := indexes[opt.Name]
+			if !found {
+				index = &Index{
+					Table: table,
+					Name:  opt.Name,
+				}
+				indexes[opt.Name] = index
+				names = append(names, opt.Name)
+			}
+			index.Fields = append(index.Fields, f)
+			if opt.Where != "" {
+				where, err := t.validWhere(opt.Where, fields)
+				if err != nil {
+					return nil, liberr.Wrap(err)
+				}
+				index.Where = where
+			}
+		}
+	}
+	for _, name := range names {
+		tpl := template.New("")
+		tpl, err := tpl.Parse(PartialIndexDDL)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		bfr := &bytes.Buffer{}
+		err = tpl.Execute(bfr, indexes[name])
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		list = append(list, bfr.String())
+	}
 
 	return list, nil
 }
 
+//
+// Validate an index predicate.
+// Identifiers must be model fields or (allowed) keywords.
+// Returns the predicate with fields mapped to column names.
+func (t Table) validWhere(where string, fields []*Field) (string, error) {
+	if strings.ContainsAny(where, ";()") {
+		return "", liberr.Wrap(IndexWhereErr)
+	}
+	stripped := StringLiteralRegex.ReplaceAllString(where, "")
+	if strings.Contains(stripped, "'") {
+		return "", liberr.Wrap(IndexWhereErr)
+	}
+	mapped := ""
+	literals := StringLiteralRegex.FindAllStringIndex(where, -1)
+	literals = append(literals, []int{len(where), len(where)})
+	begin := 0
+	for _, literal := range literals {
+		part := where[begin:literal[0]]
+		var err error
+		part = IdentRegex.ReplaceAllStringFunc(
+			part,
+			func(ident string) string {
+				if WhereKeywords[strings.ToUpper(ident)] {
+					return ident
+				}
+				for _, f := range fields {
+					if f.Match(ident) {
+						return f.Name
+					}
+				}
+				err = liberr.Wrap(IndexWhereErr)
+				return ident
+			})
+		if err != nil {
+			return "", err
+		}
+		mapped += part + where[literal[0]:literal[1]]
+		begin = literal[1]
+	}
+
+	return mapped, nil
+}
+
 //
 // Insert the model in the DB.
 // Expects the primary key (PK) to be set.
 func (t Table) Insert(model interface{}) error {
-	fields, err := t.Fields(model)
-	if err != nil {
-		return liberr.Wrap(err)
-	}
-	t.SetPk(fields)
-	stmt, err := t.insertSQL(t.Name(model), fields)
+	stmt, params, err := t.RenderInsert(model)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	params := t.Params(fields)
 	r, err := t.DB.Exec(stmt, params...)
 	if err != nil {
 		if sql3Err, cast := err.(sqlite3.Error); cast {
@@ -277,19 +619,55 @@ func (t Table) Insert(model interface{}) error {
 }
 
 //
-// Update the model in the DB.
-// Expects the primary key (PK) or natural keys to be set.
-func (t Table) Update(model interface{}) error {
+// Insert the model in the DB when absent.
+// Nothing is done when a model with the same PK (or
+// natural keys) exists. Returns whether the model was inserted.
+func (t Table) InsertIfAbsent(model interface{}) (bool, error) {
+	return t.InsertWith(model, OnConflictIgnore)
+}
+
+//
+// Insert the model in the DB using the conflict strategy.
+// Unlike Insert(), a conflict is not resolved by updating the
+// model unless OnConflictReplace is specified. Returns whether
+// the model was written.
+func (t Table) InsertWith(model interface{}, onConflict OnConflict) (bool, error) {
 	fields, err := t.Fields(model)
 	if err != nil {
-		return liberr.Wrap(err)
+		return false, liberr.Wrap(err)
 	}
+	t.setID(model, fields)
 	t.SetPk(fields)
-	stmt, err := t.updateSQL(t.Name(model), fields)
+	t.stamp(fields)
+	err = t.Required(fields, true)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	fields = t.InsertFields(fields)
+	stmt, err := t.insertSQL(t.Name(model), fields, onConflict)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	r, err := t.DB.Exec(stmt, t.Params(fields)...)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	nRows, err := r.RowsAffected()
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+
+	return nRows > 0, nil
+}
+
+//
+// Update the model in the DB.
+// Expects the primary key (PK) or natural keys to be set.
+func (t Table) Update(model interface{}) error {
+	stmt, params, err := t.RenderUpdate(model)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	params := t.Params(fields)
 	r, err := t.DB.Exec(stmt, params...)
 	if err != nil {
 		return liberr.Wrap(err)
@@ -306,15 +684,49 @@ func (t Table) Update(model interface{}) error {
 }
 
 //
-// Delete the model in the DB.
+// Update the named fields of the model in the DB.
 // Expects the primary key (PK) or natural keys to be set.
-func (t Table) Delete(model interface{}) error {
+// Only mutable fields may be updated. The updated (timestamp)
+// field is set and included.
+func (t Table) UpdateFields(model interface{}, names ...string) error {
 	fields, err := t.Fields(model)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
 	t.SetPk(fields)
-	stmt, err := t.deleteSQL(t.Name(model), fields)
+	selected := []*Field{}
+	for _, name := range names {
+		var field *Field
+		for _, f := range fields {
+			if f.Match(name) && f.Mutable() {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return liberr.Wrap(FieldRefErr)
+		}
+		selected = append(selected, field)
+	}
+	err = t.Required(selected, false)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	if f := t.stamp(fields); f != nil {
+		stamped := false
+		for _, s := range selected {
+			stamped = stamped || s == f
+		}
+		if !stamped {
+			selected = append(selected, f)
+		}
+	}
+	stmt, err := t.updateSQL(
+		t.Name(model),
+		append(selected, t.PkField(fields)))
 	if err != nil {
 		return liberr.Wrap(err)
 	}
@@ -328,112 +740,1164 @@ func (t Table) Delete(model interface{}) error {
 		return liberr.Wrap(err)
 	}
 	if nRows == 0 {
-		return nil
+		return liberr.Wrap(NotFound)
 	}
 
 	return nil
 }
 
 //
-// Get the model in the DB.
+// Touch the model in the DB.
+// Only the updated (timestamp) field is set and updated.
+// Nothing is done when the field is not declared.
 // Expects the primary key (PK) or natural keys to be set.
-// Fetch the row and populate the fields in the model.
-func (t Table) Get(model interface{}) error {
+func (t Table) Touch(model interface{}) error {
 	fields, err := t.Fields(model)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	t.SetPk(fields)
-	stmt, err := t.getSQL(t.Name(model), fields)
+	f := t.UpdatedField(fields)
+	if f == nil {
+		return nil
+	}
+	err = t.UpdateFields(model, f.Name)
 	if err != nil {
 		return liberr.Wrap(err)
 	}
-	params := t.Params(fields)
-	row := t.DB.QueryRow(stmt, params...)
-	err = t.scan(row, fields)
 
-	return liberr.Wrap(err)
+	return nil
 }
 
 //
-// List the model in the DB.
-// Qualified by the list options.
-func (t Table) List(list interface{}, options ListOptions) error {
-	var model interface{}
-	lt := reflect.TypeOf(list)
-	lv := reflect.ValueOf(list)
-	switch lt.Kind() {
-	case reflect.Ptr:
-		lt = lt.Elem()
-		lv = lv.Elem()
-	default:
-		return liberr.Wrap(MustBeSlicePtrErr)
-	}
-	switch lt.Kind() {
-	case reflect.Slice:
-		model = reflect.New(lt.Elem()).Interface()
-	default:
-		return liberr.Wrap(MustBeSlicePtrErr)
-	}
+// Update the named fields of ALL models matching the predicate.
+// The `set` is a map of: field name => value. Only mutable
+// fields may be updated. The predicate may be nil. The updated
+// (timestamp) field is set to the current time when declared.
+// Returns the number of models updated.
+func (t Table) UpdateAll(model interface{}, set map[string]interface{}, predicate Predicate) (int64, error) {
 	fields, err := t.Fields(model)
 	if err != nil {
-		return liberr.Wrap(err)
+		return 0, liberr.Wrap(err)
 	}
-	stmt, err := t.listSQL(t.Name(model), fields, &options)
-	if err != nil {
-		return liberr.Wrap(err)
+	names := []string{}
+	for name := range set {
+		names = append(names, name)
 	}
-	params := options.Params()
-	cursor, err := t.DB.Query(stmt, params...)
-	if err != nil {
-		return liberr.Wrap(err)
+	sort.Strings(names)
+	selected := []*Field{}
+	params := []interface{}{}
+	for _, name := range names {
+		var field *Field
+		for _, f := range fields {
+			if f.Match(name) && f.Mutable() {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return 0, liberr.Wrap(FieldRefErr)
+		}
+		value, err := field.AsValue(set[name])
+		if err != nil {
+			return 0, liberr.Wrap(err)
+		}
+		if field.NotNull() && !field.Nullable() && reflect.ValueOf(value).IsZero() {
+			return 0, liberr.Wrap(NotNullErr)
+		}
+		selected = append(selected, field)
+		params = append(params, sql.Named(field.Name, value))
+	}
+	if len(selected) == 0 {
+		return 0, nil
+	}
+	if f := t.UpdatedField(fields); f != nil {
+		now := sql.Named(f.Name, time.Now().UnixNano())
+		stamped := false
+		for i, s := range selected {
+			if s == f {
+				params[i] = now
+				stamped = true
+			}
+		}
+		if !stamped {
+			selected = append(selected, f)
+			params = append(params, now)
+		}
 	}
-	defer cursor.Close()
-	mList := reflect.MakeSlice(lt, 0, 0)
-	for cursor.Next() {
-		mt := reflect.TypeOf(model)
-		mPtr := reflect.New(mt.Elem())
-		mInt := mPtr.Interface()
-		newFields, _ := t.Fields(mInt)
-		err = t.scan(cursor, newFields)
+	var options *ListOptions
+	if predicate != nil {
+		options = &ListOptions{Predicate: predicate}
+		options.naming = t.Naming
+		options.namespace = t.Namespace
+		err = options.Build(t.Name(model), fields)
 		if err != nil {
-			return liberr.Wrap(err)
+			return 0, liberr.Wrap(err)
 		}
-		mList = reflect.Append(mList, mPtr.Elem())
+		params = append(params, options.Params()...)
 	}
-
-	lv.Set(mList)
-
-	return nil
-}
-
-//
-// Count the models in the DB.
-// Qualified by the model field values and list options.
+	tpl := template.New("")
+	tpl, err = tpl.Parse(UpdateAllSQL)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:   t.Name(model),
+			Fields:  selected,
+			Options: options,
+		})
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	r, err := t.DB.Exec(bfr.String(), params...)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	nRows, err := r.RowsAffected()
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+
+	return nRows, nil
+}
+
+//
+// Increment the named (int) field of the model in the DB.
+// Expects the primary key (PK) or natural keys to be set.
+// The field is incremented by `delta` in the DB and the
+// new value is read and set in the model. The updated
+// (timestamp) field is set when declared.
+// The caller must serialize writes for the read to be
+// consistent (or use a transaction).
+func (t Table) Increment(model interface{}, name string, delta int64) (int64, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	var field *Field
+	for _, f := range fields {
+		if f.Match(name) && f.Mutable() {
+			field = f
+			break
+		}
+	}
+	if field == nil {
+		return 0, liberr.Wrap(FieldRefErr)
+	}
+	switch field.Value.Kind() {
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+	default:
+		return 0, liberr.Wrap(IntFieldTypeErr)
+	}
+	tpl := template.New("")
+	tpl, err = tpl.Parse(IncrementSQL)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	pk := t.PkField(fields)
+	updated := t.stamp(fields)
+	if updated == field {
+		updated = nil
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		struct {
+			Table   string
+			Field   *Field
+			Pk      *Field
+			Updated *Field
+		}{
+			Table:   t.Name(model),
+			Field:   field,
+			Pk:      pk,
+			Updated: updated,
+		})
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	params := append(t.Params(fields), sql.Named("delta", delta))
+	r, err := t.DB.Exec(bfr.String(), params...)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	nRows, err := r.RowsAffected()
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	if nRows == 0 {
+		return 0, liberr.Wrap(NotFound)
+	}
+	row := t.DB.QueryRow(
+		"SELECT "+field.Name+" FROM "+t.Name(model)+" WHERE "+pk.Name+" = :pk",
+		sql.Named("pk", pk.Pull()))
+	err = t.scan(row, []*Field{field})
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+
+	return field.int, nil
+}
+
+//
+// Delete the model in the DB.
+// Expects the primary key (PK) or natural keys to be set.
+func (t Table) Delete(model interface{}) error {
+	stmt, params, err := t.RenderDelete(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	r, err := t.DB.Exec(stmt, params...)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	nRows, err := r.RowsAffected()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	if nRows == 0 {
+		return nil
+	}
+
+	return nil
+}
+
+//
+// Delete the models in the DB by primary key.
+// The `model` determines the model type. Keys not found are
+// ignored. Keys are deleted in batches of GetAllBatch using
+// a single statement per batch. Returns the number of
+// models deleted.
+func (t Table) DeleteKeys(model interface{}, keys []interface{}) (int64, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	pk := t.PkField(fields)
+	if pk == nil {
+		return 0, liberr.Wrap(MustHavePkErr)
+	}
+	n, err := t.deleteIn(model, pk.Name, keys, nil)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+
+	return n, nil
+}
+
+//
+// Delete the models in the DB with the named field value
+// in the list of values and matching the (optional) predicate.
+// The values are deleted in batches of GetAllBatch using a
+// single statement per batch. Returns the number of models
+// deleted.
+func (t Table) deleteIn(model interface{}, name string, values []interface{}, predicate Predicate) (int64, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	var field *Field
+	for _, f := range fields {
+		if f.Match(name) {
+			field = f
+			break
+		}
+	}
+	if field == nil {
+		return 0, liberr.Wrap(FieldRefErr)
+	}
+	tpl := template.New("")
+	tpl, err = tpl.Parse(DeleteInSQL)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	deleted := int64(0)
+	for begin := 0; begin < len(values); begin += GetAllBatch {
+		end := begin + GetAllBatch
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[begin:end]
+		params := []interface{}{}
+		for i, value := range batch {
+			v, err := field.AsValue(value)
+			if err != nil {
+				return 0, liberr.Wrap(err)
+			}
+			params = append(params, sql.Named("k"+strconv.Itoa(i), v))
+		}
+		var options *ListOptions
+		if predicate != nil {
+			options = &ListOptions{Predicate: predicate}
+			options.naming = t.Naming
+			options.namespace = t.Namespace
+			err = options.Build(t.Name(model), fields)
+			if err != nil {
+				return 0, liberr.Wrap(err)
+			}
+			params = append(params, options.Params()...)
+		}
+		bfr := &bytes.Buffer{}
+		err = tpl.Execute(
+			bfr,
+			struct {
+				TmplData
+				Field *Field
+				Keys  []interface{}
+			}{
+				TmplData: TmplData{
+					Table:   t.Name(model),
+					Options: options,
+				},
+				Field: field,
+				Keys:  batch,
+			})
+		if err != nil {
+			return 0, liberr.Wrap(err)
+		}
+		r, err := t.DB.Exec(bfr.String(), params...)
+		if err != nil {
+			return 0, liberr.Wrap(err)
+		}
+		nRows, err := r.RowsAffected()
+		if err != nil {
+			return 0, liberr.Wrap(err)
+		}
+		deleted += nRows
+	}
+
+	return deleted, nil
+}
+
+//
+// Delete the model in the DB when the predicate matches.
+// Expects the primary key (PK) or natural keys to be set.
+// Returns whether the model was deleted.
+func (t Table) DeleteIf(model interface{}, predicate Predicate) (bool, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	var options *ListOptions
+	if predicate != nil {
+		options = &ListOptions{Predicate: predicate}
+		options.naming = t.Naming
+		options.namespace = t.Namespace
+		err = options.Build(t.Name(model), fields)
+		if err != nil {
+			return false, liberr.Wrap(err)
+		}
+	}
+	stmt, err := t.deleteSQL(t.Name(model), fields, options)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	params := t.Params(fields)
+	if options != nil {
+		params = append(params, options.Params()...)
+	}
+	r, err := t.DB.Exec(stmt, params...)
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+	nRows, err := r.RowsAffected()
+	if err != nil {
+		return false, liberr.Wrap(err)
+	}
+
+	return nRows > 0, nil
+}
+
+//
+// Get the model in the DB.
+// Expects the primary key (PK) or natural keys to be set.
+// Fetch the row and populate the fields in the model.
+func (t Table) Get(model interface{}) error {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	stmt, err := t.getSQL(t.Name(model), fields)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	params := t.Params(fields)
+	row := t.DB.QueryRow(stmt, params...)
+	err = t.scan(row, fields)
+
+	return liberr.Wrap(err)
+}
+
+//
+// Get the model in the DB by unique index.
+// The model is matched using the (currently set) values of
+// the fields in the unique(`name`) group rather than the PK.
+func (t Table) GetByUnique(model interface{}, name string) error {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	predicates := []Predicate{}
+	for _, f := range fields {
+		for _, group := range f.Unique() {
+			if group == name {
+				predicates = append(predicates, Eq(f.Name, f.Pull()))
+			}
+		}
+	}
+	if len(predicates) == 0 {
+		return liberr.Wrap(UniqueIndexErr)
+	}
+	list, err := t.listModels(
+		model,
+		ListOptions{
+			Predicate: And(predicates...),
+			Page:      &Page{Limit: 1},
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	if len(list) == 0 {
+		return liberr.Wrap(NotFound)
+	}
+	reflect.ValueOf(model).Elem().Set(
+		reflect.ValueOf(list[0]).Elem())
+
+	return nil
+}
+
+//
+// Get the models in the DB by primary key.
+// The `model` determines the model type. Keys not found
+// are absent from the result. Keys are fetched in batches
+// of GetAllBatch using a single statement per batch.
+func (t Table) GetAll(model interface{}, keys []interface{}) ([]Model, error) {
+	models := []Model{}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	pk := t.PkField(fields)
+	if pk == nil {
+		return nil, liberr.Wrap(MustHavePkErr)
+	}
+	tpl := template.New("")
+	tpl, err = tpl.Parse(GetAllSQL)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+	for begin := 0; begin < len(keys); begin += GetAllBatch {
+		end := begin + GetAllBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[begin:end]
+		params := []interface{}{}
+		for i, key := range batch {
+			v, err := pk.AsValue(key)
+			if err != nil {
+				return nil, liberr.Wrap(err)
+			}
+			params = append(params, sql.Named("k"+strconv.Itoa(i), v))
+		}
+		bfr := &bytes.Buffer{}
+		err = tpl.Execute(
+			bfr,
+			struct {
+				TmplData
+				Keys []interface{}
+			}{
+				TmplData: TmplData{
+					Table:  t.Name(model),
+					Fields: fields,
+					Pk:     pk,
+				},
+				Keys: batch,
+			})
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		cursor, err := t.DB.Query(bfr.String(), params...)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		for cursor.Next() {
+			mPtr := reflect.New(mt)
+			m, cast := mPtr.Interface().(Model)
+			if !cast {
+				cursor.Close()
+				return nil, liberr.Wrap(MustBeObjectErr)
+			}
+			newFields, _ := t.Fields(m)
+			err = t.scan(cursor, newFields)
+			if err != nil {
+				cursor.Close()
+				return nil, liberr.Wrap(err)
+			}
+			models = append(models, m)
+		}
+		err = cursor.Err()
+		cursor.Close()
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+	}
+
+	return models, nil
+}
+
+//
+// List the model in the DB.
+// Qualified by the list options. Labels are populated
+// based on the detail level.
+func (t Table) List(list interface{}, options ListOptions) error {
+	var model interface{}
+	lt := reflect.TypeOf(list)
+	lv := reflect.ValueOf(list)
+	switch lt.Kind() {
+	case reflect.Ptr:
+		lt = lt.Elem()
+		lv = lv.Elem()
+	default:
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	switch lt.Kind() {
+	case reflect.Slice:
+		model = reflect.New(lt.Elem()).Interface()
+	default:
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	stmt, params, err := t.Render(model, options)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(stmt, params...)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	mList := reflect.MakeSlice(lt, 0, 0)
+	for cursor.Next() {
+		mt := reflect.TypeOf(model)
+		mPtr := reflect.New(mt.Elem())
+		mInt := mPtr.Interface()
+		newFields, _ := t.Fields(mInt)
+		err = t.scan(cursor, newFields)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		mList = reflect.Append(mList, mPtr.Elem())
+	}
+	err = cursor.Err()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	if options.Detail >= DetailLabels {
+		models := []Model{}
+		for i := 0; i < mList.Len(); i++ {
+			if m, cast := mList.Index(i).Addr().Interface().(Model); cast {
+				models = append(models, m)
+			}
+		}
+		err = t.SetLabels(models)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+	}
+
+	lv.Set(mList)
+
+	return nil
+}
+
+//
+// List the models in the DB.
+// Qualified by the list options.
+// The `model` determines the model type.
+func (t Table) listModels(model interface{}, options ListOptions) ([]Model, error) {
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+	listPtr := reflect.New(reflect.SliceOf(mt))
+	err := t.List(listPtr.Interface(), options)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	models := []Model{}
+	list := listPtr.Elem()
+	for i := 0; i < list.Len(); i++ {
+		m, cast := list.Index(i).Addr().Interface().(Model)
+		if !cast {
+			return nil, liberr.Wrap(MustBeObjectErr)
+		}
+		models = append(models, m)
+	}
+
+	return models, nil
+}
+
+//
+// List a chunk of models in the DB.
+// Qualified by the list options. Models are ordered by PK and
+// only those with a PK greater than `after` are listed. The
+// `after` may be nil to list the first chunk. Sort and pagination
+// are ignored. Returns the models and the PK of the last model.
+func (t Table) ListChunk(model interface{}, options ListOptions, after interface{}, limit int) ([]Model, interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, nil, liberr.Wrap(err)
+	}
+	options.Sort = nil
+	_, err = options.keyset(fields)
+	if err != nil {
+		return nil, nil, liberr.Wrap(err)
+	}
+	options.Page = &Page{Limit: limit}
+	options.After = nil
+	if after != nil {
+		options.After = []interface{}{after}
+	}
+	models, err := t.listModels(model, options)
+	if err != nil {
+		return nil, nil, liberr.Wrap(err)
+	}
+	if len(models) == 0 {
+		return models, after, nil
+	}
+	next, err := t.next(models[len(models)-1], options)
+	if err != nil {
+		return nil, nil, liberr.Wrap(err)
+	}
+
+	return models, next[0], nil
+}
+
+//
+// List the models in the DB (keyset pagination).
+// Qualified by the list options. The models are sorted by
+// the sort fields and then by PK. When ListOptions.After is
+// set, only models with a sort key greater than the token
+// are listed. Page.Offset is ignored. Returns the token used
+// to list the next page. The token is nil when the page is
+// not full (last page) or the options have no Page.
+func (t Table) ListAfter(list interface{}, options ListOptions) ([]interface{}, error) {
+	lt := reflect.TypeOf(list)
+	if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+		return nil, liberr.Wrap(MustBeSlicePtrErr)
+	}
+	model := reflect.New(lt.Elem().Elem()).Interface()
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	_, err = options.keyset(fields)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	if options.Page != nil {
+		options.Page = &Page{Limit: options.Page.Limit}
+	}
+	err = t.List(list, options)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	lv := reflect.ValueOf(list).Elem()
+	if options.Page == nil || lv.Len() == 0 || lv.Len() < options.Page.Limit {
+		return nil, nil
+	}
+	next, err := t.next(lv.Index(lv.Len()-1).Addr().Interface(), options)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return next, nil
+}
+
+//
+// Get the (keyset) token for the model.
+// The sort key field values.
+func (t Table) next(model interface{}, options ListOptions) ([]interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	keys, err := options.keyset(fields)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	token := []interface{}{}
+	for _, f := range keys {
+		token = append(token, f.Pull())
+	}
+
+	return token, nil
+}
+
+//
+// Count the models in the DB.
+// Qualified by the model field values and list options.
 // Expects natural keys to be set.
 // Else, ALL models counted.
 func (t Table) Count(model interface{}, predicate Predicate) (int64, error) {
+	return t.CountOptions(model, ListOptions{Predicate: predicate})
+}
+
+//
+// Count the models in the DB.
+// Qualified by the list options.
+// Counts the models that List() would return without pagination.
+// Sort and pagination are ignored.
+func (t Table) CountOptions(model interface{}, options ListOptions) (int64, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	options.Page = nil
+	options.Sort = nil
+	options.After = nil
+	stmt, err := t.countSQL(t.Name(model), fields, &options)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+	count := int64(0)
+	params := options.Params()
+	row := t.DB.QueryRow(stmt, params...)
+	err = row.Scan(&count)
+	if err != nil {
+		return 0, liberr.Wrap(err)
+	}
+
+	return count, nil
+}
+
+//
+// List the primary keys of models in the DB.
+// Qualified by the list options. Only the PK column is
+// selected and models are not constructed.
+// Returns (string|int64) keys.
+func (t Table) ListKeys(model interface{}, options ListOptions) ([]interface{}, error) {
 	fields, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	pk := t.PkField(fields)
+	if pk == nil {
+		return nil, liberr.Wrap(MustHavePkErr)
+	}
+	stmt, err := t.keysSQL(t.Name(model), fields, &options)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(stmt, options.Params()...)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	keys := []interface{}{}
+	for cursor.Next() {
+		err = cursor.Scan(pk.Ptr())
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		switch pk.Value.Kind() {
+		case reflect.String:
+			keys = append(keys, pk.string)
+		default:
+			keys = append(keys, pk.int)
+		}
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return keys, nil
+}
+
+//
+// Approximate count of ALL models in the DB.
+// NOT exact. The row estimate collected by ANALYZE is used
+// when available. Else, the largest ROWID is used which
+// does not reflect deletes. Intended for large tables when
+// an exact Count() is too slow.
+func (t Table) ApproxCount(model interface{}) (int64, error) {
+	table := t.Name(model)
+	analyzed := int64(0)
+	row := t.DB.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'")
+	err := row.Scan(&analyzed)
 	if err != nil {
 		return 0, liberr.Wrap(err)
 	}
-	options := ListOptions{Predicate: predicate}
-	stmt, err := t.countSQL(t.Name(model), fields, &options)
+	if analyzed > 0 {
+		stat := ""
+		row = t.DB.QueryRow(ApproxCountSQL, sql.Named("table", table))
+		err = row.Scan(&stat)
+		switch err {
+		case nil:
+			n, pErr := strconv.ParseInt(strings.Fields(stat + " 0")[0], 10, 64)
+			if pErr == nil {
+				return n, nil
+			}
+		case sql.ErrNoRows:
+		default:
+			return 0, liberr.Wrap(err)
+		}
+	}
+	count := sql.NullInt64{}
+	row = t.DB.QueryRow("SELECT MAX(rowid) FROM " + table)
+	err = row.Scan(&count)
 	if err != nil {
 		return 0, liberr.Wrap(err)
 	}
-	count := int64(0)
-	params := options.Params()
-	row := t.DB.QueryRow(stmt, params...)
+
+	return count.Int64, nil
+}
+
+//
+// Explain the query plan of the statement.
+// Returns the detail of each step reported by
+// EXPLAIN QUERY PLAN. The statement is not executed.
+func (t Table) Explain(stmt string, params ...interface{}) ([]string, error) {
+	cursor, err := t.DB.Query("EXPLAIN QUERY PLAN "+stmt, params...)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	plan := []string{}
+	for cursor.Next() {
+		id, parent, notUsed, detail := 0, 0, 0, ""
+		err = cursor.Scan(&id, &parent, &notUsed, &detail)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		plan = append(plan, detail)
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return plan, nil
+}
+
+//
+// Render the SQL and parameters used to List the model.
+// The statement is not executed.
+func (t Table) Render(model interface{}, options ListOptions) (string, []interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	stmt, err := t.listSQL(t.Name(model), fields, &options)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+
+	return stmt, options.Params(), nil
+}
+
+//
+// Render the SQL and parameters used to Get the model.
+// The statement is not executed.
+func (t Table) RenderGet(model interface{}) (string, []interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	stmt, err := t.getSQL(t.Name(model), fields)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+
+	return stmt, t.Params(fields), nil
+}
+
+//
+// Render the SQL and parameters used to Insert the model.
+// The statement is not executed. The updated (timestamp)
+// field is set.
+func (t Table) RenderInsert(model interface{}) (string, []interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	t.setID(model, fields)
+	t.SetPk(fields)
+	t.stamp(fields)
+	err = t.Required(fields, true)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	fields = t.InsertFields(fields)
+	stmt, err := t.insertSQL(t.Name(model), fields, OnConflictError)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+
+	return stmt, t.Params(fields), nil
+}
+
+//
+// Render the SQL and parameters used to Update the model.
+// The statement is not executed. The updated (timestamp)
+// field is set.
+func (t Table) RenderUpdate(model interface{}) (string, []interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	t.stamp(fields)
+	err = t.Required(fields, false)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	stmt, err := t.updateSQL(t.Name(model), fields)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+
+	return stmt, t.Params(fields), nil
+}
+
+//
+// Render the SQL and parameters used to Delete the model.
+// The statement is not executed.
+func (t Table) RenderDelete(model interface{}) (string, []interface{}, error) {
+	fields, err := t.Fields(model)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+	t.SetPk(fields)
+	stmt, err := t.deleteSQL(t.Name(model), fields, nil)
+	if err != nil {
+		return "", nil, liberr.Wrap(err)
+	}
+
+	return stmt, t.Params(fields), nil
+}
+
+//
+// Query models using raw SQL.
+// The result columns are matched to model fields by name.
+// Unmatched columns are ignored.
+// The caller is responsible for SQL-injection safety and
+// MUST pass values as `args` using placeholders.
+func (t Table) Query(model interface{}, stmt string, args ...interface{}) ([]Model, error) {
+	_, err := t.Fields(model)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	columns, err := cursor.Columns()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+	list := []Model{}
+	mt := reflect.TypeOf(model).Elem()
+	for cursor.Next() {
+		mPtr := reflect.New(mt)
+		m, cast := mPtr.Interface().(Model)
+		if !cast {
+			return nil, liberr.Wrap(MustBeObjectErr)
+		}
+		fields, _ := t.Fields(m)
+		err = t.scanColumns(cursor, columns, fields)
+		if err != nil {
+			return nil, liberr.Wrap(err)
+		}
+		list = append(list, m)
+	}
+	err = cursor.Err()
+	if err != nil {
+		return nil, liberr.Wrap(err)
+	}
+
+	return list, nil
+}
+
+//
+// Query using raw SQL into an arbitrary struct list.
+// The `list` must be a pointer to a slice of structs. The
+// result columns are matched to the exported fields of the
+// struct by name (case-insensitive). Columns not matched are
+// ignored. Nullable columns may be scanned into pointer or
+// sql.Null* fields.
+// The caller is responsible for SQL-injection safety and
+// MUST pass values as `args` using placeholders.
+func (t Table) QueryInto(list interface{}, stmt string, args ...interface{}) error {
+	lt := reflect.TypeOf(list)
+	if lt.Kind() != reflect.Ptr || lt.Elem().Kind() != reflect.Slice {
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	st := lt.Elem().Elem()
+	if st.Kind() != reflect.Struct {
+		return liberr.Wrap(MustBeObjectErr)
+	}
+	cursor, err := t.DB.Query(stmt, args...)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	columns, err := cursor.Columns()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	index := make([]int, len(columns))
+	for i, column := range columns {
+		index[i] = -1
+		for j := 0; j < st.NumField(); j++ {
+			f := st.Field(j)
+			if f.PkgPath == "" && strings.EqualFold(f.Name, column) {
+				index[i] = j
+				break
+			}
+		}
+	}
+	lv := reflect.ValueOf(list).Elem()
+	for cursor.Next() {
+		sv := reflect.New(st).Elem()
+		ptr := []interface{}{}
+		for _, j := range index {
+			if j < 0 {
+				ptr = append(ptr, new(interface{}))
+				continue
+			}
+			ptr = append(ptr, sv.Field(j).Addr().Interface())
+		}
+		err = cursor.Scan(ptr...)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		lv.Set(reflect.Append(lv, sv))
+	}
+	err = cursor.Err()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// List the named fields of models into a list of (DTO) structs.
+// The `dest` must be a pointer to a slice of structs. Only
+// the columns of the named fields are selected. Each field is
+// copied to the exported struct field with the same name
+// (case-insensitive). Unmatched fields are discarded. The
+// predicate may reference any field of the model. The sort
+// positions are relative to the named fields.
+func (t Table) ListProjection(dest interface{}, model interface{}, names []string, options ListOptions) error {
+	dt := reflect.TypeOf(dest)
+	if dt.Kind() != reflect.Ptr || dt.Elem().Kind() != reflect.Slice {
+		return liberr.Wrap(MustBeSlicePtrErr)
+	}
+	st := dt.Elem().Elem()
+	if st.Kind() != reflect.Struct {
+		return liberr.Wrap(MustBeObjectErr)
+	}
+	fields, err := t.Fields(model)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	selected, err := t.projected(fields, names)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	stmt, err := t.projectionSQL(t.Name(model), fields, selected, &options)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	cursor, err := t.DB.Query(stmt, options.Params()...)
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+	defer cursor.Close()
+	index := make([]int, len(selected))
+	for i, f := range selected {
+		index[i] = -1
+		for j := 0; j < st.NumField(); j++ {
+			sf := st.Field(j)
+			if sf.PkgPath == "" && strings.EqualFold(sf.Name, f.field) {
+				index[i] = j
+				break
+			}
+		}
+	}
+	mt := reflect.TypeOf(model)
+	if mt.Kind() == reflect.Ptr {
+		mt = mt.Elem()
+	}
+	dv := reflect.ValueOf(dest).Elem()
+	for cursor.Next() {
+		mPtr := reflect.New(mt)
+		newFields, _ := t.Fields(mPtr.Interface())
+		newSelected, _ := t.projected(newFields, names)
+		err = t.scan(cursor, newSelected)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		sv := reflect.New(st).Elem()
+		for i, f := range newSelected {
+			if index[i] < 0 {
+				continue
+			}
+			fv := sv.Field(index[i])
+			switch {
+			case f.Value.Type().AssignableTo(fv.Type()):
+				fv.Set(*f.Value)
+			case f.Value.Type().ConvertibleTo(fv.Type()):
+				fv.Set(f.Value.Convert(fv.Type()))
+			default:
+				return liberr.Wrap(FieldTypeErr)
+			}
+		}
+		dv.Set(reflect.Append(dv, sv))
+	}
+	err = cursor.Err()
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}
+
+//
+// Execute raw SQL.
+// Returns the number of rows affected.
+// The caller is responsible for SQL-injection safety and
+// MUST pass values as `args` using placeholders.
+func (t Table) Exec(stmt string, args ...interface{}) (int64, error) {
+	r, err := t.DB.Exec(stmt, args...)
 	if err != nil {
 		return 0, liberr.Wrap(err)
 	}
-	err = row.Scan(&count)
+	nRows, err := r.RowsAffected()
 	if err != nil {
 		return 0, liberr.Wrap(err)
 	}
 
-	return count, nil
+	return nRows, nil
 }
 
 //
@@ -457,6 +1921,17 @@ func (t Table) Fields(model interface{}) ([]*Field, error) {
 		if !fv.CanSet() {
 			continue
 		}
+		var valid *reflect.Value
+		var ptr *reflect.Value
+		if value, isValid, isNullable := t.nullable(fv); isNullable {
+			fv = value
+			valid = &isValid
+		} else if value, isValid, isPtr := t.pointer(fv); isPtr {
+			pv := fv
+			ptr = &pv
+			fv = value
+			valid = &isValid
+		}
 		switch fv.Kind() {
 		case reflect.Struct:
 			nested, err := t.Fields(fv.Addr().Interface())
@@ -472,22 +1947,90 @@ func (t Table) Fields(model interface{}) ([]*Field, error) {
 			reflect.Int32,
 			reflect.Int64:
 			sqlTag, found := ft.Tag.Lookup(Tag)
-			if !found {
+			computed, isComputed := ft.Tag.Lookup(ComputedTag)
+			if !found && !isComputed {
 				continue
 			}
-			fields = append(
-				fields,
-				&Field{
-					Tag:   sqlTag,
-					Name:  ft.Name,
-					Value: &fv,
-				})
+			field := &Field{
+				Tag:   sqlTag,
+				Name:  t.ident(ft.Name),
+				Value: &fv,
+				field: ft.Name,
+				valid: valid,
+				ptr:   ptr,
+			}
+			if isComputed {
+				field.Computed = computed
+			}
+			field.Default, field.defaulted = ft.Tag.Lookup(DefaultTag)
+			fields = append(fields, field)
 		}
 	}
 
 	return fields, nil
 }
 
+//
+// Nullable field.
+// A struct (Eg: sql.NullString, Optional[T]) implementing
+// sql.Scanner with the (scalar) value as the first field and
+// the `Valid` (bool) as the second field. Returns the value
+// and the valid (fields).
+func (t Table) nullable(fv reflect.Value) (value, valid reflect.Value, isNullable bool) {
+	if fv.Kind() != reflect.Struct || fv.NumField() != 2 {
+		return
+	}
+	if _, cast := fv.Addr().Interface().(sql.Scanner); !cast {
+		return
+	}
+	if fv.Type().Field(1).Name != "Valid" || fv.Field(1).Kind() != reflect.Bool {
+		return
+	}
+	switch fv.Field(0).Kind() {
+	case reflect.String,
+		reflect.Bool,
+		reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		value = fv.Field(0)
+		valid = fv.Field(1)
+		isNullable = value.CanSet()
+	}
+
+	return
+}
+
+//
+// Pointer (nullable) field.
+// A pointer to a (scalar) value. The value is copied so that
+// the pointer may be nil. Returns the (copied) value and the
+// valid (not nil) flag. See: Field.Push().
+func (t Table) pointer(fv reflect.Value) (value, valid reflect.Value, isPtr bool) {
+	if fv.Kind() != reflect.Ptr {
+		return
+	}
+	switch fv.Type().Elem().Kind() {
+	case reflect.String,
+		reflect.Bool,
+		reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		value = reflect.New(fv.Type().Elem()).Elem()
+		valid = reflect.New(reflect.TypeOf(true)).Elem()
+		if !fv.IsNil() {
+			value.Set(fv.Elem())
+			valid.SetBool(true)
+		}
+		isPtr = fv.CanSet()
+	}
+
+	return
+}
+
 //
 // Get the `Fields` referenced as param in SQL.
 func (t Table) Params(fields []*Field) []interface{} {
@@ -536,22 +2079,95 @@ func (t Table) SetPk(fields []*Field) error {
 			h.Write(bfr.Bytes())
 		}
 	}
-	pk.string = hex.EncodeToString(h.Sum(nil))
-	pk.Push()
+	pk.string = hex.EncodeToString(h.Sum(nil))
+	pk.Push()
+	return nil
+}
+
+//
+// Set the (str) PK using the ID generator when not set.
+// Models implementing IDGenerator generate the ID. Else,
+// the GenerateID function is used for models without natural
+// keys. Models with natural keys without an IDGenerator have
+// the PK generated by SetPk().
+func (t Table) setID(model interface{}, fields []*Field) {
+	pk := t.PkField(fields)
+	if pk == nil || pk.Value.Kind() != reflect.String || pk.Value.String() != "" {
+		return
+	}
+	id := ""
+	if m, cast := model.(IDGenerator); cast {
+		id = m.GenerateID()
+	} else if t.GenerateID != nil && len(t.KeyFields(fields)) == 0 {
+		id = t.GenerateID()
+	}
+	if id != "" {
+		pk.Value.SetString(id)
+	}
+}
+
+//
+// Get the mutable `Fields` for the model.
+func (t Table) MutableFields(fields []*Field) []*Field {
+	list := []*Field{}
+	for _, f := range fields {
+		if f.Mutable() {
+			list = append(list, f)
+		}
+	}
+
+	return list
+}
+
+//
+// Get the stored (not computed) `Fields` for the model.
+func (t Table) StoredFields(fields []*Field) []*Field {
+	list := []*Field{}
+	for _, f := range fields {
+		if f.Computed == "" {
+			list = append(list, f)
+		}
+	}
+
+	return list
+}
+
+//
+// Get the expiry (expires) field.
+// Returns nil when not declared.
+func (t Table) ExpiresField(fields []*Field) *Field {
+	for _, f := range fields {
+		if f.Expires() {
+			return f
+		}
+	}
+
 	return nil
 }
 
 //
-// Get the mutable `Fields` for the model.
-func (t Table) MutableFields(fields []*Field) []*Field {
-	list := []*Field{}
+// Get the updated (timestamp) field.
+// Returns nil when not declared.
+func (t Table) UpdatedField(fields []*Field) *Field {
 	for _, f := range fields {
-		if f.Mutable() {
-			list = append(list, f)
+		if f.Updated() {
+			return f
 		}
 	}
 
-	return list
+	return nil
+}
+
+//
+// Set the updated (timestamp) field to the current time.
+// Returns the field or nil when not declared.
+func (t Table) stamp(fields []*Field) *Field {
+	f := t.UpdatedField(fields)
+	if f != nil {
+		f.Value.SetInt(time.Now().UnixNano())
+	}
+
+	return f
 }
 
 //
@@ -606,6 +2222,8 @@ func (t Table) Constraints(fields []*Field) []string {
 		if fk == nil {
 			continue
 		}
+		fk.Table = t.ident(fk.Table)
+		fk.Field = t.ident(fk.Field)
 		constraints = append(constraints, fk.DDL(field))
 	}
 
@@ -614,19 +2232,29 @@ func (t Table) Constraints(fields []*Field) []string {
 
 //
 // Build model insert SQL.
-func (t Table) insertSQL(table string, fields []*Field) (string, error) {
+// The ON CONFLICT clause is selected by the strategy. A model
+// without mutable fields has nothing to replace and the
+// conflict is ignored.
+func (t Table) insertSQL(table string, fields []*Field, onConflict OnConflict) (string, error) {
 	tpl := template.New("")
 	tpl, err := tpl.Parse(InsertSQL)
 	if err != nil {
 		return "", liberr.Wrap(err)
 	}
+	data := TmplData{
+		Table:  table,
+		Fields: fields,
+		Pk:     t.PkField(fields),
+	}
+	switch onConflict {
+	case OnConflictIgnore:
+		data.IfAbsent = true
+	case OnConflictReplace:
+		data.Replace = t.MutableFields(fields)
+		data.IfAbsent = len(data.Replace) == 0
+	}
 	bfr := &bytes.Buffer{}
-	err = tpl.Execute(
-		bfr,
-		TmplData{
-			Table:  table,
-			Fields: fields,
-		})
+	err = tpl.Execute(bfr, data)
 	if err != nil {
 		return "", liberr.Wrap(err)
 	}
@@ -659,7 +2287,8 @@ func (t Table) updateSQL(table string, fields []*Field) (string, error) {
 
 //
 // Build model delete SQL.
-func (t Table) deleteSQL(table string, fields []*Field) (string, error) {
+// The `options` (predicate) may be nil.
+func (t Table) deleteSQL(table string, fields []*Field, options *ListOptions) (string, error) {
 	tpl := template.New("")
 	tpl, err := tpl.Parse(DeleteSQL)
 	if err != nil {
@@ -669,8 +2298,9 @@ func (t Table) deleteSQL(table string, fields []*Field) (string, error) {
 	err = tpl.Execute(
 		bfr,
 		TmplData{
-			Table: table,
-			Pk:    t.PkField(fields),
+			Table:   table,
+			Pk:      t.PkField(fields),
+			Options: options,
 		})
 	if err != nil {
 		return "", liberr.Wrap(err)
@@ -710,6 +2340,8 @@ func (t Table) listSQL(table string, fields []*Field, options *ListOptions) (str
 	if err != nil {
 		return "", liberr.Wrap(err)
 	}
+	options.naming = t.Naming
+	options.namespace = t.Namespace
 	err = options.Build(table, fields)
 	if err != nil {
 		return "", liberr.Wrap(err)
@@ -730,6 +2362,93 @@ func (t Table) listSQL(table string, fields []*Field, options *ListOptions) (str
 	return bfr.String(), nil
 }
 
+//
+// Get the named (projected) fields.
+// Returns FieldRefErr when a name is not matched.
+func (t Table) projected(fields []*Field, names []string) ([]*Field, error) {
+	selected := []*Field{}
+	for _, name := range names {
+		var field *Field
+		for _, f := range fields {
+			if f.Match(name) {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			return nil, liberr.Wrap(FieldRefErr)
+		}
+		selected = append(selected, field)
+	}
+	if len(selected) == 0 {
+		return nil, liberr.Wrap(FieldRefErr)
+	}
+
+	return selected, nil
+}
+
+//
+// Build model projection (list) SQL.
+// The `fields` are used to build the options (predicate) and
+// only the `selected` fields are selected.
+func (t Table) projectionSQL(table string, fields, selected []*Field, options *ListOptions) (string, error) {
+	tpl := template.New("")
+	tpl, err := tpl.Parse(ListSQL)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+	options.naming = t.Naming
+	options.namespace = t.Namespace
+	err = options.Build(table, fields)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:   table,
+			Fields:  selected,
+			Options: options,
+			Pk:      t.PkField(fields),
+		})
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+
+	return bfr.String(), nil
+}
+
+//
+// Build model (primary) key list SQL.
+func (t Table) keysSQL(table string, fields []*Field, options *ListOptions) (string, error) {
+	tpl := template.New("")
+	tpl, err := tpl.Parse(ListSQL)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+	options.naming = t.Naming
+	options.namespace = t.Namespace
+	err = options.Build(table, fields)
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+	bfr := &bytes.Buffer{}
+	err = tpl.Execute(
+		bfr,
+		TmplData{
+			Table:   table,
+			Fields:  []*Field{t.PkField(fields)},
+			Options: options,
+			Pk:      t.PkField(fields),
+		})
+	if err != nil {
+		return "", liberr.Wrap(err)
+	}
+
+	return bfr.String(), nil
+}
+
 //
 // Build model count SQL.
 func (t Table) countSQL(table string, fields []*Field, options *ListOptions) (string, error) {
@@ -738,6 +2457,8 @@ func (t Table) countSQL(table string, fields []*Field, options *ListOptions) (st
 	if err != nil {
 		return "", liberr.Wrap(err)
 	}
+	options.naming = t.Naming
+	options.namespace = t.Namespace
 	err = options.Build(table, fields)
 	if err != nil {
 		return "", liberr.Wrap(err)
@@ -778,10 +2499,69 @@ func (t Table) scan(row Row, fields []*Field) error {
 	return liberr.Wrap(err)
 }
 
+//
+// Scan the fetched row into the model.
+// The `columns` are matched to fields by name (case-insensitive).
+// Unmatched columns are discarded.
+func (t Table) scanColumns(row Row, columns []string, fields []*Field) error {
+	list := []interface{}{}
+	matched := []*Field{}
+	for _, name := range columns {
+		var field *Field
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, name) {
+				field = f
+				break
+			}
+		}
+		if field == nil {
+			list = append(list, new(interface{}))
+			continue
+		}
+		field.Pull()
+		list = append(list, field.Ptr())
+		matched = append(matched, field)
+	}
+	err := row.Scan(list...)
+	if err == nil {
+		for _, f := range matched {
+			f.Push()
+		}
+	}
+
+	return liberr.Wrap(err)
+}
+
 //
 // Regex used for `unique(group)` tags.
 var UniqueRegex = regexp.MustCompile(`(unique)(\()(.+)(\))`)
 
+//
+// Regex used for `index(group:where)` tags.
+var IndexRegex = regexp.MustCompile(`^index\(([a-zA-Z0-9_]+)(:(.+))?\)$`)
+
+//
+// Regex used to match identifiers in index predicates.
+var IdentRegex = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
+
+//
+// Regex used to match string literals in index predicates.
+var StringLiteralRegex = regexp.MustCompile(`'[^']*'`)
+
+//
+// Keywords permitted in index predicates.
+var WhereKeywords = map[string]bool{
+	"AND":   true,
+	"OR":    true,
+	"NOT":   true,
+	"IS":    true,
+	"NULL":  true,
+	"IN":    true,
+	"LIKE":  true,
+	"TRUE":  true,
+	"FALSE": true,
+}
+
 //
 // Regex used for `fk:<table>(field)` tags.
 var FkRegex = regexp.MustCompile(`(fk):(.+)(\()(.+)(\))`)
@@ -799,20 +2579,71 @@ var FkRegex = regexp.MustCompile(`(fk):(.+)(\()(.+)(\))`)
 //       Unique index. `G` = unique-together fields.
 //   `sql:"const"`
 //       The field is immutable and not included on update.
+//   `sql:"index(G)"`
+//       Index. `G` = index (group) name.
+//   `sql:"index(G:P)"`
+//       Partial index. `P` = predicate (WHERE) referencing
+//       only model fields. May not contain commas.
+//   `sql:"notnull"`
+//       The field value may not be the zero value. When
+//       nullable, the value must be valid (not nil) and may
+//       be the zero value. The PK column is always NOT NULL
+//       and may be the zero value.
+//   `sql:"expires"`
+//       The (int) field is the expiry time (unix seconds).
+//       Zero = never expires. See: Client.Sweep().
+//   `sql:"updated"`
+//       The (int64) field is the time (unix nanoseconds) the
+//       model was last written. Set on insert and update.
+//       See: Client.Touch().
+//   `computed:"E"`
+//       Computed (read-only) field. `E` = SQL expression
+//       selected on Get() and List(). Not stored and
+//       ignored on insert and update. The `sql` tag is
+//       optional.
+//   `default:"V"`
+//       Column default value. Applied on insert when the field
+//       has the zero value, so the zero value cannot be inserted
+//       explicitly when `V` is not the zero value. When nullable,
+//       applied only when not valid (nil) and the (valid) zero
+//       value is inserted.
+// Nullable fields (Eg: sql.NullString, Optional[T], *T) are
+// stored as NULL when not valid (nil).
+// Fields of named (int, str, bool) types (Eg: enums declared
+// as `type Phase int`) are stored as the underlying type.
+// Durations (time.Duration) are stored as INTEGER nanoseconds.
 //
 type Field struct {
 	// reflect.Value of the field.
 	Value *reflect.Value
 	// Tags.
 	Tag string
-	// Field name.
+	// Field (column) name.
+	// See: Naming.
 	Name string
+	// Model (struct) field name.
+	field string
 	// Staging (string) values.
 	string string
 	// Staging (int) values.
 	int int64
+	// reflect.Value of the `Valid` field (nullable only).
+	valid *reflect.Value
+	// reflect.Value of the model field (pointer only).
+	ptr *reflect.Value
+	// Staging (nullable) values.
+	nullString sql.NullString
+	nullInt    sql.NullInt64
 	// Referenced as a parameter.
 	isParam bool
+	// Default value.
+	// See: `default` tag.
+	Default string
+	// Has default value.
+	defaulted bool
+	// Computed (SQL) expression.
+	// See: `computed` tag.
+	Computed string
 }
 
 //
@@ -832,6 +2663,38 @@ func (f *Field) Validate() error {
 	default:
 		return liberr.Wrap(FieldTypeErr)
 	}
+	if f.defaulted {
+		_, err := f.DefaultLiteral()
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+	}
+	if f.Computed != "" && (f.Pk() || f.Key()) {
+		return liberr.Wrap(ComputedFieldErr)
+	}
+	if f.Fts() && (f.Value.Kind() != reflect.String || f.Computed != "") {
+		return liberr.Wrap(FtsFieldErr)
+	}
+	if f.Nullable() && (f.Pk() || f.Key()) {
+		return liberr.Wrap(NullableFieldErr)
+	}
+	if f.Expires() {
+		switch f.Value.Kind() {
+		case reflect.Int,
+			reflect.Int32,
+			reflect.Int64:
+		default:
+			return liberr.Wrap(ExpiresFieldErr)
+		}
+		if f.Computed != "" || f.Pk() {
+			return liberr.Wrap(ExpiresFieldErr)
+		}
+	}
+	if f.Updated() {
+		if f.Value.Kind() != reflect.Int64 || f.Computed != "" || f.Pk() {
+			return liberr.Wrap(UpdatedFieldErr)
+		}
+	}
 
 	return nil
 }
@@ -839,8 +2702,12 @@ func (f *Field) Validate() error {
 //
 // Pull from model.
 // Populate the appropriate `staging` field using the
-// model field value.
+// model field value. Returns nil when the (nullable)
+// field is not valid.
 func (f *Field) Pull() interface{} {
+	if f.Nullable() && !f.valid.Bool() {
+		return nil
+	}
 	switch f.Value.Kind() {
 	case reflect.String:
 		f.string = f.Value.String()
@@ -866,6 +2733,12 @@ func (f *Field) Pull() interface{} {
 //
 // Pointer used for Scan().
 func (f *Field) Ptr() interface{} {
+	if f.Nullable() {
+		if f.Value.Kind() == reflect.String {
+			return &f.nullString
+		}
+		return &f.nullInt
+	}
 	switch f.Value.Kind() {
 	case reflect.String:
 		return &f.string
@@ -884,7 +2757,25 @@ func (f *Field) Ptr() interface{} {
 //
 // Push to the model.
 // Set the model field value using the `staging` field.
+// The (nullable) field is set to the zero value and not
+// valid when NULL was scanned.
 func (f *Field) Push() {
+	if f.Nullable() {
+		valid := false
+		if f.Value.Kind() == reflect.String {
+			f.string = f.nullString.String
+			valid = f.nullString.Valid
+		} else {
+			f.int = f.nullInt.Int64
+			valid = f.nullInt.Valid
+		}
+		f.valid.SetBool(valid)
+		if !valid {
+			f.Value.Set(reflect.Zero(f.Value.Type()))
+			f.setPtr()
+			return
+		}
+	}
 	switch f.Value.Kind() {
 	case reflect.String:
 		f.Value.SetString(f.string)
@@ -901,34 +2792,115 @@ func (f *Field) Push() {
 		reflect.Int64:
 		f.Value.SetInt(f.int)
 	}
+	f.setPtr()
+}
+
+//
+// Set the (pointer) model field.
+// Set to a new copy of the value when valid. Else, nil.
+func (f *Field) setPtr() {
+	if f.ptr == nil {
+		return
+	}
+	if !f.valid.Bool() {
+		f.ptr.Set(reflect.Zero(f.ptr.Type()))
+		return
+	}
+	p := reflect.New(f.Value.Type())
+	p.Elem().Set(*f.Value)
+	f.ptr.Set(p)
 }
 
 //
 // Column DDL.
 func (f *Field) DDL() string {
 	part := []string{
-		f.Name, // name
-		"",     // type
-		"",     // constraint
+		f.Name,   // name
+		f.Type(), // type
+		"",       // constraint
+	}
+	switch {
+	case f.Pk():
+		part[2] = "PRIMARY KEY NOT NULL"
+	case f.Nullable() && !f.NotNull():
+		part[2] = "NULL"
+	default:
+		part[2] = "NOT NULL"
+	}
+	if f.defaulted {
+		literal, _ := f.DefaultLiteral()
+		part = append(part, "DEFAULT", literal)
+	}
+
+	return strings.Join(part, " ")
+}
+
+//
+// Get whether the field is required (notnull).
+// The value may not be the zero value or, when
+// nullable, must be valid (not nil).
+func (f *Field) NotNull() bool {
+	return f.hasOpt("notnull")
+}
+
+//
+// Get whether the field is nullable.
+func (f *Field) Nullable() bool {
+	return f.valid != nil
+}
+
+//
+// Get whether the field has a default value.
+func (f *Field) Defaulted() bool {
+	return f.defaulted
+}
+
+//
+// Get the default value as an SQL literal.
+func (f *Field) DefaultLiteral() (string, error) {
+	switch f.Value.Kind() {
+	case reflect.String:
+		return "'" + strings.ReplaceAll(f.Default, "'", "''") + "'", nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(f.Default)
+		if err != nil {
+			return "", liberr.Wrap(DefaultValueErr)
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	case reflect.Int,
+		reflect.Int8,
+		reflect.Int16,
+		reflect.Int32,
+		reflect.Int64:
+		n, err := strconv.ParseInt(f.Default, 10, 64)
+		if err != nil {
+			return "", liberr.Wrap(DefaultValueErr)
+		}
+		return strconv.FormatInt(n, 10), nil
 	}
+
+	return "", liberr.Wrap(DefaultValueErr)
+}
+
+//
+// Column (SQL) type.
+func (f *Field) Type() string {
 	switch f.Value.Kind() {
 	case reflect.String:
-		part[1] = "TEXT"
+		return "TEXT"
 	case reflect.Bool,
 		reflect.Int,
 		reflect.Int8,
 		reflect.Int16,
 		reflect.Int32,
 		reflect.Int64:
-		part[1] = "INTEGER"
-	}
-	if f.Pk() {
-		part[2] = "PRIMARY KEY"
-	} else {
-		part[2] = "NOT NULL"
+		return "INTEGER"
 	}
 
-	return strings.Join(part, " ")
+	return ""
 }
 
 //
@@ -938,6 +2910,36 @@ func (f *Field) Param() string {
 	return ":" + f.Name
 }
 
+//
+// Get the column expression.
+// The (parenthesized) expression for computed fields.
+func (f *Field) Column() string {
+	if f.Computed != "" {
+		return "(" + f.Computed + ")"
+	}
+
+	return f.Name
+}
+
+//
+// Get the SELECT expression.
+// Computed fields are selected using the expression
+// aliased to the field (column) name.
+func (f *Field) Select() string {
+	if f.Computed != "" {
+		return f.Column() + " AS " + f.Name
+	}
+
+	return f.Name
+}
+
+//
+// Get whether the field matches the name.
+// Either the model (struct) field name or column name.
+func (f *Field) Match(name string) bool {
+	return f.Name == name || f.field == name
+}
+
 //
 // Get whether field is the primary key.
 func (f *Field) Pk() bool {
@@ -948,7 +2950,7 @@ func (f *Field) Pk() bool {
 // Get whether field is mutable.
 // Only mutable fields will be updated.
 func (f *Field) Mutable() bool {
-	if f.Pk() || f.Key() {
+	if f.Pk() || f.Key() || f.Computed != "" {
 		return false
 	}
 
@@ -961,6 +2963,24 @@ func (f *Field) Key() bool {
 	return f.hasOpt("key")
 }
 
+//
+// Get whether the field is the expiry time.
+func (f *Field) Expires() bool {
+	return f.hasOpt("expires")
+}
+
+//
+// Get whether the field is the updated timestamp.
+func (f *Field) Updated() bool {
+	return f.hasOpt("updated")
+}
+
+//
+// Get whether the field is full-text indexed.
+func (f *Field) Fts() bool {
+	return f.hasOpt("fts")
+}
+
 //
 // Get whether the field is unique.
 func (f *Field) Unique() []string {
@@ -976,6 +2996,26 @@ func (f *Field) Unique() []string {
 	return list
 }
 
+//
+// Get the indexes declared on the field.
+func (f *Field) Indexes() []Index {
+	list := []Index{}
+	for _, opt := range strings.Split(f.Tag, ",") {
+		opt = strings.TrimSpace(opt)
+		m := IndexRegex.FindStringSubmatch(opt)
+		if m != nil && len(m) == 4 {
+			list = append(
+				list,
+				Index{
+					Name:  m[1],
+					Where: strings.TrimSpace(m[3]),
+				})
+		}
+	}
+
+	return list
+}
+
 //
 // Get whether the field is a foreign key.
 func (f *Field) Fk() *FK {
@@ -1131,6 +3171,10 @@ type TmplData struct {
 	Options *ListOptions
 	// Count
 	Count bool
+	// Insert if absent.
+	IfAbsent bool
+	// Fields replaced on (PK) conflict.
+	Replace []*Field
 }
 
 //
@@ -1160,19 +3204,80 @@ type ListOptions struct {
 	Sort []int
 	// Predicate
 	Predicate Predicate
+	// Keyset pagination.
+	// The token (sort key) of the last model listed.
+	// See: Table.ListAfter().
+	After []interface{}
+	// Detail level.
+	// Determines whether labels are populated.
+	Detail Detail
 	// Table (name).
 	table string
 	// Fields.
 	fields []*Field
 	// Params.
 	params []interface{}
+	// Promoted labels.
+	// Map of: kind => label names.
+	promoted map[string][]string
+	// Naming strategy.
+	naming Naming
+	// Label kind namespace.
+	namespace string
 }
 
+//
+// Insert conflict strategy.
+// Determines how an insert that conflicts with an existing
+// model (PK or unique index) is resolved. Applied by
+// InsertWith() and InsertAll(). Not applied by Insert().
+type OnConflict int
+
+const (
+	// Fail with ConflictError (default).
+	OnConflictError OnConflict = iota
+	// Skip the insert. The existing model is not changed.
+	OnConflictIgnore
+	// Overwrite the existing model (with the same PK).
+	// Conflicts on other unique indexes fail.
+	OnConflictReplace
+)
+
+//
+// Detail level.
+// Determines what is populated when models are listed.
+type Detail int
+
+const (
+	// Fields only (default).
+	DetailNone Detail = iota
+	// Fields and labels. Labels are populated for
+	// models implementing LabelSetter.
+	DetailLabels
+	// All details.
+	DetailAll
+)
+
 //
 // Validate options.
 func (l *ListOptions) Build(table string, fields []*Field) error {
 	l.table = table
 	l.fields = fields
+	if l.After != nil {
+		keys, err := l.keyset(fields)
+		if err != nil {
+			return liberr.Wrap(err)
+		}
+		after := &afterPredicate{
+			fields: keys,
+			values: l.After,
+		}
+		if l.Predicate != nil {
+			l.Predicate = And(l.Predicate, after)
+		} else {
+			l.Predicate = after
+		}
+	}
 	if l.Predicate == nil {
 		return nil
 	}
@@ -1184,6 +3289,47 @@ func (l *ListOptions) Build(table string, fields []*Field) error {
 	return nil
 }
 
+//
+// Get the (keyset) sort key fields.
+// The sort fields followed by the PK. The PK is appended
+// to the sort as needed so the order is total.
+func (l *ListOptions) keyset(fields []*Field) ([]*Field, error) {
+	var pk *Field
+	position := 0
+	for i, f := range fields {
+		if f.Pk() {
+			pk = f
+			position = i + 1
+			break
+		}
+	}
+	if pk == nil {
+		return nil, liberr.Wrap(MustHavePkErr)
+	}
+	keys := []*Field{}
+	sort := []int{}
+	for _, n := range l.Sort {
+		if n < 1 || n > len(fields) {
+			return nil, liberr.Wrap(SortErr)
+		}
+		keys = append(keys, fields[n-1])
+		sort = append(sort, n)
+		if n == position {
+			return keys, nil
+		}
+	}
+	l.Sort = append(sort, position)
+	keys = append(keys, pk)
+
+	return keys, nil
+}
+
+//
+// Get the label kind.
+func (l *ListOptions) kind() string {
+	return l.namespace + l.table
+}
+
 //
 // Get an appropriate parameter name.
 // Builds a parameter and adds it to the options.param list.
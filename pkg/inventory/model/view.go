@@ -0,0 +1,112 @@
+package model
+
+import (
+	"database/sql"
+	liberr "github.com/konveyor/controller/pkg/error"
+)
+
+//
+// Read view.
+// Reads bound to a single (read) transaction spanning
+// all tables. All reads see the same committed state.
+type View struct {
+	// Read transaction.
+	tx *sql.Tx
+	// Promoted labels.
+	promoted map[string][]string
+	// Naming strategy.
+	naming Naming
+	// Label kind namespace.
+	namespace string
+}
+
+//
+// Build a table bound to the read transaction.
+func (v *View) table() Table {
+	return Table{
+		DB:        v.tx,
+		Naming:    v.naming,
+		Namespace: v.namespace,
+	}
+}
+
+//
+// Get the model.
+func (v *View) Get(model Model) error {
+	err := v.table().Get(model)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// List models.
+// The `list` must be: *[]Model.
+func (v *View) List(list interface{}, options ListOptions) error {
+	options.promoted = v.promoted
+	err := v.table().List(list, options)
+	if err != nil {
+		return Classify(err)
+	}
+
+	return nil
+}
+
+//
+// Count models.
+func (v *View) Count(model Model, predicate Predicate) (int64, error) {
+	options := ListOptions{
+		Predicate: predicate,
+		promoted:  v.promoted,
+	}
+	n, err := v.table().CountOptions(model, options)
+	if err != nil {
+		return 0, Classify(err)
+	}
+
+	return n, nil
+}
+
+//
+// Run `fn` with a read view.
+// The reads performed using the view see a consistent
+// snapshot of the DB. With WAL journaling, writers are
+// not blocked. The error returned by `fn` is returned.
+// Example:
+//   err := client.View(func(v *View) error {
+//       err := v.List(&vms, ListOptions{})
+//       if err != nil {
+//           return err
+//       }
+//       return v.List(&networks, ListOptions{})
+//   })
+func (r *Client) View(fn func(v *View) error) error {
+	if r.db == nil {
+		return liberr.Wrap(NotOpenError)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return Classify(err)
+	}
+	defer tx.Rollback()
+	// The snapshot is established by the first read.
+	n := 0
+	err = tx.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&n)
+	if err != nil {
+		return Classify(err)
+	}
+	err = fn(
+		&View{
+			tx:        tx,
+			promoted:  r.PromotedLabels,
+			naming:    r.Naming,
+			namespace: r.Namespace,
+		})
+	if err != nil {
+		return liberr.Wrap(err)
+	}
+
+	return nil
+}